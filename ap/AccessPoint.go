@@ -0,0 +1,754 @@
+// Package ap is the access point, it registers UEs and sends their signed records to the CSP
+package ap
+
+import (
+	"NPTM/blockchain"
+	"NPTM/config"
+	"NPTM/proto"
+	"NPTM/transport"
+	"NPTM/util"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	_ "strings"
+	"sync"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/suites"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+const (
+	//data and node 's scale && the number of cycle
+	record_scale = 900
+	//node_scale          = 2
+	//status
+	AP_CONFIGURATION = 0
+	AP_CONNECTED     = 2
+	AP_COLLECTION    = 3
+)
+
+//part code of AP
+////////////////////////////////////////////
+//define part
+
+type AccessPoint struct {
+	// local address
+	LocalAddr net.Addr
+	// socket, every event is encrypted and authenticated with the long-term keys
+	Socket *transport.SecureTransport
+	// network topology for OA cluster
+	OAList []net.Addr
+	//the OA that deployed this AP
+	OperatorAgentAddr net.Addr
+	//the key that OA proved when it accepted the registration
+	OperatorAgentKey kyber.Point
+	//CSP address
+	CloudServiceProviderAddr net.Addr
+	//the key the config pins for the CSP, its replies must come from it
+	CloudServiceProviderKey kyber.Point
+	//whether the CSP confirmed the registration
+	cspRegistered bool
+	// initialize the AP status
+	Status int
+
+	// crypto variables
+	Suite      suites.Suite
+	PrivateKey kyber.Scalar
+	PublicKey  kyber.Point
+	G          kyber.Point
+
+	// store UE address
+	UEs map[string]net.Addr
+
+	DecryptedTurstValueMap map[string]float64
+	DecryptedKeysMap       map[string]kyber.Point
+	//headers of the OAs' blockchain, a reputation list is only accepted with the header of its block  //轻客户端区块头链
+	Headers *blockchain.HeaderChain
+	//keys of the OAs in topology order, pinned by the topology or sent by the CSP. Headers are
+	//rejected while they are unknown  //OA公钥，未知时拒绝区块头
+	oaKeys []kyber.Point
+	//list waiting for the headers before its own and the nonce of the header request
+	pendingList *proto.SyncRepMap
+	syncNonce   int64
+
+	//list maintenance round of the records and sequence number of the last record sent
+	Round int64
+	Seq   int64
+
+	conf *config.Config
+	//the dataset the config binds to this AP
+	dataset string
+	//closed by Stop
+	stop chan struct{}
+	//sends the events queued under mu after the lock is released
+	out *util.Outbox
+	//guards the state above between the listener and Run, phase is broadcast after every event
+	mu    sync.Mutex
+	phase *sync.Cond
+}
+
+//get last OA
+//为结构体a（AccessPoint）定义的方法
+func (a *AccessPoint) GetLastOA() net.Addr {
+	if len(a.OAList) == 0 {
+		return nil
+	}
+	return a.OAList[len(a.OAList)-1]
+}
+
+//get first OA
+
+func (a *AccessPoint) GetFirstOA() net.Addr {
+	if len(a.OAList) == 0 {
+		return nil
+	}
+	return a.OAList[0]
+}
+
+//add new userEquipment
+
+func (a *AccessPoint) AddUE(key kyber.Point, val net.Addr) {
+	// delete the client who has same ip address
+	for k, v := range a.UEs {
+		//用于迭代一个集合（如数组、切片、映射或通道）的常用语法。k 表示映射中的键，v 表示映射中的值
+		if v.String() == val.String() {
+			delete(a.UEs, k)
+			break
+		}
+	}
+
+	a.UEs[key.String()] = val
+}
+
+//add OA
+
+func (a *AccessPoint) AddOA(addr net.Addr) {
+	a.OAList = append(a.OAList, addr)
+}
+
+//add into decrypted TV map
+func (a *AccessPoint) AddIntoDecryptedTVMap(key kyber.Point, val float64) {
+	keyStr := key.String()
+	a.DecryptedKeysMap[keyStr] = key
+	a.DecryptedTurstValueMap[keyStr] = val
+}
+
+///////////////////////////////////////////////
+//Handle function part
+
+// Handle processes one event received from addr
+func (accessPoint *AccessPoint) Handle(buf []byte, addr net.Addr) {
+	//one event at a time, Run waits for the status it changes
+	accessPoint.mu.Lock()
+	defer accessPoint.mu.Unlock()
+	defer accessPoint.phase.Broadcast()
+
+	//decode the event, malformed or incompatible events are dropped
+	event, msg, err := proto.Decode(buf)
+	if err != nil {
+		fmt.Println("[AP] Reject the event from", addr, ":", err)
+		return
+	}
+	//the reputation list is only accepted from the OA this AP registered with
+	if !accessPoint.authorizedAP(event.EventType, addr) {
+		fmt.Println("[AP] Reject the unauthenticated event", event.EventType, "from", addr)
+		return
+	}
+	switch event.EventType {
+	case proto.AP_REGISTER_REPLY_OA:
+		accessPoint.handleAPRegisterReply_OA(msg.(*proto.RegisterReply), addr)
+		break
+	case proto.AP_REGISTER_REPLY_CSP:
+		accessPoint.handleAPRegisterReply_CSP(msg.(*proto.RegisterReply), addr)
+		break
+	case proto.UE_REGISTER_APSIDE:
+		accessPoint.handleUERegisterAPSide(msg.(*proto.Register), addr)
+		break
+	case proto.UE_REGISTER_OASIDE:
+		accessPoint.handleUERegisterOASide_AP(msg.(*proto.UERegister))
+		break
+	case proto.SYNC_REPMAP:
+		accessPoint.handleSyncRepAP(msg.(*proto.SyncRepMap))
+		break
+	case proto.SYNC_HEADERS:
+		accessPoint.handleHeadersAP(msg.(*proto.Headers))
+		break
+	default:
+		fmt.Println("[AP] Unrecognized request...")
+		break
+	}
+}
+
+//authorizedAP checks the sender of an event against the entity expected to send it
+func (accessPoint *AccessPoint) authorizedAP(eventType int, addr net.Addr) bool {
+	switch eventType {
+	case proto.AP_REGISTER_REPLY_OA:
+		return addr.String() == accessPoint.OperatorAgentAddr.String()
+	case proto.AP_REGISTER_REPLY_CSP:
+		return addr.String() == accessPoint.CloudServiceProviderAddr.String() &&
+			accessPoint.Socket.Authenticated(addr, accessPoint.CloudServiceProviderKey)
+	case proto.UE_REGISTER_OASIDE:
+		lastOA := accessPoint.GetLastOA()
+		return lastOA != nil && addr.String() == lastOA.String()
+	case proto.SYNC_REPMAP, proto.SYNC_HEADERS:
+		return accessPoint.Socket.Authenticated(addr, accessPoint.OperatorAgentKey)
+	}
+	return true
+}
+
+func (accessPoint *AccessPoint) handleUERegisterAPSide(msg *proto.Register, srcAddr net.Addr) {
+	//get UE's public key
+	publicKey := accessPoint.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		fmt.Println("[AP] Reject the registration, invalid public key:", srcAddr)
+		return
+	}
+	//the registered key must be the one that opened the channel
+	if !accessPoint.Socket.Authenticated(srcAddr, publicKey) {
+		fmt.Println("[AP] Reject the registration, the key is not the channel's key:", srcAddr)
+		return
+	}
+	accessPoint.AddUE(publicKey, srcAddr)
+
+	firstOA := accessPoint.GetFirstOA()
+
+	event := proto.NewEvent(proto.UE_REGISTER_OASIDE, &proto.UERegister{
+		PublicKey: msg.PublicKey,
+		UEAddr:    srcAddr.String(),
+		UpperAP:   accessPoint.LocalAddr.String(),
+	})
+	fmt.Println("[AP] Send the UE's register info to OperatorAgent.")
+	//send to first OA
+	accessPoint.out.Send(firstOA, util.Encode(event))
+
+}
+
+func (accessPoint *AccessPoint) handleUERegisterOASide_AP(msg *proto.UERegister) {
+	addr, err := accessPoint.Socket.ResolveAddr(msg.UEAddr)
+	if err != nil {
+		fmt.Println("[AP] Reject the register info, invalid UE address:", msg.UEAddr)
+		return
+	}
+	event := proto.NewEvent(proto.UE_REGISTER_CONFIRMATION, &proto.UERegisterConfirmation{})
+	fmt.Println("[AP] Send the register info to UserEqiupment:", addr)
+	accessPoint.out.Send(addr, util.Encode(event))
+}
+
+func (accessPoint *AccessPoint) handleAPRegisterReply_OA(msg *proto.RegisterReply, addr net.Addr) {
+
+	if msg.Reply {
+		//remember the OA's key, later reputation lists must come from it
+		key, _ := accessPoint.Socket.PeerKey(addr)
+		if known := accessPoint.oaKey(addr.String()); known != nil && !known.Equal(key) {
+			fmt.Println("[AP] Reject the OA's reply, the key is not the one of the topology:", addr)
+			return
+		}
+		accessPoint.OperatorAgentKey = key
+		accessPoint.Status++
+		fmt.Println("[AP] Register success to OA:", addr)
+	}
+
+}
+
+//handleAPRegisterReply_CSP takes the keys of the OAs from the CSP's reply, the CSP sends them again
+//when an OA registers another key
+func (accessPoint *AccessPoint) handleAPRegisterReply_CSP(msg *proto.RegisterReply, addr net.Addr) {
+	if !msg.Reply {
+		return
+	}
+	keys, err := util.DecodePointList(msg.OAKeys)
+	if err != nil || len(keys) != len(accessPoint.conf.Topology) {
+		fmt.Println("[AP] Reject the CSP's reply, it does not hold the keys of the OAs:", addr)
+		return
+	}
+	for i, OAAddr := range accessPoint.conf.OAAddrs() {
+		if pinned := accessPoint.conf.OAKey(OAAddr); pinned != nil && !pinned.Equal(keys[i]) {
+			fmt.Println("[AP] Reject the CSP's reply, the key of", OAAddr, "is not the one of the topology")
+			return
+		}
+	}
+	accessPoint.oaKeys = keys
+	if !accessPoint.cspRegistered {
+		accessPoint.cspRegistered = true
+		accessPoint.Status++
+		fmt.Println("[AP] Register success to CSP:", addr)
+	} else {
+		fmt.Println("[AP] The CSP updated the keys of the OAs.")
+	}
+}
+
+//oaKey is the key of the OA at addr, nil while the keys are unknown or addr is not in the topology
+func (accessPoint *AccessPoint) oaKey(addr string) kyber.Point {
+	if accessPoint.oaKeys == nil {
+		return nil
+	}
+	for i, OAAddr := range accessPoint.conf.OAAddrs() {
+		if OAAddr == addr {
+			return accessPoint.oaKeys[i]
+		}
+	}
+	return nil
+}
+
+func (accessPoint *AccessPoint) handleSyncRepAP(msg *proto.SyncRepMap) {
+
+	// This event is triggered when server finishes forward shuffle
+
+	//the list must be the one of its block header  //列表必须与其区块头一致
+	header, err := blockchain.DecodeHeader(msg.Header)
+	if err == nil {
+		err = accessPoint.checkListHeader(&header, msg)
+	}
+	if err != nil {
+		fmt.Println("[AP] Reject the reputation list:", err)
+		return
+	}
+
+	//the header has to continue the header chain, the headers before it are fetched from the OA first
+	if tip := accessPoint.Headers.Tip(); tip == nil || !bytes.Equal(tip.Hash(), header.Hash()) {
+		if err := accessPoint.appendHeader(header); err != nil {
+			fmt.Println("[AP] The header of the list does not follow the header chain, fetch the headers before it:", err)
+			accessPoint.pendingList = msg
+			accessPoint.requestHeaders(header.Hash())
+			return
+		}
+	}
+	accessPoint.acceptList(msg)
+}
+
+//acceptList stores a checked reputation list and sends its g to the UEs
+func (accessPoint *AccessPoint) acceptList(msg *proto.SyncRepMap) {
+	var g = accessPoint.Suite.Point()
+	if err := g.UnmarshalBinary(msg.G); err != nil {
+		fmt.Println("[AP] Reject the reputation list, invalid g:", err)
+		return
+	}
+
+	//construct Decrypted reputation map
+	keyList, err := util.DecodePointList(msg.Nyms)
+	valList := msg.Vals
+	if err != nil || len(keyList) != len(valList) {
+		fmt.Println("[AP] Reject the reputation list, nyms and values do not match.")
+		return
+	}
+	accessPoint.DecryptedTurstValueMap = make(map[string]float64)
+	accessPoint.DecryptedKeysMap = make(map[string]kyber.Point)
+
+	fmt.Println("[AP] Recieve the new reputation list of block", accessPoint.Headers.Tip().K0, ".")
+	for i := 0; i < len(keyList); i++ {
+		accessPoint.AddIntoDecryptedTVMap(keyList[i], valList[i])
+	}
+
+	// distribute g and hash table of ids to user
+	event := proto.NewEvent(proto.SYNC_REPMAP, &proto.SyncRepMap{G: msg.G})
+	for _, UEAddr := range accessPoint.UEs {
+		accessPoint.out.Send(UEAddr, util.Encode(event))
+	}
+	// set controller's new g
+	accessPoint.G = g
+	//allow ap to share data to CSP
+	accessPoint.Status = AP_COLLECTION
+
+}
+
+//checkListHeader checks the header of a reputation list on its own: a list block of the last OA,
+//signed by its creator and with the Merkle root of the list and g
+func (accessPoint *AccessPoint) checkListHeader(header *blockchain.Header, msg *proto.SyncRepMap) error {
+	if header.Mined() {
+		return errors.New("a mined block can not hold a new list")
+	}
+	if err := accessPoint.checkCreator(header); err != nil {
+		return err
+	}
+	if err := header.Check(); err != nil {
+		return err
+	}
+	root, err := blockchain.ListRoot(msg.Nyms, msg.Vals, msg.G)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, header.MerkelRoot1) {
+		return errors.New("the list is not the one of its block")
+	}
+	return nil
+}
+
+//checkCreator compares the creator of a header with the keys of the OAs, list blocks are created
+//by the last OA. A header can not be checked and is rejected while the keys are unknown.
+func (accessPoint *AccessPoint) checkCreator(header *blockchain.Header) error {
+	keys := accessPoint.oaKeys
+	if keys == nil {
+		return errors.New("the keys of the OAs are not known yet")
+	}
+	if !header.Mined() {
+		keys = keys[len(keys)-1:]
+	}
+	for _, key := range keys {
+		if bytePK, _ := key.MarshalBinary(); bytes.Equal(bytePK, header.PublicKey) {
+			return nil
+		}
+	}
+	return errors.New("the block is not created by an OA of the topology")
+}
+
+//appendHeader adds a header to the header chain once its timestamp fits the tip and the local clock
+func (accessPoint *AccessPoint) appendHeader(header blockchain.Header) error {
+	if err := header.CheckTime(accessPoint.Headers.Tip(), time.Now().UnixMilli(), int64(accessPoint.conf.Protocol.ClockSkew)); err != nil {
+		return err
+	}
+	return accessPoint.Headers.Append(header, accessPoint.conf.Difficulty())
+}
+
+//checkCommit verifies the commit of a BFT header with the keys of the OAs, it fails while they are unknown
+func (accessPoint *AccessPoint) checkCommit(header *blockchain.Header) error {
+	if accessPoint.oaKeys == nil {
+		return errors.New("the keys of the OAs are not known yet")
+	}
+	return header.CheckCommit(accessPoint.oaKeys)
+}
+
+//requestHeaders asks the OA for the headers after the tip up to the block with hash to
+func (accessPoint *AccessPoint) requestHeaders(to []byte) {
+	accessPoint.syncNonce++
+	request := &proto.HeadersRequest{Nonce: accessPoint.syncNonce, To: to}
+	if tip := accessPoint.Headers.Tip(); tip != nil {
+		request.From = tip.Hash()
+	}
+	accessPoint.out.Send(accessPoint.OperatorAgentAddr, util.Encode(proto.NewEvent(proto.SYNC_HEADERS_REQUEST, request)))
+}
+
+//handleHeadersAP appends the headers the OA sent, the pending list is accepted once its header is the tip
+func (accessPoint *AccessPoint) handleHeadersAP(msg *proto.Headers) {
+	if accessPoint.pendingList == nil || msg.Nonce != accessPoint.syncNonce {
+		fmt.Println("[AP] Drop the stale headers.")
+		return
+	}
+	pending := accessPoint.pendingList
+	header, _ := blockchain.DecodeHeader(pending.Header)
+
+	if len(msg.Headers) == 0 {
+		if tip := accessPoint.Headers.Tip(); tip != nil && !bytes.Equal(msg.Tip, tip.Hash()) {
+			//the OA's chain left the tip for another branch, fetch the header chain again  //OA 切换了分支，重新获取区块头链
+			fmt.Println("[AP] The OA's chain does not hold header", tip.K0, ", fetch the header chain again.")
+			accessPoint.Headers = &blockchain.HeaderChain{}
+			accessPoint.requestHeaders(header.Hash())
+			return
+		}
+		fmt.Println("[AP] Reject the reputation list, its block is not in the OA's chain.")
+		accessPoint.pendingList = nil
+		return
+	}
+
+	for _, byteHeader := range msg.Headers {
+		next, err := blockchain.DecodeHeader(byteHeader)
+		if err == nil {
+			err = accessPoint.checkCreator(&next)
+		}
+		if err == nil && next.Mode == blockchain.BFT {
+			err = accessPoint.checkCommit(&next)
+		}
+		if err == nil {
+			err = accessPoint.appendHeader(next)
+		}
+		if err != nil {
+			fmt.Println("[AP] Reject the headers of the OA:", err)
+			accessPoint.pendingList = nil
+			return
+		}
+	}
+	if !bytes.Equal(accessPoint.Headers.Tip().Hash(), header.Hash()) {
+		//more headers before the list's one
+		accessPoint.requestHeaders(header.Hash())
+		return
+	}
+	fmt.Println("[AP] The header chain reached block", header.K0, "of the reputation list.")
+	accessPoint.pendingList = nil
+	accessPoint.acceptList(pending)
+}
+
+/////////////////////////////////   更新网络拓扑
+func (accessPoint *AccessPoint) updateTopology() error {
+	for _, v := range accessPoint.conf.OAAddrs() {
+		addr, err := accessPoint.Socket.ResolveAddr(v)
+		if err != nil {
+			return err
+		}
+		accessPoint.OAList = append(accessPoint.OAList, addr)
+	}
+
+	fmt.Println("[AP] The OA topology list is updated!")
+	fmt.Println("[AP] OA topology list:", accessPoint.OAList)
+	return nil
+}
+
+// New listens on listen ("" is the first free port from ap_ip:ap_port) and prepares an AP
+// deployed by the OA at OAAddr, CSPAddr "" is csp_ip:csp_port
+func New(conf *config.Config, listen, CSPAddr, OAAddr string) (*AccessPoint, error) {
+	Socket, err := listenAP(conf, listen)
+	if err != nil {
+		return nil, err
+	}
+	//the address the others reach this node at, also when it listens on all interfaces
+	LocalAddr, err := Socket.ResolveAddr(transport.AdvertisedAddr(Socket.LocalAddr(), conf.Conn.APIP))
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+	fmt.Println("[AP] Local address is:", LocalAddr)
+
+	accessPoint, err := initAP(conf, LocalAddr, Socket, CSPAddr, OAAddr)
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+	return accessPoint, nil
+}
+
+func listenAP(conf *config.Config, listen string) (transport.Transport, error) {
+	if listen != "" {
+		return transport.Listen(conf.Conn.Transport, listen)
+	}
+	// check available port
+	Port := conf.Conn.APPort
+	for i := Port; i <= Port+1000; i++ {
+		//端口增加，监听没有错误的端口
+		conn, err := transport.Listen(conf.Conn.Transport, net.JoinHostPort(conf.Conn.APIP, strconv.Itoa(i)))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, errors.New("no free port from " + net.JoinHostPort(conf.Conn.APIP, strconv.Itoa(Port)))
+}
+
+//initialize accesspoint
+func initAP(conf *config.Config, LocalAddr net.Addr, Socket transport.Transport, CSPStr, UpperOAStr string) (*AccessPoint, error) {
+	dataset, ok := conf.Dataset(LocalAddr.String())
+	if !ok {
+		return nil, errors.New("no dataset is bound to " + LocalAddr.String() + " in the aps of the config")
+	}
+
+	//get csp's ip address
+	if CSPStr == "" {
+		CSPStr = conf.CSPAddr()
+	}
+	CSPAddr, err := Socket.ResolveAddr(CSPStr)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("[AP] CSP's IP address :", CSPAddr)
+	//get OA's ip address
+	OAAddr, err := Socket.ResolveAddr(UpperOAStr)
+	if err != nil {
+		return nil, err
+	}
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()  // Use the edwards25519-curve
+	a := suite.Scalar().Pick(suite.RandomStream()) // Alice's private key
+	A := suite.Point().Mul(a, nil)
+
+	accessPoint := &AccessPoint{
+		LocalAddr, transport.Secure(Socket, suite, a, A, LocalAddr.String()), nil, OAAddr, nil, CSPAddr, conf.CSPKey(), false, AP_CONFIGURATION,
+		suite, a, A, nil,
+		make(map[string]net.Addr),
+		make(map[string]float64), make(map[string]kyber.Point), &blockchain.HeaderChain{}, conf.OAKeys(), nil, 0, 0, 0,
+		conf, dataset, make(chan struct{}), nil, sync.Mutex{}, nil}
+	accessPoint.phase = sync.NewCond(&accessPoint.mu)
+	accessPoint.out = util.NewOutbox(accessPoint.Socket)
+	accessPoint.Socket.Pin(CSPAddr.String(), accessPoint.CloudServiceProviderKey)
+	if err := accessPoint.updateTopology(); err != nil {
+		return nil, err
+	}
+
+	fmt.Println("[AP] Parameter initialization is complete.")
+	fmt.Println("[AP] My public key is ", accessPoint.PublicKey)
+	return accessPoint, nil
+}
+
+// Start runs the listener and registers with the OA and the CSP
+func (accessPoint *AccessPoint) Start() {
+	go accessPoint.startAPListener()
+	//使用 go 关键字时，函数会在一个新的 goroutine 中异步执行，当前 goroutine 会立即继续执行后续代码，而不等待新 goroutine 执行完毕。
+	accessPoint.registerAP()
+}
+
+// Run sends the records of rounds list maintenance rounds, each once the new reputation list arrived
+func (accessPoint *AccessPoint) Run(rounds int) {
+	accessPoint.mu.Lock()
+	defer accessPoint.mu.Unlock()
+	for i := 0; i < rounds; i++ {
+		for accessPoint.Status != AP_COLLECTION {
+			//wait for new list
+			accessPoint.phase.Wait()
+		}
+		// add a time to solve interrupt
+		_, port, _ := net.SplitHostPort(accessPoint.LocalAddr.String())
+		intPort, _ := strconv.Atoi(port)
+		localPort := uint(intPort)  //无符号整数类型
+		if localPort >= 8002 {
+			accessPoint.sleep(4.0 * time.Second)
+		} else {	
+			accessPoint.sleep(3.0 * time.Second)
+		}
+		accessPoint.dataCollectionToCSP()
+	}
+}
+
+//sleep releases the lock for d, the caller holds it
+func (accessPoint *AccessPoint) sleep(d time.Duration) {
+	accessPoint.mu.Unlock()
+	time.Sleep(d)
+	accessPoint.mu.Lock()
+}
+
+//flush releases the lock until the queued events were sent, the caller holds it
+func (accessPoint *AccessPoint) flush() {
+	accessPoint.mu.Unlock()
+	accessPoint.out.Flush()
+	accessPoint.mu.Lock()
+}
+
+// Stop sends the queued events and closes the socket, the listener returns
+func (accessPoint *AccessPoint) Stop() {
+	close(accessPoint.stop)
+	accessPoint.out.Close()
+	accessPoint.Socket.Close()
+}
+
+func (accessPoint *AccessPoint) registerAP() {
+
+	// set the parameters to register
+	bytePublicKey, _ := accessPoint.PublicKey.MarshalBinary()
+	event := proto.NewEvent(proto.AP_REGISTER, &proto.Register{PublicKey: bytePublicKey})
+	//register to OA
+	util.SendUntilDelivered(accessPoint.Socket, accessPoint.OperatorAgentAddr, util.Encode(event))
+	//register to CSP
+	util.SendUntilDelivered(accessPoint.Socket, accessPoint.CloudServiceProviderAddr, util.Encode(event))
+}
+
+//持续监听，并调用处理函数
+func (accessPoint *AccessPoint) startAPListener() {
+	fmt.Println("[AP] AccessPoint listener started...")
+	for {
+		//events arrive reassembled, acknowledged and without duplicates
+		msg, addr, err := accessPoint.Socket.Receive()
+		if err != nil {
+			select {
+			case <-accessPoint.stop:
+			default:
+				fmt.Println("[AP] Listener stopped:", err)
+			}
+			return
+		}
+		//every event is a new buffer, it is handled before the next one is received
+		accessPoint.Handle(msg, addr)
+	}
+}
+
+func (accessPoint *AccessPoint) dataCollectionToCSP() {
+	//read the csv and add the nym to the head, which is NE's behavior records   ?
+
+	//get the data
+	var Records = make([]util.Record, record_scale)
+	//创建了一个长度为 record_scale 的切片，其中每个元素的类型是 util.Record。
+	var opencast *os.File = nil
+	//os.File 是 Go 标准库 os 包中的一个结构体，表示一个打开的文件对象。
+	var err error = nil
+	// support different dataset to different AP (simulate the dataset collected from UE), the config binds them
+	opencast, err = os.Open(accessPoint.dataset)
+
+	if err != nil {
+		fmt.Println("[AP] Dataset open failed!")
+	}
+
+	ReadCsv := csv.NewReader(opencast)
+
+	//Get rid of the first row
+	read, err := ReadCsv.Read()
+	util.CheckErr(err)
+	// fmt.Println(read)
+
+	node_scale := len(accessPoint.DecryptedTurstValueMap)
+
+	//将数据从字符串转换为浮点数，然后存储。
+	for i := 0; i < record_scale; i++ {
+		//read one row
+		read, err = ReadCsv.Read()
+		util.CheckErr(err)
+		//if read success,get the data to records
+		for j := 0; j < len(read)-1; j++ {
+			tempdata, _ := strconv.ParseFloat(read[j], 64)
+			/*strconv 是 Go 标准库中的一个包，用于提供字符串与基本数据类型之间的转换功能。
+			ParseFloat 是 strconv 包中的一个函数，用于将字符串转换为浮点数。
+   			64 是转换精度参数，表示将字符串转换为 float64 类型的浮点数。
+			*/
+			if math.IsInf(tempdata, 0) || math.IsNaN(tempdata) {
+				tempdata = 0.0
+				//检查浮点数 tempdata 是否为正无穷、负无穷或 NaN（Not a Number），如果是，则将其重置为 0.0。
+			}
+			Records[i].Data = append(Records[i].Data, tempdata)
+		}
+	}
+	opencast.Close()
+
+	//get the nym from NElist
+	i := 0
+	for j := 0; j < record_scale/node_scale; j++ {      // ?
+
+		for _, nym := range accessPoint.DecryptedKeysMap {
+			Records[i].Nym = nym
+			i++
+		}
+
+	}
+
+	fmt.Println("[AP] Send the records to Cloud Service Provider...")
+	accessPoint.Round++
+
+	//send one data one time
+	for i = 0; i < record_scale; i++ {
+		//sign the Record together with the round and its sequence number, a replayed record is rejected by the CSP
+		accessPoint.Seq++
+		byteRecord := util.ToSignedRecord(Records[i], accessPoint.Round, accessPoint.Seq)
+		SignRe := util.SchnorrSign(accessPoint.Suite, random.New(),
+			byteRecord, accessPoint.PrivateKey)
+		var start bool = false
+		var done bool = false
+		//set the start and done flag
+		if i == 0 {
+			start = true
+		} else if i == record_scale-1 {
+			done = true
+		}
+
+		byteNym, _ := Records[i].Nym.MarshalBinary()
+		pm := &proto.DataCollection{
+			Start:  start,
+			Nym:    byteNym,
+			Data:   Records[i].Data,
+			Round:  accessPoint.Round,
+			Seq:    accessPoint.Seq,
+			SignRe: SignRe,
+			Done:   done,
+		}
+		event := proto.NewEvent(proto.DATA_COLLECTION_AP, pm)
+		if done {
+			fmt.Println("done status:", done)
+		}
+
+		//the outbox waits for the CSP's acknowledgement of each record, so the records can not overrun the CSP
+		accessPoint.out.Send(accessPoint.CloudServiceProviderAddr, util.Encode(event))
+	}
+
+	accessPoint.flush()
+	fmt.Println("[AP] Trust data has been sent.")
+	accessPoint.Status = AP_CONNECTED
+}
+
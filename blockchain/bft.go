@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"bytes"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/sign/cosi"
+)
+
+// The block of a consensus round is either mined (POW), the winner of the OAs' search for a hash
+// below the target, or agreed on by the OAs in three phases (BFT): the proposer of the view sends a
+// block, every OA prevotes it and precommits it once a quorum prevoted it, and the block is final with
+// a quorum of precommits. The OAs that precommitted it sign its hash collectively (CoSi), the
+// signature and the bitmap of the signers are the commit of the block.  //两种共识方式：工作量证明与BFT
+
+// consensus modes of a block
+const (
+	POW = 0
+	BFT = 1
+)
+
+// Quorum is the number of votes a BFT decision needs among n OAs, 2f+1 for n = 3f+1. Two quorums
+// share more than f OAs, so at least one honest OA.
+func Quorum(n int) int {
+	return n - (n-1)/3
+}
+
+// PrevoteMessage is what an OA signs to prevote the block with this hash at height in view, a nil hash prevotes no block
+func PrevoteMessage(height, view int64, hash []byte) []byte {
+	return bytes.Join([][]byte{[]byte("prevote"), util.ToHexInt(height), util.ToHexInt(view), hash}, []byte{})
+}
+
+// PrecommitMessage is what an OA signs to precommit the block with this hash at height in view, a nil hash precommits no block
+func PrecommitMessage(height, view int64, hash []byte) []byte {
+	return bytes.Join([][]byte{[]byte("precommit"), util.ToHexInt(height), util.ToHexInt(view), hash}, []byte{})
+}
+
+// CheckCommit verifies the collective signature of the BFT block: the OAs marked in Signers, a
+// quorum of the OAs with these keys in the order of the topology, signed its hash. One check covers all signers.
+// Without keys the commit can not be verified and is rejected.
+func (h *Header) CheckCommit(keys []kyber.Point) error {
+	if h.Mode != BFT {
+		return errors.New("not a BFT block")
+	}
+	if len(keys) == 0 {
+		return errors.New("the keys of the OAs are unknown")
+	}
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	if len(h.CoSign) != suite.PointLen()+suite.ScalarLen() {
+		return errors.New("no collective signature")
+	}
+	if len(h.Signers) != (len(keys)+7)/8 {
+		return fmt.Errorf("the bitmap of signers does not fit %d OAs", len(keys))
+	}
+	if len(keys)%8 != 0 && h.Signers[len(h.Signers)-1]>>(len(keys)%8) != 0 {
+		return errors.New("the bitmap of signers marks unknown OAs")
+	}
+	signature := bytes.Join([][]byte{h.CoSign, h.Signers}, []byte{})
+	if err := cosi.Verify(suite, keys, h.Hash(), signature, quorumPolicy{}); err != nil {
+		return fmt.Errorf("the collective signature does not verify: %v", err)
+	}
+	return nil
+}
+
+// SignerCount is the number of OAs marked in Signers
+func (h *Header) SignerCount() int {
+	count := 0
+	for _, b := range h.Signers {
+		for ; b != 0; b &= b - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+//quorumPolicy accepts the collective signature of a quorum of the OAs
+type quorumPolicy struct{}
+
+func (quorumPolicy) Check(m cosi.ParticipationMask) bool {
+	return m.CountEnabled() >= Quorum(m.CountTotal())
+}
+
+// CheckCommits verifies the commit of every BFT block of the canonical chain, see CheckCommit.
+// The error is a *ValidationError for the first block without a valid commit.
+func (bc *BlockChain) CheckCommits(keys []kyber.Point) error {
+	for i, b := range bc.Blocks {
+		if b.Mode != BFT {
+			continue
+		}
+		header := b.Header()
+		if err := header.CheckCommit(keys); err != nil {
+			return &ValidationError{i, b.K0, fmt.Sprintf("commit: %v", err)}
+		}
+	}
+	return nil
+}
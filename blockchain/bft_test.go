@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/sign/cosi"
+)
+
+//testKeys are the key pairs of n OAs
+func testKeys(n int) ([]kyber.Scalar, []kyber.Point) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	rand := suite.XOF([]byte("oas"))
+	private := make([]kyber.Scalar, n)
+	public := make([]kyber.Point, n)
+	for i := range private {
+		private[i] = suite.Scalar().Pick(rand)
+		public[i] = suite.Point().Mul(private[i], nil)
+	}
+	return private, public
+}
+
+//cosign signs the hash of the header collectively with the keys of signers the way the OAs do
+func cosign(t *testing.T, h *Header, private []kyber.Scalar, public []kyber.Point, signers []int) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	mask, err := cosi.NewMask(suite, public, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := make([]kyber.Scalar, len(signers))
+	commitment := suite.Point().Null()
+	for i, signer := range signers {
+		var point kyber.Point
+		secrets[i], point = cosi.Commit(suite)
+		commitment.Add(commitment, point)
+		mask.SetBit(signer, true)
+	}
+	hash := h.Hash()
+	challenge, err := cosi.Challenge(suite, commitment, mask.AggregatePublic, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := make([]kyber.Scalar, len(signers))
+	for i, signer := range signers {
+		if responses[i], err = cosi.Response(suite, private[signer], secrets[i], challenge); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aggregate, err := cosi.AggregateResponses(suite, responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := cosi.Sign(suite, commitment, aggregate, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.CoSign = signature[:len(signature)-mask.Len()]
+	h.Signers = mask.Mask()
+}
+
+func TestQuorum(t *testing.T) {
+	for _, c := range []struct{ n, quorum int }{{1, 1}, {2, 2}, {3, 3}, {4, 3}, {5, 4}, {7, 5}, {10, 7}, {13, 9}} {
+		if got := Quorum(c.n); got != c.quorum {
+			t.Fatalf("Quorum(%d) = %d, want %d", c.n, got, c.quorum)
+		}
+	}
+}
+
+func TestCheckCommit(t *testing.T) {
+	private, public := testKeys(4)
+	block := &Block{K0: 3, PreHash: []byte("parent"), Mode: BFT, Timestamp: 1000}
+	signed := block.Header()
+	cosign(t, &signed, private, public, []int{0, 1, 3})
+	if err := signed.CheckCommit(public); err != nil {
+		t.Fatal(err)
+	}
+	if signed.SignerCount() != 3 {
+		t.Fatalf("%d signers counted, want 3", signed.SignerCount())
+	}
+
+	for _, c := range []struct {
+		name   string
+		commit func(h *Header)
+		keys   []kyber.Point
+	}{
+		//two of four OAs are below the quorum of three, even with a valid signature
+		{"below quorum", func(h *Header) { cosign(t, h, private, public, []int{0, 3}) }, public},
+		{"forged signature", func(h *Header) {
+			h.CoSign = append([]byte{}, h.CoSign...)
+			h.CoSign[len(h.CoSign)-1] ^= 1
+		}, public},
+		{"signature of other keys", func(h *Header) {
+			other, _ := testKeys(5)
+			cosign(t, h, other[1:], public, []int{0, 1, 3})
+		}, public},
+		//the bitmap names OAs that did not sign
+		{"other signers", func(h *Header) { h.Signers = []byte{0x07} }, public},
+		{"unknown signer", func(h *Header) { h.Signers = []byte{h.Signers[0] | 0x10} }, public},
+		{"short bitmap", func(h *Header) { h.Signers = nil }, public},
+		{"no signature", func(h *Header) { h.CoSign = nil }, public},
+		{"other block", func(h *Header) { h.K0++ }, public},
+		{"no keys", func(h *Header) {}, nil},
+		{"other order of keys", func(h *Header) {}, []kyber.Point{public[0], public[1], public[3], public[2]}},
+		{"mined block", func(h *Header) { h.Mode = POW }, public},
+	} {
+		h := signed
+		h.CoSign = append([]byte{}, signed.CoSign...)
+		h.Signers = append([]byte{}, signed.Signers...)
+		c.commit(&h)
+		if err := h.CheckCommit(c.keys); err == nil {
+			t.Fatalf("%s: the commit verifies", c.name)
+		}
+	}
+	if !bytes.Equal(signed.Signers, []byte{0x0b}) {
+		t.Fatalf("the signers are %x, want 0b", signed.Signers)
+	}
+}
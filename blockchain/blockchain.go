@@ -3,7 +3,6 @@ package blockchain
 import (
 	"NPTM/util"
 	"bytes" //提供了用于操作字节切片 ([]byte) 的函数。它在处理字符串、数据缓冲区、以及二进制数据时非常有用。bytes 包中的许多函数与 strings 包中的函数类似，但它们处理的是字节切片而不是字符串。
-	"crypto/md5"
 	"encoding/gob"
 	"fmt"
 	"math/big"  /*提供了任意精度的整数、浮点数和有理数的基本运算。该包中的数据类型和函数允许对大于 int64 或 float64 类型所能表示的数值进行精确计算。主要的类型和功能包括：
@@ -12,26 +11,38 @@ import (
 		Float：任意精度的浮点数。 */
 
 	"github.com/izqui/helpers"      //提供了一些常用的实用函数。这些函数涵盖了多种功能，主要集中在处理加密哈希、编码、随机数生成等方面
-	"github.com/xsleonard/go-merkle"     //用于构建和操作 Merkle 树的 Go 语言库
 )
 
 //go run main.go AccessPoint.go CloudServiceProvider.go NetworkNode.go OperatorAgent.go
+//defaults of the protocol section of the config
 const (
-	TAG      = "000fffffffffffffffffffffffffffffeecfae81b1b9b3c908810b10a1b56001" //A basic difficulty.
-	Nbr      = 10                                                                 //The threshold of the number of blocks that a miner has created.
-	Ntr      = 50                                                                 //The threshold number of APs whose provided trust-related data in a block
-	Theta    = 1500                                                               //recieve window  //监听多久之后，阻塞进程
-	INTERVAL = 750                                                                //time interval
+	TAG            = "000fffffffffffffffffffffffffffffeecfae81b1b9b3c908810b10a1b56001" //A basic difficulty.
+	Nbr            = 10                                                                 //The threshold of the number of blocks that a miner has created.
+	Ntr            = 50                                                                 //The threshold number of APs whose provided trust-related data in a block
+	Theta          = 1500                                                               //recieve window  //监听多久之后，阻塞进程
+	INTERVAL       = 750                                                                //time interval
+	BLOCK_INTERVAL = 30000                                                              //time between two mined blocks the difficulty aims at, in milliseconds
+	WINDOW         = 8                                                                  //number of block intervals the difficulty is retargeted over
 )
 
 type BlockChain struct {
-	Blocks []*Block //the array of the set of his block's pointer
+	Blocks []*Block //the array of the set of his block's pointer, the canonical chain of the tree
+
+	//position of every block in Blocks by K0 and by hash
+	byK0   map[int64]int
+	byHash map[string]int
+	//every block added, the canonical chain and the side branches, by hash
+	tree map[string]*Block
+	//the file the blocks are appended to, nil keeps the chain in memory
+	store *store
 }
 
 //set Block's listm to type byte   //区块结构
 type Block struct {
 	K0        int64           //块序号
-	Timestamp int64
+	Timestamp int64           //creation time in milliseconds since the Unix epoch  //创建时间（毫秒）
+	Nonce     int64           //the nonce of the work, 0 for blocks that are not mined
+	Target    []byte          //the target of the work before the fairness term, mined PoW blocks only  //难度目标
 	PreHash   []byte
 
 	//the body data
@@ -42,9 +53,20 @@ type Block struct {
 	MerkelRoot0 []byte
 	MerkelRoot1 []byte
 	PublicKey   []byte
+	//how the block was agreed on, POW or BFT
+	Mode int64
 	//the list <nym(byte),val(float64)>
 	Nyms []byte                       //切片同时存储了过程值
 	Vals []float64
+
+	//g of the round, list blocks only, MerkelRoot1 covers it
+	G []byte
+	//the creator's signature of BlockHash
+	SignBK []byte
+	//the collective signature of BlockHash by the OAs of a BFT block and the bitmap of the signers,
+	//bit i for the i-th OA of the topology, not covered by BlockHash
+	CoSign  []byte
+	Signers []byte
 }
 
 //返回区块链中的最后一个区块
@@ -57,12 +79,52 @@ func (bc *BlockChain) PreviousBlock() *Block {
 	}
 }
 
-//add the block into blockchain
-func (bc *BlockChain) AddBlock(bl *Block) {
+//add the block into the tree of the blockchain, a stored chain writes it to disk first. The parent
+//must be in the tree, the Reorg tells how the canonical chain changed and is nil if the block
+//went to a side branch or was already known
+func (bc *BlockChain) AddBlock(bl *Block) (*Reorg, error) {
+	if err := bc.canInsert(bl); err != nil {
+		return nil, err
+	}
+	if bc.Known(bl.BlockHash()) {
+		return nil, nil
+	}
+	if bc.store != nil {
+		if err := bc.store.append(bl); err != nil {
+			return nil, err
+		}
+	}
+	return bc.insert(bl)
+}
+
+func (bc *BlockChain) index(bl *Block) {
+	if bc.byK0 == nil {
+		bc.byK0 = make(map[int64]int)
+		bc.byHash = make(map[string]int)
+	}
+	bc.byK0[bl.K0] = len(bc.Blocks)
+	bc.byHash[string(bl.BlockHash())] = len(bc.Blocks)
 	bc.Blocks = append(bc.Blocks, bl)   //append切片增加一项
 }
 
+//BlockByK0 returns the block with serial number K0, nil if the chain has none
+func (bc *BlockChain) BlockByK0(K0 int64) *Block {
+	if i, ok := bc.byK0[K0]; ok {
+		return bc.Blocks[i]
+	}
+	return nil
+}
+
+//BlockByHash returns the block whose BlockHash is hash, nil if the chain has none
+func (bc *BlockChain) BlockByHash(hash []byte) *Block {
+	if i, ok := bc.byHash[string(hash)]; ok {
+		return bc.Blocks[i]
+	}
+	return nil
+}
+
 //计算挖矿的难度，基于给定的参数 TAG、Npk（该单位创建的区块数量）和 Nb（参考编号）。
+//TAG is the target of the block, see Difficulty, Nb/Npk is the fairness term of its creator
 func ComputeDiff(TAG big.Int, Npk int64, Nb int64) []byte {
 	if Npk == 0 {
 		return TAG.Bytes()
@@ -82,20 +144,27 @@ func ToByteBlock(block Block) []byte {
 }
 
 func ByteToBlock(byteBlock []byte) Block {
+	block, err := DecodeBlock(byteBlock)
+	util.CheckErr(err)
+	return block
+}
+
+//DecodeBlock is ByteToBlock for blocks received from the network, a malformed block is reported
+func DecodeBlock(byteBlock []byte) (Block, error) {
 	var block *Block = &Block{}
 	err := gob.NewDecoder(bytes.NewReader(byteBlock)).Decode(block)
-	util.CheckErr(err)
-	return *block
+	return *block, err
 }
 
 //生成区块链中区块的哈希值
-func SetHash(K int64, PreHash []byte, mr0 []byte, mr1 []byte, pk []byte, t int64) []byte {
+func SetHash(K int64, PreHash []byte, mr0 []byte, mr1 []byte, pk []byte, t int64, nonce int64) []byte {
 	/*K：一个 int64 类型的整数，表示某个值。
 	PreHash：一个字节数组，表示前一个区块的哈希值。
 	mr0：一个字节数组，表示 Merkle 树的根哈希值。
 	mr1：另一个字节数组，表示另一个 Merkle 树的根哈希值。
 	pk：一个字节数组，表示公钥。
 	t：一个 int64 类型的整数，表示时间戳。
+	nonce：工作量证明的随机数。
 	*/
 	
 	//将多个字节切片连接成一个字节切片
@@ -111,6 +180,7 @@ func SetHash(K int64, PreHash []byte, mr0 []byte, mr1 []byte, pk []byte, t int64
 		mr1,
 		pk,
 		util.ToHexInt(t),
+		util.ToHexInt(nonce),
 	},
 		[]byte{},      //第二个参数是一个空字节切片 []byte{}，表示在连接这些字节切片时不使用任何分隔符。
 	)
@@ -118,28 +188,10 @@ func SetHash(K int64, PreHash []byte, mr0 []byte, mr1 []byte, pk []byte, t int64
 	return hash
 }
 
-//set the block's hash value(self)
+//set the block's hash value(self), the hash of its header  //区块哈希即区块头的哈希，列表由 MerkelRoot1 绑定
 func (b *Block) BlockHash() []byte {
-	//first         //将区块的各个字段转换为字节切片并存储在一个二维字节切片 info 中
-	info := [][]byte{
-		util.ToHexInt(b.K0),
-		util.ToHexInt(b.Timestamp),
-		b.PreHash,
-		util.ToHexInt(b.D),
-		util.ToHexInt(b.Nb),
-		util.ToHexInt(b.Npk),
-		util.ToHexInt(b.Nd),
-		b.MerkelRoot0,
-		b.MerkelRoot1,
-		b.PublicKey,
-		b.Nyms,
-	}
-	for i := 0; i < len(b.Vals); i++ {
-		info = append(info, util.Float64ToByte(b.Vals[i]))    //将每个浮点数值转换为字节切片，并添加到 info 数组中
-	}
-
-	hash := helpers.SHA256(bytes.Join(info, []byte{}))   //使用 bytes.Join 函数将 info 数组中的所有字节切片连接成一个大的字节切片，然后对该字节切片进行 SHA256 哈希计算
-	return hash
+	header := b.Header()
+	return header.Hash()
 }
 
 func (b *Block) PrintBlock() {
@@ -148,6 +200,7 @@ func (b *Block) PrintBlock() {
 	fmt.Println("The block's creator is", b.PublicKey)
 	fmt.Println("The block's serial number is:", b.K0)
 	fmt.Println("The block's timestamp is:", b.Timestamp)
+	fmt.Println("The block's nonce is:", b.Nonce)
 	fmt.Println("The block's previous hash is: ", b.PreHash)
 	fmt.Println("The block's obfuscation factor is: ", b.D)
 	fmt.Printf("When this block is created, there is already %d block in the blockchain.\n", b.Nb)
@@ -187,12 +240,8 @@ func BlockWinnnerSelection(block0, block1 *Block) *Block {
 
 //Get the MerkleRoot's hash      
 //Merkle 树是一种树形结构，在区块链和其他数据完整性验证应用中非常常见。它通过将数据分片逐级哈希化，最终得到一个唯一的根哈希值，从而可以高效地验证任何一个数据分片是否属于原始数据集。
+//The leaves and inner nodes are hashed with MD5 behind different prefixes, see merkleLevels
 func GetMerkleRoot(items [][]byte) []byte {
-	tree := merkle.NewTree()
-	err := tree.Generate(items, md5.New())  //生成了一个 Merkle 树。items 是一个二维字节切片，表示要包含在树中的数据分片。md5.New() 指定了用于生成哈希的哈希函数（在这里使用的是 MD5）。
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return tree.Root().Hash  //返回了 Merkle 树的根哈希值。
+	levels := merkleLevels(items)  //生成了一个 Merkle 树，叶子与内部节点使用不同前缀
+	return levels[len(levels)-1][0]  //返回了 Merkle 树的根哈希值。
 }
@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// The target of a mined block follows how long the consensus rounds took: the first blocks are
+// mined against Tag, then every block against the average target of the last Window mined blocks,
+// scaled by the time between the first and the last of them over Window block intervals. A round
+// that took too long makes the next one easier and the other way round. The fairness term of
+// ComputeDiff applies on top of the target.  //根据实际出块间隔调整难度，公平性因子 Nb/Npk 保留
+
+// Difficulty holds the parameters of the retargeting
+type Difficulty struct {
+	Tag      string //target of the first window, hex
+	Interval int64  //time between two mined blocks it aims at, in milliseconds
+	Window   int    //number of intervals averaged
+}
+
+// maxTarget bounds the target to the hash space
+var maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// NextTarget is the target of the block mined after the mined blocks, oldest first. Only the last
+// Window+1 of them are used, fewer keep the target at Tag.
+func (d Difficulty) NextTarget(mined []*Header) []byte {
+	var target big.Int
+	if len(mined) < d.Window+1 {
+		target.SetString(d.Tag, 16)
+		return target.Bytes()
+	}
+	mined = mined[len(mined)-d.Window-1:]
+
+	//average target of the window
+	for _, header := range mined[1:] {
+		target.Add(&target, new(big.Int).SetBytes(header.Target))
+	}
+	target.Div(&target, big.NewInt(int64(d.Window)))
+
+	//a window at most 4 times faster or slower than expected, so one window moves the target by 4 at most
+	expected := d.Interval * int64(d.Window)
+	timespan := mined[len(mined)-1].Timestamp - mined[0].Timestamp
+	if timespan < expected/4 {
+		timespan = expected / 4
+	}
+	if timespan > expected*4 {
+		timespan = expected * 4
+	}
+	target.Mul(&target, big.NewInt(timespan))
+	target.Div(&target, big.NewInt(expected))
+
+	if target.Sign() <= 0 {
+		target.SetInt64(1)
+	}
+	if target.Cmp(maxTarget) > 0 {
+		target.Set(maxTarget)
+	}
+	return target.Bytes()
+}
+
+// pow reports whether the header is a mined block with a target
+func (h *Header) pow() bool {
+	return h.Mined() && h.Mode == POW
+}
+
+// Target is the target of the block mined after the block with hash parent, from its branch of the tree
+func (bc *BlockChain) Target(parent []byte, d Difficulty) []byte {
+	var mined []*Header
+	for b := bc.tree[string(parent)]; b != nil && len(mined) < d.Window+1; b = bc.tree[string(b.PreHash)] {
+		header := b.Header()
+		if header.pow() {
+			mined = append([]*Header{&header}, mined...)
+		}
+	}
+	return d.NextTarget(mined)
+}
+
+// CheckTarget checks that a mined PoW block was mined against the target of its branch, the parent
+// must be in the tree. Header.Check makes sure other blocks have no target.
+func (bc *BlockChain) CheckTarget(b *Block, d Difficulty) error {
+	if header := b.Header(); !header.pow() {
+		return nil
+	}
+	if _, ok := bc.tree[string(b.PreHash)]; !ok {
+		return ErrUnknownParent
+	}
+	if target := bc.Target(b.PreHash, d); !bytes.Equal(b.Target, target) {
+		return fmt.Errorf("the target is %x, but the blocks before it give %x", b.Target, target)
+	}
+	return nil
+}
+
+// Target is the target of the header mined after the tip
+func (hc *HeaderChain) Target(d Difficulty) []byte {
+	var mined []*Header
+	for i := len(hc.Headers) - 1; i >= 0 && len(mined) < d.Window+1; i-- {
+		if header := &hc.Headers[i]; header.pow() {
+			mined = append([]*Header{header}, mined...)
+		}
+	}
+	return d.NextTarget(mined)
+}
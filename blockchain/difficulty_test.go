@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+//minedHeaders are the headers of blocks mined at the timestamps against the targets
+func minedHeaders(timestamps []int64, targets []int64) []*Header {
+	headers := make([]*Header, len(timestamps))
+	for i := range headers {
+		headers[i] = &Header{Timestamp: timestamps[i], Target: big.NewInt(targets[i]).Bytes(), MerkelRoot0: []byte{1}}
+	}
+	return headers
+}
+
+func TestNextTarget(t *testing.T) {
+	//a short window of 2 intervals of a second, 2000 ms between the first and the last block are on time
+	d := Difficulty{Tag: "1000", Interval: 1000, Window: 2}
+	huge := new(big.Int).Rsh(maxTarget, 1)
+	for _, c := range []struct {
+		name       string
+		timestamps []int64
+		targets    []int64
+		want       *big.Int
+	}{
+		{"no blocks", nil, nil, big.NewInt(0x1000)},
+		{"short window", []int64{0, 1000}, []int64{800, 800}, big.NewInt(0x1000)},
+		{"on time", []int64{0, 1000, 2000}, []int64{1, 600, 1000}, big.NewInt(800)},
+		{"twice as fast", []int64{0, 500, 1000}, []int64{800, 800, 800}, big.NewInt(400)},
+		{"twice as slow", []int64{0, 2000, 4000}, []int64{800, 800, 800}, big.NewInt(1600)},
+		//a window faster or slower than 4 times moves the target by 4
+		{"ten times as fast", []int64{0, 100, 200}, []int64{800, 800, 800}, big.NewInt(200)},
+		{"at once", []int64{5000, 5000, 5000}, []int64{800, 800, 800}, big.NewInt(200)},
+		{"clock going back", []int64{5000, 3000, 1000}, []int64{800, 800, 800}, big.NewInt(200)},
+		{"ten times as slow", []int64{0, 10000, 20000}, []int64{800, 800, 800}, big.NewInt(3200)},
+		{"exactly 4 times as fast", []int64{0, 250, 500}, []int64{800, 800, 800}, big.NewInt(200)},
+		//only the last 3 blocks count
+		{"old blocks", []int64{0, 1, 10000, 11000, 12000}, []int64{1, 1, 1, 800, 800}, big.NewInt(800)},
+		{"never zero", []int64{0, 0, 0}, []int64{1, 1, 1}, big.NewInt(1)},
+	} {
+		got := new(big.Int).SetBytes(d.NextTarget(minedHeaders(c.timestamps, c.targets)))
+		if got.Cmp(c.want) != 0 {
+			t.Fatalf("%s: target %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	//a slow window can not leave the hash space
+	headers := minedHeaders([]int64{0, 10000, 20000}, []int64{0, 0, 0})
+	for _, header := range headers {
+		header.Target = huge.Bytes()
+	}
+	if got := d.NextTarget(headers); !bytes.Equal(got, maxTarget.Bytes()) {
+		t.Fatalf("target %x, want the largest %x", got, maxTarget.Bytes())
+	}
+}
+
+//Target only counts the mined PoW blocks of the branch of the parent
+func TestTargetBranch(t *testing.T) {
+	d := Difficulty{Tag: "1000", Interval: 1000, Window: 2}
+	bc := &BlockChain{}
+	add := func(parent *Block, k0, timestamp, target int64, mined bool) *Block {
+		b := &Block{K0: k0, Timestamp: timestamp}
+		if parent != nil {
+			b.PreHash, b.Nb = parent.BlockHash(), parent.Nb+1
+		}
+		if mined {
+			b.MerkelRoot0, b.Target = []byte{1}, big.NewInt(target).Bytes()
+		}
+		if _, err := bc.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	genesis := add(nil, 0, 0, 0, false)
+	a := add(genesis, 1, 1000, 800, true)
+	b := add(a, 2, 2000, 800, true)
+	list := add(b, 3, 2500, 0, false)
+	c := add(list, 4, 3000, 800, true)
+	//on time, the list block in between does not count
+	if got := new(big.Int).SetBytes(bc.Target(c.BlockHash(), d)); got.Int64() != 800 {
+		t.Fatalf("target %v on the canonical branch, want 800", got)
+	}
+	//a side branch mined twice as slow from b
+	side := add(b, 3, 5000, 800, true)
+	if got := new(big.Int).SetBytes(bc.Target(side.BlockHash(), d)); got.Int64() != 1600 {
+		t.Fatalf("target %v on the side branch, want 1600", got)
+	}
+	if got := new(big.Int).SetBytes(bc.Target(a.BlockHash(), d)); got.Int64() != 0x1000 {
+		t.Fatalf("target %v before a full window, want the tag", got)
+	}
+
+	//a light client computes the same target from the headers of the canonical chain
+	var hc HeaderChain
+	for _, block := range bc.Blocks {
+		hc.Headers = append(hc.Headers, block.Header())
+	}
+	if !bytes.Equal(hc.Target(d), bc.Target(c.BlockHash(), d)) {
+		t.Fatal("the header chain and the block tree give different targets")
+	}
+}
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/izqui/helpers"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+// Header is the part of a block its hash covers. The list <nym,val> and g are bound to it by
+// MerkelRoot1, so a chain of headers can be checked without the lists.
+type Header struct {
+	K0          int64
+	Timestamp   int64
+	Nonce       int64
+	Target      []byte
+	PreHash     []byte
+	D           int64
+	Nb          int64
+	Npk         int64
+	Nd          int64
+	MerkelRoot0 []byte
+	MerkelRoot1 []byte
+	PublicKey   []byte
+	Mode        int64
+	//the creator's signature of the hash, the collective signature of a BFT block and its signers,
+	//not covered by it
+	SignBK  []byte
+	CoSign  []byte
+	Signers []byte
+}
+
+// Header returns the header of the block
+func (b *Block) Header() Header {
+	return Header{b.K0, b.Timestamp, b.Nonce, b.Target, b.PreHash, b.D, b.Nb, b.Npk, b.Nd,
+		b.MerkelRoot0, b.MerkelRoot1, b.PublicKey, b.Mode, b.SignBK, b.CoSign, b.Signers}
+}
+
+// Hash is the BlockHash of the header's block
+func (h *Header) Hash() []byte {
+	//将区块头的各个字段转换为字节切片后连接并计算 SHA256
+	info := [][]byte{
+		util.ToHexInt(h.K0),
+		util.ToHexInt(h.Timestamp),
+		util.ToHexInt(h.Nonce),
+		h.Target,
+		h.PreHash,
+		util.ToHexInt(h.D),
+		util.ToHexInt(h.Nb),
+		util.ToHexInt(h.Npk),
+		util.ToHexInt(h.Nd),
+		h.MerkelRoot0,
+		h.MerkelRoot1,
+		h.PublicKey,
+		util.ToHexInt(h.Mode),
+	}
+	return helpers.SHA256(bytes.Join(info, []byte{}))
+}
+
+// Mined reports whether the block was mined in a consensus round, mined blocks carry the root
+// of their records while the genesis block and the list blocks have none
+func (h *Header) Mined() bool {
+	return len(h.MerkelRoot0) != 0
+}
+
+// CheckWork reports whether the hash of the block is below what ComputeDiff gives for its target, Npk and Nb
+func (h *Header) CheckWork() bool {
+	var intTarget, intDiff, intHash big.Int
+	intTarget.SetBytes(h.Target)
+	intDiff.SetBytes(ComputeDiff(intTarget, h.Npk, h.Nb))
+	intHash.SetBytes(SetHash(h.K0, h.PreHash, h.MerkelRoot0, h.MerkelRoot1, h.PublicKey, h.Timestamp, h.Nonce))
+	return intHash.Cmp(&intDiff) == -1
+}
+
+// Check verifies the creator's signature and the work of a mined PoW block. The target of the work
+// needs the blocks before it and is checked by CheckTarget, the commit of a BFT block needs the keys
+// of the OAs and is checked by CheckCommit.
+func (h *Header) Check() error {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	creator := suite.Point()
+	if err := creator.UnmarshalBinary(h.PublicKey); err != nil {
+		return errors.New("invalid creator key")
+	}
+	if err := util.SchnorrVerify(suite, h.Hash(), creator, h.SignBK); err != nil {
+		return errors.New("the creator's signature does not verify")
+	}
+	if h.Mode != POW && h.Mode != BFT {
+		return fmt.Errorf("unknown consensus mode %d", h.Mode)
+	}
+	if h.Mode == BFT && !h.Mined() {
+		return errors.New("only the block of a consensus round is agreed on by BFT")
+	}
+	if !h.pow() {
+		if len(h.Target) != 0 {
+			return errors.New("only a mined PoW block has a target")
+		}
+		return nil
+	}
+	if target := new(big.Int).SetBytes(h.Target); target.Sign() == 0 || target.Cmp(maxTarget) > 0 {
+		return errors.New("the target is out of range")
+	}
+	if !h.CheckWork() {
+		return errors.New("the hash is above the difficulty target")
+	}
+	return nil
+}
+
+// CheckTime checks the timestamp against the clocks: it is not before the timestamp of prev and
+// not after now, both up to skew milliseconds. prev is nil for the genesis block.  //容忍时钟偏差的时间戳检查
+func (h *Header) CheckTime(prev *Header, now int64, skew int64) error {
+	if prev != nil && h.Timestamp < prev.Timestamp-skew {
+		return fmt.Errorf("the timestamp %d is before the previous block's %d", h.Timestamp, prev.Timestamp)
+	}
+	if h.Timestamp > now+skew {
+		return fmt.Errorf("the timestamp %d is %d ms in the future", h.Timestamp, h.Timestamp-now)
+	}
+	return nil
+}
+
+// Follows checks that the header is the block at height after prev, prev is nil for the genesis block
+func (h *Header) Follows(prev *Header, height int) error {
+	if prev == nil {
+		if len(h.PreHash) != 0 {
+			return errors.New("the genesis block has a previous hash")
+		}
+	} else {
+		if !bytes.Equal(h.PreHash, prev.Hash()) {
+			return fmt.Errorf("PreHash is not the hash of block %d", height-1)
+		}
+		if h.K0 <= prev.K0 {
+			return fmt.Errorf("K0 does not grow, block %d has K0 %d", height-1, prev.K0)
+		}
+	}
+	if h.Nb != int64(height) {
+		return fmt.Errorf("Nb is %d, but %d blocks precede it", h.Nb, height)
+	}
+	return nil
+}
+
+func ToByteHeader(header Header) []byte {
+	buf := new(bytes.Buffer)
+	gob.NewEncoder(buf).Encode(header)
+	return buf.Bytes()
+}
+
+//DecodeHeader reads a header received from the network, a malformed header is reported
+func DecodeHeader(byteHeader []byte) (Header, error) {
+	var header Header
+	err := gob.NewDecoder(bytes.NewReader(byteHeader)).Decode(&header)
+	return header, err
+}
+
+// HeaderChain is the chain of a light client, the headers of the canonical chain without the lists.
+// A list is checked against its header with MerkelRoot1, a single pair with an InclusionProof.
+type HeaderChain struct {
+	Headers []Header
+}
+
+// Tip is the last header, nil for an empty chain
+func (hc *HeaderChain) Tip() *Header {
+	if len(hc.Headers) == 0 {
+		return nil
+	}
+	return &hc.Headers[len(hc.Headers)-1]
+}
+
+// Append checks the header against the tip, the creator's signature, the target and the work and adds it
+func (hc *HeaderChain) Append(header Header, d Difficulty) error {
+	if err := header.Follows(hc.Tip(), len(hc.Headers)); err != nil {
+		return err
+	}
+	if err := header.Check(); err != nil {
+		return err
+	}
+	if target := hc.Target(d); header.pow() && !bytes.Equal(header.Target, target) {
+		return fmt.Errorf("the target is %x, but the headers before it give %x", header.Target, target)
+	}
+	hc.Headers = append(hc.Headers, header)
+	return nil
+}
@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+// MerkelRoot1 is the root of a tree with one leaf per pair <nym,val> of the list, in the order of
+// the list, and g as the last leaf of a list block. A pair can be shown to be in a block with an
+// inclusion proof, which only needs the header of the block.  //每个 <nym,val> 一个叶子，可单独证明
+
+// The trees hash a leaf as md5(0x00 || item) and an inner node as md5(0x01 || left || right), so no
+// leaf can pass for an inner node. A node without a sibling is passed up unchanged.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+//leafHash is the node of an item
+func leafHash(item []byte) []byte {
+	h := md5.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(item)
+	return h.Sum(nil)
+}
+
+//nodeHash is the parent of two nodes
+func nodeHash(left, right []byte) []byte {
+	h := md5.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+//merkleLevels are the levels of the tree of items from the leaves up to the root, no items have
+//the tree of one empty item
+func merkleLevels(items [][]byte) [][][]byte {
+	if len(items) == 0 {
+		items = [][]byte{{}}
+	}
+	level := make([][]byte, len(items))
+	for i, item := range items {
+		level[i] = leafHash(item)
+	}
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, nodeHash(level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// InclusionProof shows that the pair <Nym,Val> is a leaf of MerkelRoot1 of the block with hash Block
+type InclusionProof struct {
+	Block []byte //hash of the block
+	Nym   []byte //the encoded point
+	Val   float64
+	//position of the leaf and the hashes of its siblings from the leaf up,
+	//empty where the node has no sibling and is passed up unchanged
+	Index int
+	Path  [][]byte
+}
+
+// ListLeaf is the leaf of one pair, the encoded nym followed by the value
+func ListLeaf(nym []byte, val float64) []byte {
+	return bytes.Join([][]byte{nym, util.Float64ToByte(val)}, []byte{})
+}
+
+// ListLeaves are the leaves of MerkelRoot1: one per pair of the encoded list and g if it is not nil
+func ListLeaves(nyms []byte, vals []float64, g []byte) ([][]byte, error) {
+	nymList, err := util.DecodePointList(nyms)
+	if err != nil {
+		return nil, err
+	}
+	if len(nymList) != len(vals) {
+		return nil, fmt.Errorf("%d nyms but %d values", len(nymList), len(vals))
+	}
+	leaves := make([][]byte, 0, len(vals)+1)
+	for i, nym := range nymList {
+		byteNym, err := nym.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, ListLeaf(byteNym, vals[i]))
+	}
+	if g != nil {
+		leaves = append(leaves, g)
+	}
+	if len(leaves) == 0 {
+		//an empty list without g still has a root
+		leaves = append(leaves, []byte{})
+	}
+	return leaves, nil
+}
+
+// ListRoot is MerkelRoot1 of the encoded list and g, g is nil for a mined block
+func ListRoot(nyms []byte, vals []float64, g []byte) ([]byte, error) {
+	leaves, err := ListLeaves(nyms, vals, g)
+	if err != nil {
+		return nil, err
+	}
+	return GetMerkleRoot(leaves), nil
+}
+
+// Prove returns the inclusion proof of the encoded nym in the list of the block
+func (b *Block) Prove(nym []byte) (*InclusionProof, error) {
+	leaves, err := ListLeaves(b.Nyms, b.Vals, b.G)
+	if err != nil {
+		return nil, err
+	}
+	index := -1
+	for i := range b.Vals {
+		if bytes.Equal(leaves[i][:len(leaves[i])-8], nym) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("the nym is not in the list of the block")
+	}
+
+	levels := merkleLevels(leaves)
+	proof := &InclusionProof{Block: b.BlockHash(), Nym: nym, Val: b.Vals[index], Index: index}
+	position := index
+	for _, nodes := range levels[:len(levels)-1] {
+		var sibling []byte
+		if position^1 < len(nodes) {
+			sibling = nodes[position^1]
+		}
+		proof.Path = append(proof.Path, sibling)
+		position /= 2
+	}
+	return proof, nil
+}
+
+// Prove returns the inclusion proof of the encoded nym in the block with this hash
+func (bc *BlockChain) Prove(hash []byte, nym []byte) (*InclusionProof, error) {
+	block, ok := bc.tree[string(hash)]
+	if !ok {
+		return nil, errors.New("unknown block")
+	}
+	return block.Prove(nym)
+}
+
+// Verify checks the proof against the header of its block, the header itself is checked by the caller
+func (p *InclusionProof) Verify(header *Header) error {
+	if !bytes.Equal(header.Hash(), p.Block) {
+		return errors.New("the proof is for another block")
+	}
+	//the nym is a point, so the leaf is a pair and not g
+	if err := edwards25519.NewBlakeSHA256Ed25519().Point().UnmarshalBinary(p.Nym); err != nil {
+		return fmt.Errorf("the nym is not a point: %v", err)
+	}
+	node := leafHash(ListLeaf(p.Nym, p.Val))
+	position := p.Index
+	for _, sibling := range p.Path {
+		if len(sibling) != 0 {
+			if position%2 == 0 {
+				node = nodeHash(node, sibling)
+			} else {
+				node = nodeHash(sibling, node)
+			}
+		}
+		position /= 2
+	}
+	if position != 0 || !bytes.Equal(node, header.MerkelRoot1) {
+		return errors.New("the pair is not in MerkelRoot1 of the block")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"bytes"
+	"strconv"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//listBlock is a block with a list of k pairs, and g if withG is set
+func listBlock(t *testing.T, k int, withG bool) (*Block, [][]byte) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	nyms := make([]kyber.Point, k)
+	byteNyms := make([][]byte, k)
+	vals := make([]float64, k)
+	for i := range nyms {
+		nyms[i] = suite.Point().Pick(suite.XOF([]byte("nym" + strconv.Itoa(i))))
+		byteNyms[i], _ = nyms[i].MarshalBinary()
+		vals[i] = float64(i) / 10
+	}
+	block := &Block{K0: 1, Nyms: util.ProtobufEncodePointList(nyms), Vals: vals}
+	if withG {
+		block.G, _ = suite.Point().Pick(suite.XOF([]byte("g"))).MarshalBinary()
+	}
+	root, err := ListRoot(block.Nyms, block.Vals, block.G)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.MerkelRoot1 = root
+	return block, byteNyms
+}
+
+func TestInclusionProof(t *testing.T) {
+	for k := 1; k <= 9; k++ {
+		for _, withG := range []bool{false, true} {
+			block, nyms := listBlock(t, k, withG)
+			header := block.Header()
+			passedUp := false
+			for i, nym := range nyms {
+				proof, err := block.Prove(nym)
+				if err != nil {
+					t.Fatalf("k %d g %v nym %d: %v", k, withG, i, err)
+				}
+				if proof.Index != i || proof.Val != block.Vals[i] {
+					t.Fatalf("k %d g %v nym %d: the proof is for leaf %d", k, withG, i, proof.Index)
+				}
+				if err := proof.Verify(&header); err != nil {
+					t.Fatalf("k %d g %v nym %d: %v", k, withG, i, err)
+				}
+				for _, sibling := range proof.Path {
+					passedUp = passedUp || len(sibling) == 0
+				}
+			}
+			//the last nym of an odd number of leaves has no sibling on the first level
+			if odd := !withG && k > 1 && k%2 == 1; odd && !passedUp {
+				t.Fatalf("k %d: no proof passes a node without a sibling up", k)
+			}
+		}
+	}
+}
+
+func TestInclusionProofRejected(t *testing.T) {
+	block, nyms := listBlock(t, 5, true)
+	header := block.Header()
+	other, _ := listBlock(t, 4, false)
+	otherHeader := other.Header()
+	if _, err := block.Prove([]byte("unknown")); err == nil {
+		t.Fatal("a nym that is not in the list is proven")
+	}
+	for name, tamper := range map[string]func(p *InclusionProof) *Header{
+		"other value":   func(p *InclusionProof) *Header { p.Val += 0.1; return &header },
+		"other index":   func(p *InclusionProof) *Header { p.Index ^= 1; return &header },
+		"other nym":     func(p *InclusionProof) *Header { p.Nym = nyms[0]; return &header },
+		"other sibling": func(p *InclusionProof) *Header { p.Path[1] = p.Path[0]; return &header },
+		"short path":    func(p *InclusionProof) *Header { p.Path = p.Path[1:]; return &header },
+		"other block":   func(p *InclusionProof) *Header { return &otherHeader },
+		"other root": func(p *InclusionProof) *Header {
+			h := header
+			h.MerkelRoot1 = otherHeader.MerkelRoot1
+			return &h
+		},
+	} {
+		proof, err := block.Prove(nyms[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proof.Verify(tamper(proof)); err == nil {
+			t.Fatalf("%s: the proof verifies", name)
+		}
+	}
+}
+
+// an inner node of 32 bytes split into a 24 byte nym and a value is not a leaf
+func TestInclusionProofInnerNode(t *testing.T) {
+	block, nyms := listBlock(t, 4, false)
+	header := block.Header()
+	proof, err := block.Prove(nyms[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves, _ := ListLeaves(block.Nyms, block.Vals, nil)
+	levels := merkleLevels(leaves)
+	inner := append(append([]byte{}, levels[0][0]...), levels[0][1]...)
+	if !bytes.Equal(nodeHash(levels[0][0], levels[0][1]), levels[1][0]) {
+		t.Fatal("the first inner node is not the parent of the first two leaves")
+	}
+	forged := &InclusionProof{Block: proof.Block, Nym: inner[:24], Val: util.ByteToFloat64(inner[24:]),
+		Index: 0, Path: proof.Path[1:]}
+	if err := forged.Verify(&header); err == nil {
+		t.Fatal("an inner node verifies as a pair")
+	}
+	//the prefixes alone keep it out too
+	if bytes.Equal(leafHash(inner), levels[1][0]) {
+		t.Fatal("a leaf hashes like the inner node of its content")
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	if !bytes.Equal(GetMerkleRoot([][]byte{a}), leafHash(a)) {
+		t.Fatal("the root of one item is not its leaf")
+	}
+	want := nodeHash(nodeHash(leafHash(a), leafHash(b)), leafHash(c))
+	if !bytes.Equal(GetMerkleRoot([][]byte{a, b, c}), want) {
+		t.Fatal("the root of three items is not md5(1 || md5(1 || a || b) || c)")
+	}
+	if !bytes.Equal(GetMerkleRoot(nil), leafHash(nil)) {
+		t.Fatal("no items do not have the root of one empty item")
+	}
+}
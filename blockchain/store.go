@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The store is an append-only file of records (see util.AppendRecord), one gob encoded block for
+// every block of the tree. Every record is synced before AddBlock returns. A crash can only leave the
+// last record incomplete, Open cuts such a record off. A damaged record before the last one is reported.
+
+// ErrCorruptRecord marks a record whose length or checksum does not match its content
+var ErrCorruptRecord = util.ErrCorruptRecord
+
+type store struct {
+	file *os.File
+}
+
+// Open loads the chain stored at path, the file and its directory are created if missing.
+// Blocks added to the returned chain are appended to the file.
+func Open(path string) (*BlockChain, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	bc := &BlockChain{}
+	valid, err := readBlocks(file, bc)
+	if err != nil && !errors.Is(err, ErrCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		file.Close()
+		return nil, err
+	}
+	if err != nil {
+		if torn, terr := util.TornTail(file, valid, err); terr != nil || !torn {
+			file.Close()
+			if terr != nil {
+				return nil, terr
+			}
+			return nil, fmt.Errorf("%s after block %d at offset %d: %w", path, len(bc.Blocks), valid, err)
+		}
+		//drop the partially written tail block  //截断崩溃时未写完的尾部区块
+		fmt.Println("[BlockChain] Truncate", path, "after block", len(bc.Blocks), "at offset", valid, ":", err)
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	bc.store = &store{file}
+	return bc, nil
+}
+
+// Load reads the chain stored at path without changing the file, a damaged tail is reported
+func Load(path string) (*BlockChain, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	bc := &BlockChain{}
+	if _, err := readBlocks(file, bc); err != nil {
+		return bc, fmt.Errorf("%s after block %d: %w", path, len(bc.Blocks), err)
+	}
+	return bc, nil
+}
+
+// Close closes the file of a stored chain, the chain stays usable in memory
+func (bc *BlockChain) Close() error {
+	if bc.store == nil {
+		return nil
+	}
+	err := bc.store.file.Close()
+	bc.store = nil
+	return err
+}
+
+//readBlocks adds the records of r to bc and returns the offset after the last complete one
+func readBlocks(r io.Reader, bc *BlockChain) (int64, error) {
+	var offset int64
+	for {
+		data, err := util.ReadRecord(r)
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		block, err := DecodeBlock(data)
+		if err != nil {
+			return offset, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+		}
+		//the blocks are stored in the order they were added, so every parent comes first
+		if _, err := bc.insert(&block); err != nil {
+			return offset, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+		}
+		offset += util.RecordHeaderSize + int64(len(data))
+	}
+}
+
+//append writes one record and syncs it
+func (s *store) append(block *Block) error {
+	return util.AppendRecord(s.file, ToByteBlock(*block))
+}
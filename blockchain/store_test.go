@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//storeChain writes a chain of n blocks to a new store and returns its path and the offset of every record
+func storeChain(t *testing.T, n int) (string, []int64) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	var offsets []int64
+	var prev []byte
+	for i := 0; i < n; i++ {
+		info, _ := os.Stat(path)
+		offsets = append(offsets, info.Size())
+		block := &Block{K0: int64(i), Nb: int64(i), PreHash: prev, Nyms: []byte{byte(i)}}
+		if _, err := bc.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		prev = block.BlockHash()
+	}
+	return path, offsets
+}
+
+func damage(t *testing.T, path string, offset int64, b []byte) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//flip inverts the byte at offset
+func flip(t *testing.T, path string, offset int64) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damage(t, path, offset, []byte{^data[offset]})
+}
+
+func TestStoreReload(t *testing.T) {
+	path, _ := storeChain(t, 3)
+	bc, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	if len(bc.Blocks) != 3 {
+		t.Fatalf("%d blocks reloaded, want 3", len(bc.Blocks))
+	}
+}
+
+//a crash while appending leaves a short or garbled last record, Open cuts it off
+func TestStoreTornTail(t *testing.T) {
+	for _, name := range []string{"short", "checksum"} {
+		path, offsets := storeChain(t, 3)
+		switch name {
+		case "short":
+			info, _ := os.Stat(path)
+			os.Truncate(path, info.Size()-3)
+		case "checksum":
+			flip(t, path, offsets[2]+util.RecordHeaderSize+5)
+		}
+		bc, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(bc.Blocks) != 2 {
+			t.Fatalf("%s: %d blocks reloaded, want 2", name, len(bc.Blocks))
+		}
+		bc.Close()
+		if info, _ := os.Stat(path); info.Size() != offsets[2] {
+			t.Fatalf("%s: the file was cut at %d, want %d", name, info.Size(), offsets[2])
+		}
+	}
+}
+
+//a damaged record that is not the last one is reported and the file is left as it is
+func TestStoreCorruptRecord(t *testing.T) {
+	for _, name := range []string{"checksum", "length", "huge length"} {
+		path, offsets := storeChain(t, 3)
+		before, _ := os.Stat(path)
+		switch name {
+		case "checksum":
+			flip(t, path, offsets[1]+util.RecordHeaderSize+5)
+		case "length":
+			length := make([]byte, 4)
+			binary.BigEndian.PutUint32(length, uint32(offsets[2]-offsets[1]-util.RecordHeaderSize-1))
+			damage(t, path, offsets[1], length)
+		case "huge length":
+			damage(t, path, offsets[1], []byte{0xff, 0xff, 0xff, 0xff})
+		}
+		if _, err := Open(path); !errors.Is(err, ErrCorruptRecord) {
+			t.Fatalf("%s: got %v, want ErrCorruptRecord", name, err)
+		}
+		if after, _ := os.Stat(path); after.Size() != before.Size() {
+			t.Fatalf("%s: the file was cut", name)
+		}
+	}
+}
+
+func TestStoreAppendError(t *testing.T) {
+	bc, err := Open(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.store.file.Close()
+	if _, err := bc.AddBlock(&Block{}); err == nil {
+		t.Fatal("a block that was not stored was added")
+	}
+	if len(bc.Blocks) != 0 {
+		t.Fatal("the block is in the chain")
+	}
+}
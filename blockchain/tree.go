@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+)
+
+// The chain keeps every valid block it is given in a tree of branches. Blocks is the canonical
+// branch, the one whose tip the fork choice prefers: the longest branch, and between branches of
+// the same length the one whose first block after the fork point wins BlockWinnnerSelection
+// (timestamp, Npk, Nd, hash). The rule only depends on the blocks, so OAs holding the same blocks
+// agree on the canonical chain whatever order the blocks arrived in.  //区块树与确定性的分叉选择规则
+
+// ErrUnknownParent marks a block whose previous block is not in the tree
+var ErrUnknownParent = errors.New("the previous block is unknown")
+
+// Reorg lists how AddBlock changed the canonical chain: the blocks that left it, from the old tip
+// back to the fork point, and the blocks that joined it, from the fork point to the new tip.
+// Extending the tip removes nothing.
+type Reorg struct {
+	Removed []*Block
+	Added   []*Block
+}
+
+// Known reports whether the block with this hash is in the tree, on any branch
+func (bc *BlockChain) Known(hash []byte) bool {
+	_, ok := bc.tree[string(hash)]
+	return ok
+}
+
+// Lookup returns the block with this hash on any branch, nil if it is not in the tree
+func (bc *BlockChain) Lookup(hash []byte) *Block {
+	return bc.tree[string(hash)]
+}
+
+// Size is the number of blocks in the tree, the side branches included
+func (bc *BlockChain) Size() int {
+	return len(bc.tree)
+}
+
+//insert adds a block whose parent is in the tree and applies the fork choice. A block that is
+//already known changes nothing.
+func (bc *BlockChain) insert(bl *Block) (*Reorg, error) {
+	if err := bc.canInsert(bl); err != nil {
+		return nil, err
+	}
+	hash := string(bl.BlockHash())
+	if _, ok := bc.tree[hash]; ok {
+		return nil, nil
+	}
+	if bc.tree == nil {
+		bc.tree = make(map[string]*Block)
+	}
+	bc.tree[hash] = bl
+
+	tip := bc.PreviousBlock()
+	if tip == nil || bytes.Equal(bl.PreHash, tip.BlockHash()) {
+		//the canonical tip is preferred to every other tip, so is the block extending it
+		bc.index(bl)
+		return &Reorg{Added: []*Block{bl}}, nil
+	}
+	if !bc.prefer(bl, tip) {
+		//a side branch  //侧链
+		return nil, nil
+	}
+	return bc.switchTo(bl), nil
+}
+
+//canInsert checks that the block follows its parent in the tree, a second genesis block is refused
+func (bc *BlockChain) canInsert(bl *Block) error {
+	header := bl.Header()
+	if len(bl.PreHash) == 0 {
+		if len(bc.Blocks) != 0 && !bytes.Equal(bl.BlockHash(), bc.Blocks[0].BlockHash()) {
+			return errors.New("the chain already has another genesis block")
+		}
+		return header.Follows(nil, 0)
+	}
+	parent, ok := bc.tree[string(bl.PreHash)]
+	if !ok {
+		return ErrUnknownParent
+	}
+	parentHeader := parent.Header()
+	return header.Follows(&parentHeader, int(parent.Nb)+1)
+}
+
+//prefer reports whether the fork choice prefers the branch ending in a to the one ending in b
+func (bc *BlockChain) prefer(a, b *Block) bool {
+	if a.Nb != b.Nb {
+		return a.Nb > b.Nb
+	}
+	//same length, compare the first blocks after the fork point
+	for !bytes.Equal(a.PreHash, b.PreHash) {
+		a = bc.tree[string(a.PreHash)]
+		b = bc.tree[string(b.PreHash)]
+	}
+	return BlockWinnnerSelection(a, b) == a
+}
+
+//switchTo makes the branch ending in tip canonical
+func (bc *BlockChain) switchTo(tip *Block) *Reorg {
+	reorg := &Reorg{}
+	//walk back to the fork point, the last block of the branch on the canonical chain
+	block := tip
+	for {
+		if _, ok := bc.byHash[string(block.BlockHash())]; ok {
+			break
+		}
+		reorg.Added = append([]*Block{block}, reorg.Added...)
+		block = bc.tree[string(block.PreHash)]
+	}
+	fork := int(block.Nb)
+	for i := len(bc.Blocks) - 1; i > fork; i-- {
+		removed := bc.Blocks[i]
+		reorg.Removed = append(reorg.Removed, removed)
+		delete(bc.byK0, removed.K0)
+		delete(bc.byHash, string(removed.BlockHash()))
+	}
+	bc.Blocks = bc.Blocks[:fork+1]
+	for _, added := range reorg.Added {
+		bc.index(added)
+	}
+	return reorg
+}
@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+//child is a block after parent
+func child(parent *Block, k0, timestamp int64) *Block {
+	return &Block{K0: k0, Timestamp: timestamp, PreHash: parent.BlockHash(), Nb: parent.Nb + 1}
+}
+
+func addBlocks(t *testing.T, bc *BlockChain, blocks ...*Block) *Reorg {
+	var reorg *Reorg
+	for _, b := range blocks {
+		var err error
+		if reorg, err = bc.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return reorg
+}
+
+func sameBlocks(got, want []*Block) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !bytes.Equal(got[i].BlockHash(), want[i].BlockHash()) {
+			return false
+		}
+	}
+	return true
+}
+
+//a side branch becomes canonical once it is longer than the chain
+func TestSideBranchOvertakes(t *testing.T) {
+	bc := &BlockChain{}
+	genesis := &Block{K0: 0, Timestamp: 1000}
+	a := child(genesis, 1, 2000)
+	b := child(a, 2, 3000)
+	addBlocks(t, bc, genesis, a, b)
+
+	//c loses the tie against b with its later timestamp
+	c := child(a, 2, 3500)
+	if reorg := addBlocks(t, bc, c); reorg != nil || bc.PreviousBlock() != b {
+		t.Fatal("a side block of the same height replaced the tip")
+	}
+	if !bc.Known(c.BlockHash()) || bc.BlockByHash(c.BlockHash()) != nil || bc.Size() != 4 {
+		t.Fatal("the side block is not kept off the canonical chain")
+	}
+
+	d := child(c, 3, 4000)
+	reorg := addBlocks(t, bc, d)
+	if reorg == nil || !sameBlocks(reorg.Removed, []*Block{b}) || !sameBlocks(reorg.Added, []*Block{c, d}) {
+		t.Fatalf("reorg %+v, want b removed and c, d added", reorg)
+	}
+	if !sameBlocks(bc.Blocks, []*Block{genesis, a, c, d}) {
+		t.Fatal("the longer branch is not canonical")
+	}
+	if bc.BlockByHash(b.BlockHash()) != nil || bc.BlockByK0(2) != c || bc.BlockByK0(3) != d {
+		t.Fatal("the index still holds the old branch")
+	}
+
+	//b extended ties d, and b is before c at the fork point
+	e := child(b, 3, 4500)
+	reorg = addBlocks(t, bc, e)
+	if reorg == nil || !sameBlocks(reorg.Removed, []*Block{d, c}) || !sameBlocks(reorg.Added, []*Block{b, e}) {
+		t.Fatalf("reorg %+v, want d, c removed and b, e added", reorg)
+	}
+	if !sameBlocks(bc.Blocks, []*Block{genesis, a, b, e}) || bc.Size() != 6 {
+		t.Fatal("the tie is not broken at the fork point")
+	}
+}
+
+//between two branches of the same length the first blocks after the fork point decide, in any order
+func TestTieBreak(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		winner, loser func(b *Block)
+	}{
+		{"earlier timestamp", func(b *Block) { b.Timestamp = 2000 }, func(b *Block) { b.Timestamp = 2001 }},
+		{"fewer blocks of its creator", func(b *Block) { b.Npk = 1 }, func(b *Block) { b.Npk = 2 }},
+		{"more data", func(b *Block) { b.Nd = 5 }, func(b *Block) { b.Nd = 4 }},
+	} {
+		for _, winnerFirst := range []bool{true, false} {
+			bc := &BlockChain{}
+			genesis := &Block{K0: 0, Timestamp: 1000}
+			winner, loser := child(genesis, 1, 2000), child(genesis, 1, 2000)
+			c.winner(winner)
+			c.loser(loser)
+			//the loser's branch is not shorter, its later blocks do not matter
+			loserTip := child(loser, 2, 1500)
+			winnerTip := child(winner, 2, 9000)
+			if BlockWinnnerSelection(winner, loser) != winner {
+				t.Fatalf("%s: BlockWinnnerSelection picks the other block", c.name)
+			}
+			if winnerFirst {
+				addBlocks(t, bc, genesis, winner, winnerTip, loser, loserTip)
+			} else {
+				addBlocks(t, bc, genesis, loser, loserTip, winner, winnerTip)
+			}
+			if !sameBlocks(bc.Blocks, []*Block{genesis, winner, winnerTip}) {
+				t.Fatalf("%s, winner first %v: the other branch is canonical", c.name, winnerFirst)
+			}
+		}
+	}
+
+	//all else equal the smaller hash wins
+	genesis := &Block{K0: 0, Timestamp: 1000}
+	x, y := child(genesis, 1, 2000), child(genesis, 2, 2000)
+	winner := BlockWinnnerSelection(x, y)
+	if bytes.Compare(winner.BlockHash(), x.BlockHash()) > 0 || bytes.Compare(winner.BlockHash(), y.BlockHash()) > 0 {
+		t.Fatal("the block with the larger hash wins")
+	}
+	for _, order := range [][]*Block{{x, y}, {y, x}} {
+		bc := &BlockChain{}
+		addBlocks(t, bc, genesis, order[0], order[1])
+		if bc.PreviousBlock() != winner {
+			t.Fatal("the tip depends on the order the blocks arrived in")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ValidationError reports the first invalid block of a chain
+type ValidationError struct {
+	Index  int //position in Blocks
+	K0     int64
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("block %d (K0 %d): %s", e.Index, e.K0, e.Reason)
+}
+
+// Mined reports whether the block was mined in a consensus round, see Header.Mined
+func (b *Block) Mined() bool {
+	return len(b.MerkelRoot0) != 0
+}
+
+// Check validates what a block proves on its own: the creator's signature, the work of a mined
+// block and MerkelRoot1. MerkelRoot0 covers the records of the round, they are not kept in the block.
+func (b *Block) Check() error {
+	header := b.Header()
+	if err := header.Check(); err != nil {
+		return err
+	}
+	root, err := ListRoot(b.Nyms, b.Vals, b.G)
+	if err != nil {
+		return fmt.Errorf("invalid list: %v", err)
+	}
+	if !bytes.Equal(root, b.MerkelRoot1) {
+		return errors.New("MerkelRoot1 is not the root of the block's list")
+	}
+	return nil
+}
+
+// Validate walks the canonical chain from the genesis block and checks the PreHash links, the growing K0,
+// Nb and Npk of every block, the target of mined blocks and Check of the block itself, then the blocks
+// of the side branches. The error is a *ValidationError for the first invalid block.
+func (bc *BlockChain) Validate(d Difficulty) error {
+	//mined blocks of every creator so far, Npk of the next one
+	mined := make(map[string]int64)
+	var prev *Header
+	for i, b := range bc.Blocks {
+		fail := func(format string, a ...interface{}) error {
+			return &ValidationError{i, b.K0, fmt.Sprintf(format, a...)}
+		}
+		header := b.Header()
+		if err := header.Follows(prev, i); err != nil {
+			return fail("%v", err)
+		}
+		if b.Mined() {
+			if b.Npk != mined[string(b.PublicKey)] {
+				return fail("Npk is %d, but its creator mined %d blocks before", b.Npk, mined[string(b.PublicKey)])
+			}
+			mined[string(b.PublicKey)]++
+		}
+		if err := b.Check(); err != nil {
+			return fail("%v", err)
+		}
+		if err := bc.CheckTarget(b, d); err != nil {
+			return fail("%v", err)
+		}
+		prev = &header
+	}
+
+	//the side branches hold valid blocks that follow their parents too  //侧链上的区块同样要有效
+	for _, b := range bc.sideBlocks() {
+		err := bc.canInsert(b)
+		if err == nil {
+			err = b.Check()
+		}
+		if err == nil {
+			err = bc.CheckTarget(b, d)
+		}
+		if err != nil {
+			return &ValidationError{int(b.Nb), b.K0, fmt.Sprintf("side branch: %v", err)}
+		}
+	}
+	return nil
+}
+
+// CheckTimes checks the timestamp of every block of the tree against its parent and the local clock
+// now, up to skew milliseconds, see Header.CheckTime. The error is a *ValidationError for the first
+// block out of bounds.
+func (bc *BlockChain) CheckTimes(now int64, skew int64) error {
+	for _, b := range append(append([]*Block{}, bc.Blocks...), bc.sideBlocks()...) {
+		var prev *Header
+		if parent, ok := bc.tree[string(b.PreHash)]; ok {
+			parentHeader := parent.Header()
+			prev = &parentHeader
+		}
+		header := b.Header()
+		if err := header.CheckTime(prev, now, skew); err != nil {
+			return &ValidationError{int(b.Nb), b.K0, err.Error()}
+		}
+	}
+	return nil
+}
+
+//sideBlocks are the blocks of the tree off the canonical chain, by height and hash
+func (bc *BlockChain) sideBlocks() []*Block {
+	var side []*Block
+	for hash, b := range bc.tree {
+		if _, ok := bc.byHash[hash]; !ok {
+			side = append(side, b)
+		}
+	}
+	sort.Slice(side, func(i, j int) bool {
+		if side[i].Nb != side[j].Nb {
+			return side[i].Nb < side[j].Nb
+		}
+		return bytes.Compare(side[i].BlockHash(), side[j].BlockHash()) < 0
+	})
+	return side
+}
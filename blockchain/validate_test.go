@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"NPTM/util"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//an easy tag, every second hash is below it
+const testTag = "7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+
+//sign signs the block with the creator's key
+func sign(b *Block, private kyber.Scalar) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	b.PublicKey, _ = suite.Point().Mul(private, nil).MarshalBinary()
+	b.SignBK = util.SchnorrSign(suite, suite.RandomStream(), b.BlockHash(), private)
+}
+
+//validChain is a chain of a genesis list block, a block mined against testTag and a list block
+func validChain(t *testing.T, d Difficulty) *BlockChain {
+	private, _ := testKeys(1)
+	bc := &BlockChain{}
+	genesis, _ := listBlock(t, 3, false)
+	genesis.K0, genesis.Timestamp = 0, 1000
+	sign(genesis, private[0])
+
+	mined := &Block{K0: 1, Timestamp: 2000, PreHash: genesis.BlockHash(), Nb: 1, MerkelRoot0: []byte("records"),
+		MerkelRoot1: genesis.MerkelRoot1, Nyms: genesis.Nyms, Vals: genesis.Vals, Mode: POW}
+	mined.Target = bc.Target(nil, d)
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	mined.PublicKey, _ = suite.Point().Mul(private[0], nil).MarshalBinary()
+	for header := mined.Header(); !header.CheckWork(); header = mined.Header() {
+		mined.Nonce++
+	}
+	sign(mined, private[0])
+
+	list, _ := listBlock(t, 4, true)
+	list.K0, list.Timestamp, list.PreHash, list.Nb = 2, 3000, mined.BlockHash(), 2
+	sign(list, private[0])
+
+	for _, b := range []*Block{genesis, mined, list} {
+		if _, err := bc.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bc
+}
+
+func TestValidate(t *testing.T) {
+	d := Difficulty{Tag: testTag, Interval: 1000, Window: 8}
+	if err := validChain(t, d).Validate(d); err != nil {
+		t.Fatal(err)
+	}
+	private, _ := testKeys(1)
+	for _, c := range []struct {
+		name   string
+		tamper func(bc *BlockChain)
+		index  int
+		reason string
+	}{
+		{"bad PreHash", func(bc *BlockChain) {
+			bc.Blocks[2].PreHash = bc.Blocks[0].BlockHash()
+			sign(bc.Blocks[2], private[0])
+		}, 2, "PreHash is not the hash of block 1"},
+		{"bad signature", func(bc *BlockChain) {
+			bc.Blocks[1].SignBK = append([]byte{}, bc.Blocks[1].SignBK...)
+			bc.Blocks[1].SignBK[len(bc.Blocks[1].SignBK)-1] ^= 1
+		}, 1, "signature does not verify"},
+		{"signature of another block", func(bc *BlockChain) { bc.Blocks[2].SignBK = bc.Blocks[0].SignBK }, 2,
+			"signature does not verify"},
+		//the list no longer gives the root its header commits to
+		{"bad MerkelRoot1", func(bc *BlockChain) {
+			bc.Blocks[2].Vals = append([]float64{}, bc.Blocks[2].Vals...)
+			bc.Blocks[2].Vals[1] += 0.5
+		}, 2, "MerkelRoot1"},
+		{"MerkelRoot1 of another list", func(bc *BlockChain) {
+			bc.Blocks[2].MerkelRoot1 = bc.Blocks[0].MerkelRoot1
+			sign(bc.Blocks[2], private[0])
+		}, 2, "MerkelRoot1"},
+	} {
+		bc := validChain(t, d)
+		c.tamper(bc)
+		err := bc.Validate(d)
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) || invalid.Index != c.index || !strings.Contains(invalid.Reason, c.reason) {
+			t.Fatalf("%s: got %v, want block %d: %s", c.name, err, c.index, c.reason)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"NPTM/ap"
+	"NPTM/config"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+//go run ./cmd/ap
+func main() {
+
+	fmt.Println("[AP] AccessPoint started!")
+
+	//flags override the config, with -oa the AP starts without a prompt
+	configPath := flag.String("config", config.DefaultPath, "configuration file")
+	listen := flag.String("listen", "", "listen address (default: first free port from ap_ip:ap_port)")
+	csp := flag.String("csp", "", "address of the CSP (default: csp_ip:csp_port)")
+	oa := flag.String("oa", "", "address of the OperatorAgent that deploys this AP (default: ask on stdin)")
+	rounds := flag.Int("rounds", 0, "number of list maintenance rounds (default: protocol.list_maintenance_rounds)")
+	flag.Parse()
+
+	//read and check the config before anything starts
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("[AP] ", err)
+	}
+	if *rounds == 0 {
+		*rounds = conf.Protocol.ListMaintenanceRounds
+	}
+
+	//get OA's ip address
+	UpperOAStr := *oa
+	if UpperOAStr == "" {
+		fmt.Print("[AP] Please enter the IP address of the OperatorAgent: ")
+		reader := bufio.NewReader(os.Stdin)
+		ipdata, _, err := reader.ReadLine()
+		if err == nil {
+			fmt.Println("[AP] Enter success!")
+		}
+		UpperOAStr = string(ipdata)
+	}
+
+	accessPoint, err := ap.New(conf, *listen, *csp, UpperOAStr)
+	if err != nil {
+		log.Fatal("[AP] ", err)
+	}
+	accessPoint.Start()
+	accessPoint.Run(*rounds)
+
+	fmt.Println("[AP] System exit...")
+	accessPoint.Stop()
+}
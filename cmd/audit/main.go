@@ -0,0 +1,125 @@
+package main
+
+import (
+	"NPTM/blockchain"
+	"NPTM/config"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+//go run ./cmd/audit [chain files]
+//离线审计：从创世区块开始校验每个 OA 存储的区块链
+func main() {
+	configPath := flag.String("config", config.DefaultPath, "configuration file, gives the difficulty and the chains of the topology")
+	tag := flag.String("tag", "", "target of the first mined blocks in hex (default: protocol.tag of the config)")
+	verbose := flag.Bool("v", false, "print every block")
+	nym := flag.String("nym", "", "hex encoded pseudonym, prove and verify its latest value in every chain")
+	flag.Parse()
+
+	paths := flag.Args()
+	//the keys the topology pins check the commits of BFT blocks, a BFT block fails without them
+	var keys []kyber.Point
+	//timestamps may be ahead of the local clock by the skew
+	skew := int64(config.Default().Protocol.ClockSkew)
+	difficulty := config.Default().Difficulty()
+	if *tag == "" || len(paths) == 0 {
+		conf, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatal("[AUDIT] ", err)
+		}
+		keys = conf.OAKeys()
+		skew = int64(conf.Protocol.ClockSkew)
+		difficulty = conf.Difficulty()
+		if len(paths) == 0 {
+			//the block stores of all OAs in the topology
+			for _, addr := range conf.OAAddrs() {
+				if path := conf.ChainPath(addr); path != "" {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	if *tag != "" {
+		difficulty.Tag = *tag
+	}
+	byteNym, err := hex.DecodeString(*nym)
+	if err != nil {
+		log.Fatal("[AUDIT] -nym: ", err)
+	}
+	if len(paths) == 0 {
+		log.Fatal("[AUDIT] No chain to audit, name the files or set data_dir in the config.")
+	}
+
+	failed := false
+	for _, path := range paths {
+		//Load does not change the file, a cut off tail is reported as well
+		bc, err := blockchain.Load(path)
+		if err == nil {
+			err = bc.Validate(difficulty)
+		}
+		if err == nil {
+			err = bc.CheckTimes(time.Now().UnixMilli(), skew)
+		}
+		if err == nil {
+			err = bc.CheckCommits(keys)
+		}
+		if *verbose && bc != nil {
+			for _, block := range bc.Blocks {
+				fmt.Printf("[AUDIT] %s K0 %d time %s hash %x mined %v mode %d target %x Nd %d\n", path, block.K0,
+					time.UnixMilli(block.Timestamp).Format(time.RFC3339), block.BlockHash(), block.Mined(), block.Mode, block.Target, block.Nd)
+			}
+		}
+		if err != nil {
+			fmt.Println("[AUDIT]", path, "is invalid:", err)
+			failed = true
+			continue
+		}
+		fmt.Println("[AUDIT]", path, "is valid,", len(bc.Blocks), "blocks,", bc.Size()-len(bc.Blocks), "on side branches.")
+		if keys == nil && hasBFT(bc) {
+			fmt.Println("[AUDIT]", path, "the commits of the BFT blocks are not checked, the topology pins no keys.")
+		}
+		if len(byteNym) != 0 && !proveNym(path, bc, byteNym) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//proveNym proves the value of the nym in the newest block carrying it and verifies the proof
+//against the block's header only  //只用区块头验证假名信任值的包含证明
+func proveNym(path string, bc *blockchain.BlockChain, nym []byte) bool {
+	for i := len(bc.Blocks) - 1; i >= 0; i-- {
+		block := bc.Blocks[i]
+		proof, err := block.Prove(nym)
+		if err != nil {
+			continue
+		}
+		header := block.Header()
+		if err := proof.Verify(&header); err != nil {
+			fmt.Println("[AUDIT]", path, "proof of the nym in block", block.K0, "does not verify:", err)
+			return false
+		}
+		fmt.Printf("[AUDIT] %s the nym has value %v in block %d, proof of %d hashes verified.\n", path, proof.Val, block.K0, len(proof.Path))
+		return true
+	}
+	fmt.Println("[AUDIT]", path, "no block holds the nym.")
+	return false
+}
+
+//hasBFT reports whether a block of the canonical chain was agreed on by BFT
+func hasBFT(bc *blockchain.BlockChain) bool {
+	for _, block := range bc.Blocks {
+		if block.Mode == blockchain.BFT {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"NPTM/config"
+	"NPTM/csp"
+	"NPTM/util"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+//go run ./cmd/csp -key config/csp.key
+func main() {
+
+	fmt.Println("[CSP] CloudServiceProvider started.")
+	//flags override the config, with -oas the CSP starts without a prompt
+	configPath := flag.String("config", config.DefaultPath, "configuration file")
+	listen := flag.String("listen", "", "listen address (default: csp_ip:csp_port)")
+	rounds := flag.Int("rounds", 0, "number of data sharing rounds (default: list_maintenance_rounds * consensus_rounds)")
+	oas := flag.Int("oas", -1, "start the cycle once this many OAs registered (-1: wait for 'ok' on stdin)")
+	aps := flag.Int("aps", 0, "with -oas, also wait until this many APs registered")
+	keyFile := flag.String("key", "", "file with the hex encoded private key whose public key is csp.public_key")
+	flag.Parse()
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("[CSP] ", err)
+	}
+	if *rounds == 0 {
+		//every consensus round of the OAs requests the data once
+		*rounds = conf.Protocol.ListMaintenanceRounds * conf.Protocol.ConsensusRounds
+	}
+
+	var privateKey kyber.Scalar = nil
+	if *keyFile != "" {
+		privateKey, err = util.ReadPrivateKey(*keyFile)
+		if err != nil {
+			log.Fatal("[CSP] Private key ", *keyFile, ": ", err)
+		}
+	}
+
+	cloudServiceProvider, err := csp.New(conf, *listen, privateKey)
+	if err != nil {
+		log.Fatal("[CSP] ", err)
+	}
+	cloudServiceProvider.Start()
+	if *oas >= 0 {
+		fmt.Println("[CSP] Wait for", *oas, "OperatorAgents and", *aps, "AccessPoints to register.")
+		cloudServiceProvider.WaitForRegistrations(*oas, *aps)
+	} else {
+		// read command and process
+		fmt.Println("[OA] Enter your command.(Type 'ok' to start cycle)")
+		reader := bufio.NewReader(os.Stdin)
+	Loop:
+		for {
+			data, _, _ := reader.ReadLine()
+			commands := strings.Split(string(data), " ")
+			switch commands[0] {
+			case "ok":
+				break Loop
+			default:
+				fmt.Println("[OA] Hello!")
+			}
+		}
+	}
+	cloudServiceProvider.Run(*rounds)
+
+	fmt.Println("[CSP] Exit system...")
+	cloudServiceProvider.Stop()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"NPTM/config"
+	"NPTM/oa"
+	"NPTM/util"
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//go run ./cmd/oa
+//包含初始化操作、配置读取、循环逻辑和命令处理等，确保OperatorAgent 启动并运行整个周期过程
+func main() {
+	fmt.Println("[OA] OperatorAgent started.")
+
+	//flags override the config, with -aps the OA starts without a prompt  //命令行参数，可以在脚本或 systemd 中无人值守启动
+	configPath := flag.String("config", config.DefaultPath, "configuration file")
+	listen := flag.String("listen", "", "listen address (default: first free port from oa_ip:oa_port)")
+	csp := flag.String("csp", "", "address of the CSP (default: csp_ip:csp_port)")
+	rounds := flag.Int("rounds", 0, "number of list maintenance rounds (default: protocol.list_maintenance_rounds)")
+	consensusRounds := flag.Int("consensus", 0, "number of consensus rounds per list maintenance round (default: protocol.consensus_rounds)")
+	aps := flag.Int("aps", -1, "start the cycle once this many APs registered (-1: wait for 'ok' on stdin)")
+	keyFile := flag.String("key", "", "file with the hex encoded private key (default: a new key)")
+	genKey := flag.Bool("genkey", false, "print a new key pair for the topology and exit")
+	flag.Parse()
+
+	if *genKey {
+		suite := edwards25519.NewBlakeSHA256Ed25519()
+		a := suite.Scalar().Pick(suite.RandomStream())
+		byteA, _ := a.MarshalBinary()
+		bytePub, _ := suite.Point().Mul(a, nil).MarshalBinary()
+		fmt.Println("private key:", hex.EncodeToString(byteA))
+		fmt.Println("public key: ", hex.EncodeToString(bytePub))
+		return
+	}
+
+	//read and check the config before anything starts  //启动前读取并校验配置
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("[OA] ", err)
+	}
+	if *rounds == 0 {
+		*rounds = conf.Protocol.ListMaintenanceRounds
+	}
+	if *consensusRounds == 0 {
+		*consensusRounds = conf.Protocol.ConsensusRounds
+	}
+	var privateKey kyber.Scalar = nil
+	if *keyFile != "" {
+		privateKey, err = util.ReadPrivateKey(*keyFile)
+		if err != nil {
+			log.Fatal("[OA] Private key ", *keyFile, ": ", err)
+		}
+	}
+
+	// 初始化OA
+	operatorAgent, err := oa.New(conf, *listen, *csp, privateKey)
+	if err != nil {
+		log.Fatal("[OA] ", err)
+	}
+	operatorAgent.Start()
+
+	if *aps >= 0 {
+		//start once the CSP accepted this OA and enough APs registered  // 等待CSP确认和足够多的AP注册
+		fmt.Println("[OA] Wait for the CSP and", *aps, "AccessPoints to register.")
+		operatorAgent.WaitForAPs(*aps)
+	} else {
+		//wait for AP and UE register     // 等待AP和UE注册
+		time.Sleep(10.0 * time.Second)
+
+		// read command and process      // 读取命令并处理
+		fmt.Println("[OA] Enter your command.(Type 'ok' to start cycle)")
+		reader := bufio.NewReader(os.Stdin)
+	Loop:
+		for {
+			//读取并分割命令
+			data, _, _ := reader.ReadLine()
+			commands := strings.Split(string(data), " ")
+			switch commands[0] {
+			case "ok":
+				break Loop
+			default:
+				fmt.Println("[OA] Hello!")
+			}
+		}
+	}
+
+	operatorAgent.Run(*rounds, *consensusRounds)
+	fmt.Println("[OA] Exit system...")
+	operatorAgent.Stop()
+}
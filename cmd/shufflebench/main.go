@@ -0,0 +1,121 @@
+package main
+
+import (
+	"NPTM/shuffle"
+	"bytes"
+	"crypto/cipher"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+//go run ./cmd/shufflebench -k 100,1000 -workers 1,2,4
+//measures how long a shuffle proof of k pairs takes to prove and verify, pair by pair and
+//batched, with each number of workers and checks that all of them produce the same proof
+//混洗证明并行化的基准测试
+func main() {
+	sizes := flag.String("k", "10,100,1000", "comma separated numbers of pairs")
+	workerCounts := flag.String("workers", "1,2,4,8", "comma separated numbers of workers, 0 for one per CPU")
+	reps := flag.Int("n", 3, "runs per measurement, the fastest counts")
+	flag.Parse()
+
+	ks, err := parseInts(*sizes)
+	if err != nil {
+		log.Fatal("[BENCH] -k: ", err)
+	}
+	workers, err := parseInts(*workerCounts)
+	if err != nil {
+		log.Fatal("[BENCH] -workers: ", err)
+	}
+
+	fmt.Printf("%8s %8s %12s %12s %12s %8s %10s\n", "k", "workers", "prove", "verify", "batched", "speedup", "identical")
+	for _, k := range ks {
+		if k < 0 {
+			log.Fatal("[BENCH] a list can not have ", k, " pairs")
+		}
+		var first []byte
+		var base time.Duration
+		for _, w := range workers {
+			prove, verify, batched, prf := measure(k, w, *reps)
+			if first == nil {
+				first, base = prf, prove+verify
+			}
+			fmt.Printf("%8d %8d %12v %12v %12v %7.2fx %10v\n", k, w, prove.Round(time.Microsecond), verify.Round(time.Microsecond),
+				batched.Round(time.Microsecond), float64(base)/float64(prove+verify), bytes.Equal(prf, first))
+		}
+	}
+}
+
+//measure shuffles k pairs the way an OA does and returns the fastest proof, verification and
+//batched verification of reps runs and the proof, the same seed gives every run the same secrets
+func measure(k, workers, reps int) (prove, verify, batched time.Duration, prf []byte) {
+	suite := seededSuite{edwards25519.NewBlakeSHA256Ed25519(), []byte("shufflebench")}
+	key := suite.Scalar().Pick(suite.XOF([]byte("key")))
+	h := suite.Point().Mul(key, nil)
+	X := make([]kyber.Point, k)
+	Y := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		X[i] = h
+		Y[i] = suite.Point().Pick(suite.XOF([]byte("nym" + strconv.Itoa(i))))
+	}
+
+	for r := 0; r < reps; r++ {
+		start := time.Now()
+		Xbar, Ybar, _, prover := shuffle.Shuffle(suite, nil, h, X, Y, suite.RandomStream(), workers)
+		p, err := proof.HashProve(suite, "PairShuffle", prover)
+		if err != nil {
+			log.Fatal("[BENCH] ", err)
+		}
+		elapsed := time.Since(start)
+		if r == 0 || elapsed < prove {
+			prove = elapsed
+		}
+
+		for _, batch := range []bool{false, true} {
+			start = time.Now()
+			verifier := shuffle.Verifier(suite, nil, h, X, Y, Xbar, Ybar, workers, batch)
+			if err := proof.HashVerify(suite, "PairShuffle", verifier, p); err != nil {
+				log.Fatal("[BENCH] the proof does not verify: ", err)
+			}
+			elapsed = time.Since(start)
+			fastest := &verify
+			if batch {
+				fastest = &batched
+			}
+			if r == 0 || elapsed < *fastest {
+				*fastest = elapsed
+			}
+		}
+		prf = p
+	}
+	return prove, verify, batched, prf
+}
+
+//seededSuite draws its secrets from a fixed seed, so the proofs of two runs can be compared
+type seededSuite struct {
+	shuffle.Suite
+	seed []byte
+}
+
+func (s seededSuite) RandomStream() cipher.Stream {
+	return s.Suite.XOF(s.seed)
+}
+
+func parseInts(list string) ([]int, error) {
+	var ints []int
+	for _, field := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"NPTM/config"
+	"NPTM/shuffle"
+	"NPTM/util"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//go run ./cmd/transcript [transcript files]
+//verifies the archived shuffles of the OAs: the proofs of every hop and that every hop of a round
+//raised or lowered the keys it got from the hop before it with one round key and shuffled them with
+//the values it got, so no OA dropped, duplicated or substituted a pseudonym or its value
+//离线验证每一跳的混洗证明
+func main() {
+	configPath := flag.String("config", config.DefaultPath, "configuration file, gives the topology and the archives of its OAs")
+	verbose := flag.Bool("v", false, "print every hop")
+	workers := flag.Int("workers", -1, "goroutines a proof is verified with, 0 for one per CPU (default: protocol.shuffle_workers of the config)")
+	batch := flag.String("batch", "", "true or false, verify the equations of the pairs at once (default: protocol.shuffle_batch_verify of the config)")
+	flag.Parse()
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("[TRANSCRIPT] ", err)
+	}
+	if *workers < 0 {
+		*workers = conf.Protocol.ShuffleWorkers
+	}
+	batched := conf.Protocol.ShuffleBatch
+	if *batch != "" {
+		if batched, err = strconv.ParseBool(*batch); err != nil {
+			log.Fatal("[TRANSCRIPT] -batch: ", err)
+		}
+	}
+	paths := flag.Args()
+	if len(paths) == 0 {
+		//the archives of all OAs in the topology
+		for _, addr := range conf.OAAddrs() {
+			if path := conf.TranscriptPath(addr); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		log.Fatal("[TRANSCRIPT] No archive to verify, name the files or set data_dir in the config.")
+	}
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	failed := false
+	rounds := make(map[round][]*shuffle.Transcript)
+	for _, path := range paths {
+		//a missing archive is an OA that never shuffled, the round reports its hops
+		transcripts, err := shuffle.LoadTranscripts(path)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Println("[TRANSCRIPT]", err)
+			failed = true
+		}
+		for _, t := range transcripts {
+			if err := t.Verify(suite, *workers, batched); err != nil {
+				fmt.Println("[TRANSCRIPT]", path, "hop", t.Hop, direction(t.Forward), "after block", t.K0, "is invalid:", err)
+				failed = true
+				continue
+			}
+			if *verbose {
+				fmt.Println("[TRANSCRIPT]", path, "hop", t.Hop, direction(t.Forward), "after block", t.K0, "verified,", size(t.Keys), "keys.")
+			}
+			r := round{t.K0, t.Forward}
+			rounds[r] = append(rounds[r], t)
+		}
+	}
+
+	keys := make([]round, 0, len(rounds))
+	for r := range rounds {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].K0 != keys[j].K0 {
+			return keys[i].K0 < keys[j].K0
+		}
+		return !keys[i].Forward && keys[j].Forward
+	})
+	for i, r := range keys {
+		//the forward shuffle follows the reverse shuffle of its list, the reverse shuffle the last forward shuffle
+		var prev []*shuffle.Transcript
+		if i > 0 && keys[i-1].Forward != r.Forward && (!r.Forward || keys[i-1].K0 == r.K0) {
+			prev = rounds[keys[i-1]]
+		}
+		if err := shuffle.CheckRound(suite, rounds[r], len(conf.Topology), prev); err != nil {
+			fmt.Println("[TRANSCRIPT] The", direction(r.Forward), "shuffle after block", r.K0, "is invalid:", err)
+			failed = true
+			continue
+		}
+		fmt.Println("[TRANSCRIPT] The", direction(r.Forward), "shuffle after block", r.K0, "is valid,", len(rounds[r]), "hops of", size(rounds[r][0].Keys), "keys.")
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//round is one direction of the shuffle of a list
+type round struct {
+	K0      int64
+	Forward bool
+}
+
+//size is the number of points of an encoded list
+func size(list []byte) int {
+	points, err := util.DecodePointList(list)
+	if err != nil {
+		return -1
+	}
+	return len(points)
+}
+
+func direction(forward bool) string {
+	if forward {
+		return "forward"
+	}
+	return "reverse"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"NPTM/config"
+	"NPTM/ue"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+//go run ./cmd/ue
+func main() {
+	//get AP's address
+
+	fmt.Println("[UE] User equiment started!")
+	//命令行参数：-ap 给出接入点地址后不再从标准输入询问，-duration 让 UE 运行一段时间后自动退出
+	configPath := flag.String("config", config.DefaultPath, "configuration file")
+	ap := flag.String("ap", "", "address of the access point (default: ask on stdin)")
+	listen := flag.String("listen", "", "listen address (default: ue_ip with a free port)")
+	duration := flag.Duration("duration", 0, "exit after this time instead of waiting for 'exit' on stdin")
+	flag.Parse()
+
+	conf, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("[UE] ", err)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	APAddr := *ap
+	if APAddr == "" {
+		fmt.Print("[UE] Please enter the IP address of the access point: ")
+		ipdata, _, err := reader.ReadLine()
+		if err == nil {
+			fmt.Println("[UE] Enter success!")
+		}
+		APAddr = string(ipdata)
+	}
+
+	//initial params and network configurations
+	userEquipment, err := ue.New(conf, *listen, APAddr)
+	if err != nil {
+		log.Fatal("[UE] ", err)
+	}
+	userEquipment.Start()
+	fmt.Println("[UE] Wait for register confirmation.")
+	userEquipment.WaitRegistered()
+
+	if *duration > 0 {
+		//无人值守运行：到时间后退出
+		time.Sleep(*duration)
+		userEquipment.Stop()
+		fmt.Println("[UE] Exit system...")
+		return
+	}
+
+	// read command and process
+	fmt.Println("[UE] Enter your command.")
+
+Loop: //标记一个无线循环  //等待推出指令
+	for {
+		fmt.Print("cmd >> ")
+		data, _, err := reader.ReadLine()
+		if err != nil {
+			//标准输入已关闭（例如在脚本中运行），退出
+			break Loop
+		}
+		commands := strings.Split(string(data), " ")
+
+		switch commands[0] {
+		case "exit":
+			break Loop
+		default:
+			fmt.Println("[UE] Hello!")
+		}
+	}
+
+	userEquipment.Stop()
+	fmt.Println("[UE] Exit system...")
+}
@@ -0,0 +1,421 @@
+// Package config loads and validates the configuration shared by CSP, OAs, APs and UEs
+package config
+
+import (
+	"NPTM/blockchain"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+// DefaultPath is read when an entity is started without -config
+const DefaultPath = "config/deptvm.json"
+
+type Config struct {
+	Conn Conn `json:"conn"`
+	CSP  CSP  `json:"csp"`
+	//the OperatorAgents in shuffle order, the first one starts the forward shuffle
+	Topology []OA `json:"topology"`
+	//the dataset every AP collects, by listen address
+	APs      []AP     `json:"aps"`
+	Protocol Protocol `json:"protocol"`
+	//directory of the OAs' block stores, empty keeps the chains in memory
+	DataDir string `json:"data_dir"`
+}
+
+// Conn holds the addresses the entities listen on
+type Conn struct {
+	//udp, tcp or mem
+	Transport string `json:"transport"`
+	CSPIP     string `json:"csp_ip"`
+	CSPPort   int    `json:"csp_port"`
+	//OAs and APs listen on the first free port from here on
+	OAIP   string `json:"oa_ip"`
+	OAPort int    `json:"oa_port"`
+	APIP   string `json:"ap_ip"`
+	APPort int    `json:"ap_port"`
+	UEIP   string `json:"ue_ip"`
+}
+
+// CSP pins the key of the CSP, the OAs and APs only accept it with that key
+type CSP struct {
+	//hex encoded long-term key
+	PublicKey string `json:"public_key"`
+
+	Key kyber.Point `json:"-"`
+}
+
+// OA is one hop of the topology
+type OA struct {
+	Addr string `json:"addr"`
+	//hex encoded long-term key, optional, a registration with another key is rejected
+	PublicKey string `json:"public_key,omitempty"`
+
+	Key kyber.Point `json:"-"`
+}
+
+// AP binds a dataset to the AP listening on Addr
+type AP struct {
+	Addr    string `json:"addr"`
+	Dataset string `json:"dataset"`
+}
+
+// Protocol holds the constants of the consensus and the trust evaluation
+type Protocol struct {
+	//basic difficulty, hex, the target of the first mined blocks. The target is retargeted over
+	//the last retarget_window mined blocks toward one mined block every block_interval_ms.
+	Tag            string `json:"tag"`
+	BlockInterval  int    `json:"block_interval_ms"`
+	RetargetWindow int    `json:"retarget_window"`
+	//threshold of the number of blocks that a miner has created
+	Nbr int64 `json:"nbr"`
+	//threshold number of APs whose provided trust-related data in a block
+	Ntr int64 `json:"ntr"`
+	//receive window and its polling interval in milliseconds
+	Theta    int `json:"theta_ms"`
+	Interval int `json:"interval_ms"`
+	//how long an OA waits for the reply of a peer while it syncs its chain, in milliseconds
+	SyncTimeout int `json:"sync_timeout_ms"`
+	//how the block of a consensus round is agreed on: pow, the OAs mine, or bft, the OAs vote on
+	//the block of a proposer. A BFT phase ends after bft_timeout_ms without a quorum.
+	Consensus  string `json:"consensus"`
+	BFTTimeout int    `json:"bft_timeout_ms"`
+	//how far the clocks of the entities may differ, timestamps are checked up to it, in milliseconds
+	ClockSkew int `json:"clock_skew_ms"`
+
+	//goroutines a shuffle proof is computed and verified with, 0 for one per CPU, and whether the
+	//equations of the pairs are verified at once with a random linear combination
+	ShuffleWorkers int  `json:"shuffle_workers"`
+	ShuffleBatch   bool `json:"shuffle_batch_verify"`
+
+	//probability threshold of the obfuscation factor
+	Pth float64 `json:"pth"`
+	//influence of abnormal behaviours
+	AbnormalFactor float64 `json:"abnormal_factor"`
+	//time delay factor
+	TimeDelay float64 `json:"time_delay"`
+
+	ConsensusRounds       int    `json:"consensus_rounds"`
+	ListMaintenanceRounds int    `json:"list_maintenance_rounds"`
+	NormalModel           string `json:"normal_model"`
+	AbnormalModel         string `json:"abnormal_model"`
+}
+
+// Default returns the protocol constants the paper's experiments use, Load starts from them
+func Default() *Config {
+	return &Config{
+		Conn: Conn{Transport: "udp"},
+		Protocol: Protocol{
+			Tag:                   blockchain.TAG,
+			BlockInterval:         blockchain.BLOCK_INTERVAL,
+			RetargetWindow:        blockchain.WINDOW,
+			Nbr:                   blockchain.Nbr,
+			Ntr:                   blockchain.Ntr,
+			Theta:                 blockchain.Theta,
+			Interval:              blockchain.INTERVAL,
+			SyncTimeout:           10000,
+			Consensus:             "pow",
+			BFTTimeout:            5000,
+			ClockSkew:             5000,
+			ShuffleBatch:          true,
+			Pth:                   0.5,
+			AbnormalFactor:        0.17,
+			TimeDelay:             0.5,
+			ConsensusRounds:       1,
+			ListMaintenanceRounds: 3,
+			NormalModel:           "datasets/normal_model.csv",
+			AbnormalModel:         "datasets/abnormal_model.csv",
+		},
+	}
+}
+
+// Load reads the file at path, fields it leaves out keep their default
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	conf := Default()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(conf); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s:\n%w", path, err)
+	}
+	return conf, nil
+}
+
+// Validate checks every field and parses the pinned keys, the error lists all problems found
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field string, format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("  %s: %s", field, fmt.Sprintf(format, a...)))
+	}
+
+	switch c.Conn.Transport {
+	case "udp", "tcp", "mem":
+	default:
+		fail("conn.transport", "%q is not one of udp, tcp, mem", c.Conn.Transport)
+	}
+	for _, host := range []struct {
+		field string
+		ip    string
+	}{{"conn.csp_ip", c.Conn.CSPIP}, {"conn.oa_ip", c.Conn.OAIP}, {"conn.ap_ip", c.Conn.APIP}, {"conn.ue_ip", c.Conn.UEIP}} {
+		if host.ip == "" {
+			fail(host.field, "missing")
+		}
+	}
+	for _, port := range []struct {
+		field string
+		port  int
+	}{{"conn.csp_port", c.Conn.CSPPort}, {"conn.oa_port", c.Conn.OAPort}, {"conn.ap_port", c.Conn.APPort}} {
+		if port.port <= 0 || port.port > 65535 {
+			fail(port.field, "port %d out of range 1-65535", port.port)
+		}
+	}
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	c.CSP.Key = nil
+	if c.CSP.PublicKey == "" {
+		fail("csp.public_key", "missing, the OAs and APs only trust the CSP with a pinned key")
+	} else if key, err := DecodePoint(suite, c.CSP.PublicKey); err != nil {
+		fail("csp.public_key", "%v", err)
+	} else {
+		c.CSP.Key = key
+	}
+
+	//the first OA needs a next hop and the last one a previous hop
+	if len(c.Topology) < 2 {
+		fail("topology", "needs at least 2 OperatorAgents, got %d", len(c.Topology))
+	}
+	seen := make(map[string]string)
+	pinned := make(map[string]string)
+	for i := range c.Topology {
+		oa := &c.Topology[i]
+		field := fmt.Sprintf("topology[%d]", i)
+		if err := checkAddr(oa.Addr); err != nil {
+			fail(field+".addr", "%v", err)
+		} else if other, ok := seen[oa.Addr]; ok {
+			fail(field+".addr", "%s is already used by %s", oa.Addr, other)
+		} else {
+			seen[oa.Addr] = field
+		}
+		oa.Key = nil
+		if oa.PublicKey != "" {
+			key, err := DecodePoint(suite, oa.PublicKey)
+			if err != nil {
+				fail(field+".public_key", "%v", err)
+			} else if other, ok := pinned[key.String()]; ok {
+				fail(field+".public_key", "is already pinned for %s", other)
+			} else {
+				oa.Key = key
+				pinned[key.String()] = field
+			}
+		} else if c.Protocol.Consensus == "bft" {
+			//a key chosen after the others could forge their collective signature  //BFT防止恶意公钥
+			fail(field+".public_key", "missing, bft checks the collective signatures only with the pinned keys of all OAs")
+		}
+	}
+
+	for i, ap := range c.APs {
+		field := fmt.Sprintf("aps[%d]", i)
+		if err := checkAddr(ap.Addr); err != nil {
+			fail(field+".addr", "%v", err)
+		} else if other, ok := seen[ap.Addr]; ok {
+			fail(field+".addr", "%s is already used by %s", ap.Addr, other)
+		} else {
+			seen[ap.Addr] = field
+		}
+		checkFile(fail, field+".dataset", ap.Dataset)
+	}
+
+	p := c.Protocol
+	if tag, ok := new(big.Int).SetString(p.Tag, 16); !ok || tag.Sign() <= 0 {
+		fail("protocol.tag", "%q is not a positive hex number", p.Tag)
+	}
+	if p.BlockInterval <= 0 {
+		fail("protocol.block_interval_ms", "must be positive, got %d", p.BlockInterval)
+	}
+	if p.RetargetWindow <= 0 {
+		fail("protocol.retarget_window", "must be positive, got %d", p.RetargetWindow)
+	}
+	if p.Nbr <= 0 {
+		fail("protocol.nbr", "must be positive, got %d", p.Nbr)
+	}
+	if p.Ntr <= 0 {
+		fail("protocol.ntr", "must be positive, got %d", p.Ntr)
+	}
+	if p.Interval <= 0 {
+		fail("protocol.interval_ms", "must be positive, got %d", p.Interval)
+	}
+	if p.Theta < p.Interval {
+		fail("protocol.theta_ms", "%d is shorter than interval_ms %d", p.Theta, p.Interval)
+	}
+	if p.SyncTimeout <= 0 {
+		fail("protocol.sync_timeout_ms", "must be positive, got %d", p.SyncTimeout)
+	}
+	switch p.Consensus {
+	case "pow", "bft":
+	default:
+		fail("protocol.consensus", "%q is not one of pow, bft", p.Consensus)
+	}
+	if p.BFTTimeout <= 0 {
+		fail("protocol.bft_timeout_ms", "must be positive, got %d", p.BFTTimeout)
+	}
+	if p.ClockSkew < 0 {
+		fail("protocol.clock_skew_ms", "must not be negative, got %d", p.ClockSkew)
+	}
+	if p.ShuffleWorkers < 0 {
+		fail("protocol.shuffle_workers", "must not be negative, got %d", p.ShuffleWorkers)
+	}
+	if p.Pth <= 0 || p.Pth > 1 {
+		fail("protocol.pth", "%v is not in (0, 1]", p.Pth)
+	}
+	if p.AbnormalFactor < 0 {
+		fail("protocol.abnormal_factor", "must not be negative, got %v", p.AbnormalFactor)
+	}
+	if p.TimeDelay <= 0 {
+		fail("protocol.time_delay", "must be positive, got %v", p.TimeDelay)
+	}
+	if p.ConsensusRounds <= 0 {
+		fail("protocol.consensus_rounds", "must be positive, got %d", p.ConsensusRounds)
+	}
+	if p.ListMaintenanceRounds <= 0 {
+		fail("protocol.list_maintenance_rounds", "must be positive, got %d", p.ListMaintenanceRounds)
+	}
+	checkFile(fail, "protocol.normal_model", p.NormalModel)
+	checkFile(fail, "protocol.abnormal_model", p.AbnormalModel)
+
+	//the directory is created by the first OA, but it must not be a file
+	if c.DataDir != "" {
+		if info, err := os.Stat(c.DataDir); err == nil && !info.IsDir() {
+			fail("data_dir", "%s is not a directory", c.DataDir)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CSPAddr is the address of the CSP
+func (c *Config) CSPAddr() string {
+	return net.JoinHostPort(c.Conn.CSPIP, strconv.Itoa(c.Conn.CSPPort))
+}
+
+// CSPKey is the pinned key of the CSP
+func (c *Config) CSPKey() kyber.Point {
+	return c.CSP.Key
+}
+
+// OAAddrs lists the topology in shuffle order
+func (c *Config) OAAddrs() []string {
+	addrs := make([]string, len(c.Topology))
+	for i, oa := range c.Topology {
+		addrs[i] = oa.Addr
+	}
+	return addrs
+}
+
+// OAKey is the key pinned for the OA at addr, nil if the topology pins none
+func (c *Config) OAKey(addr string) kyber.Point {
+	for _, oa := range c.Topology {
+		if oa.Addr == addr {
+			return oa.Key
+		}
+	}
+	return nil
+}
+
+// OAKeys are the pinned keys in topology order, nil unless the topology pins every key
+func (c *Config) OAKeys() []kyber.Point {
+	keys := make([]kyber.Point, len(c.Topology))
+	for i, oa := range c.Topology {
+		if oa.Key == nil {
+			return nil
+		}
+		keys[i] = oa.Key
+	}
+	return keys
+}
+
+// Difficulty is the retargeting of mined blocks the protocol section gives
+func (c *Config) Difficulty() blockchain.Difficulty {
+	return blockchain.Difficulty{Tag: c.Protocol.Tag, Interval: int64(c.Protocol.BlockInterval), Window: c.Protocol.RetargetWindow}
+}
+
+// ChainPath is the block store of the OA at addr, "" without a data_dir
+func (c *Config) ChainPath(addr string) string {
+	return c.dataPath(addr, ".chain")
+}
+
+// TranscriptPath is the archive of the shuffle transcripts of the OA at addr, "" without a data_dir
+func (c *Config) TranscriptPath(addr string) string {
+	return c.dataPath(addr, ".shuffles")
+}
+
+func (c *Config) dataPath(addr string, ext string) string {
+	if c.DataDir == "" {
+		return ""
+	}
+	name := strings.NewReplacer(":", "_", "[", "", "]", "").Replace(addr)
+	return filepath.Join(c.DataDir, "oa_"+name+ext)
+}
+
+// Dataset is the dataset bound to the AP at addr
+func (c *Config) Dataset(addr string) (string, bool) {
+	for _, ap := range c.APs {
+		if ap.Addr == addr {
+			return ap.Dataset, true
+		}
+	}
+	return "", false
+}
+
+// DecodePoint parses a hex encoded point, the format of public_key
+func DecodePoint(group kyber.Group, s string) (kyber.Point, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("not hex: %v", err)
+	}
+	point := group.Point()
+	if err := point.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("not a point: %v", err)
+	}
+	return point, nil
+}
+
+func checkAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not host:port", addr)
+	}
+	if host == "" {
+		return fmt.Errorf("%q has no host", addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%q has an invalid port", addr)
+	}
+	return nil
+}
+
+func checkFile(fail func(string, string, ...interface{}), field, path string) {
+	if path == "" {
+		fail(field, "missing")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		fail(field, "%v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//testKey is the hex encoded public key of a seed
+func testKey(seed string) string {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	key, _ := suite.Point().Pick(suite.XOF([]byte(seed))).MarshalBinary()
+	return hex.EncodeToString(key)
+}
+
+//validConfig is a config of a CSP, two OAs and an AP that passes Validate
+func validConfig() *Config {
+	conf := Default()
+	conf.Conn = Conn{Transport: "mem", CSPIP: "127.0.0.1", CSPPort: 12345, OAIP: "127.0.0.1", OAPort: 10000,
+		APIP: "127.0.0.1", APPort: 8000, UEIP: "127.0.0.1"}
+	conf.CSP.PublicKey = testKey("csp")
+	conf.Topology = []OA{{Addr: "127.0.0.1:10000"}, {Addr: "127.0.0.1:10001"}}
+	conf.APs = []AP{{Addr: "127.0.0.1:8000", Dataset: "../datasets/dataset1.csv"}}
+	conf.Protocol.NormalModel = "../datasets/normal_model.csv"
+	conf.Protocol.AbnormalModel = "../datasets/abnormal_model.csv"
+	return conf
+}
+
+//bft checks the collective signatures with the keys of all OAs, so none of them may be left to registration
+func TestValidateBFTKeys(t *testing.T) {
+	conf := validConfig()
+	conf.Protocol.Consensus = "bft"
+	conf.Topology[0].PublicKey = testKey("oa0")
+	err := conf.Validate()
+	if err == nil || !strings.Contains(err.Error(), "topology[1].public_key") {
+		t.Fatalf("got %v, want a missing topology[1].public_key", err)
+	}
+	conf.Topology[1].PublicKey = testKey("oa1")
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if key := conf.OAKey("127.0.0.1:10001"); key == nil || key.String() != conf.Topology[1].Key.String() {
+		t.Fatal("the pinned key of the second OA is not parsed")
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name   string
+		change func(conf *Config)
+		field  string
+	}{
+		{"one OA", func(conf *Config) { conf.Topology = conf.Topology[:1] }, "topology"},
+		{"no port", func(conf *Config) { conf.Topology[1].Addr = "127.0.0.1" }, "topology[1].addr"},
+		{"no host", func(conf *Config) { conf.Topology[1].Addr = ":10001" }, "topology[1].addr"},
+		{"port out of range", func(conf *Config) { conf.Topology[1].Addr = "127.0.0.1:70000" }, "topology[1].addr"},
+		{"duplicate OA", func(conf *Config) { conf.Topology[1].Addr = conf.Topology[0].Addr }, "topology[1].addr"},
+		{"AP on an OA", func(conf *Config) { conf.APs[0].Addr = conf.Topology[0].Addr }, "aps[0].addr"},
+		{"malformed key", func(conf *Config) { conf.Topology[0].PublicKey = "not hex" }, "topology[0].public_key"},
+		{"short key", func(conf *Config) { conf.Topology[0].PublicKey = testKey("oa0")[:62] }, "topology[0].public_key"},
+		{"duplicate key", func(conf *Config) {
+			conf.Topology[0].PublicKey = testKey("oa0")
+			conf.Topology[1].PublicKey = testKey("oa0")
+		}, "topology[1].public_key"},
+		{"no CSP key", func(conf *Config) { conf.CSP.PublicKey = "" }, "csp.public_key"},
+		{"no dataset", func(conf *Config) { conf.APs[0].Dataset = "../datasets/missing.csv" }, "aps[0].dataset"},
+	} {
+		conf := validConfig()
+		c.change(conf)
+		err := conf.Validate()
+		if err == nil || !strings.Contains(err.Error(), "  "+c.field+":") {
+			t.Fatalf("%s: got %v, want an error for %s", c.name, err, c.field)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+// Package csp is the cloud service provider, it collects the signed records of the APs and hands them to the OAs
+package csp
+
+import (
+	"NPTM/config"
+	"NPTM/proto"
+	"NPTM/transport"
+	"NPTM/util"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/suites"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+type CloudServiceProvider struct {
+	//local address
+	LocalAdress net.Addr
+	//sokect, every event is encrypted and authenticated with the long-term keys
+	Socket *transport.SecureTransport
+
+	// crypto variables
+	Suite      suites.Suite
+	PrivateKey kyber.Scalar
+	PublicKey  kyber.Point
+	G          kyber.Point
+
+	OAList []net.Addr
+	//Stored OA's public key&&addr
+	OAKeyList map[string]kyber.Point
+	APList    []net.Addr
+	//Stored AP's public key&&addr
+	APKeyList map[string]kyber.Point
+	//trust value set
+	Records []util.Record
+
+	//round requested by the OAs and sequence number of the last record sent to them
+	Round int64
+	Seq   int64
+	//newest round and sequence number accepted from every AP
+	Replay *util.ReplayGuard
+
+	//records before this index have been sent to the OAs
+	memoryIndex int
+	//number of OAs that requested the records of this round
+	oaNum int
+	//APs whose records of this round all arrived
+	apDone map[string]bool
+	//the topology, OAs register only at its addresses and with the keys it pins
+	conf *config.Config
+	//closed by Stop
+	stop chan struct{}
+	//sends the replies queued under mu after the lock is released
+	out *util.Outbox
+	//guards the state above between the listener and Run, phase is broadcast after every event
+	mu    sync.Mutex
+	phase *sync.Cond
+}
+
+func (c *CloudServiceProvider) AddOA(addr net.Addr, key kyber.Point) {
+	// delete the OA who has same pub key
+	for a, k := range c.OAKeyList {
+		if k == key {
+			delete(c.OAKeyList, a)
+			break
+		}
+	}
+
+	//an OA that registers again, for instance after a restart, replaces its key
+	if _, ok := c.OAKeyList[addr.String()]; !ok {
+		c.OAList = append(c.OAList, addr)
+	}
+	c.OAKeyList[addr.String()] = key
+}
+
+func (c *CloudServiceProvider) AddAP(addr net.Addr, key kyber.Point) {
+	// delete the AP who has same pub key
+	for a, k := range c.APKeyList {
+		if k == key {
+			delete(c.APKeyList, a)
+			break
+		}
+	}
+
+	c.APKeyList[addr.String()] = key
+	c.APList = append(c.APList, addr)
+}
+
+// Handle processes one event received from addr
+func (cloudServiceProvider *CloudServiceProvider) Handle(buf []byte, addr net.Addr) {
+	//one event at a time, Run waits for the requests it counts
+	cloudServiceProvider.mu.Lock()
+	defer cloudServiceProvider.mu.Unlock()
+	defer cloudServiceProvider.phase.Broadcast()
+
+	event, msg, err := proto.Decode(buf)
+	if err != nil {
+		//malformed or incompatible events are dropped, they must not stop the CSP
+		fmt.Println("[CSP] Reject the event from", addr, ":", err)
+		return
+	}
+
+	//records and data requests are only accepted from the key the sender registered with
+	if !cloudServiceProvider.authorizedCSP(event.EventType, addr) {
+		fmt.Println("[CSP] Reject the unauthenticated event", event.EventType, "from", addr)
+		return
+	}
+
+	switch event.EventType {
+	case proto.AP_REGISTER:
+		cloudServiceProvider.handleAPRegister(msg.(*proto.Register), addr)
+		break
+	case proto.OA_REGISTER_CSP:
+		cloudServiceProvider.handleOARegister(msg.(*proto.Register), addr)
+		break
+	case proto.DATA_COLLECTION_AP:
+		cloudServiceProvider.handleDataCollection_AP_Side(msg.(*proto.DataCollection), addr)
+		break
+	case proto.DATA_COLLECTION_OA:
+		cloudServiceProvider.handelDataCollection_OA_Side(msg.(*proto.DataCollection), addr)
+		break
+	default:
+		fmt.Println("[CSP] Unrecognized request...")
+		break
+	}
+}
+
+//authorizedCSP checks the sender of an event against the key registered for its role
+func (cloudServiceProvider *CloudServiceProvider) authorizedCSP(eventType int, addr net.Addr) bool {
+	switch eventType {
+	case proto.DATA_COLLECTION_AP:
+		return cloudServiceProvider.Socket.Authenticated(addr, cloudServiceProvider.APKeyList[addr.String()])
+	case proto.DATA_COLLECTION_OA:
+		return cloudServiceProvider.Socket.Authenticated(addr, cloudServiceProvider.OAKeyList[addr.String()])
+	}
+	//registrations carry their key, the handlers compare it with the channel
+	return true
+}
+
+func (cloudServiceProvider *CloudServiceProvider) handleAPRegister(msg *proto.Register, addr net.Addr) {
+	publicKey := cloudServiceProvider.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		fmt.Println("[CSP] Reject the registration, invalid public key:", addr)
+		return
+	}
+	//the registered key must be the one that opened the channel
+	if !cloudServiceProvider.Socket.Authenticated(addr, publicKey) {
+		fmt.Println("[CSP] Reject the registration, the key is not the channel's key:", addr)
+		return
+	}
+	cloudServiceProvider.AddAP(addr, publicKey)
+	fmt.Println("[CSP] Receive the registration info from AccessPoint: ", addr)
+	if !cloudServiceProvider.replyAP(addr) {
+		fmt.Println("[CSP] The AccessPoint waits for the keys of the OAs:", addr)
+	}
+}
+
+//topologyKeys are the keys of the OAs in topology order, nil while an OA of the topology has not
+//registered
+func (cloudServiceProvider *CloudServiceProvider) topologyKeys() []kyber.Point {
+	keys := make([]kyber.Point, 0, len(cloudServiceProvider.conf.Topology))
+	for _, OAAddr := range cloudServiceProvider.conf.OAAddrs() {
+		key, ok := cloudServiceProvider.OAKeyList[OAAddr]
+		if !ok {
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+//replyAP confirms the registration of the AP at addr with the keys of the OAs in topology order,
+//the AP checks the creators and commits of the blocks with them. It reports false while an OA of
+//the topology has not registered.  //向AP发送拓扑中OA的公钥
+func (cloudServiceProvider *CloudServiceProvider) replyAP(addr net.Addr) bool {
+	keys := cloudServiceProvider.topologyKeys()
+	if keys == nil {
+		return false
+	}
+	event := proto.NewEvent(proto.AP_REGISTER_REPLY_CSP, &proto.RegisterReply{Reply: true, OAKeys: util.ProtobufEncodePointList(keys)})
+	cloudServiceProvider.out.Send(addr, util.Encode(event))
+	return true
+}
+
+//replyOA confirms the registration of the OA at addr, with the keys of the OAs once all registered.
+//The OAs open their sessions to each other only with these keys.  //向OA发送拓扑中OA的公钥
+func (cloudServiceProvider *CloudServiceProvider) replyOA(addr net.Addr) {
+	bytePublickey, _ := cloudServiceProvider.PublicKey.MarshalBinary()
+	reply := &proto.RegisterReply{Reply: true, PublicKey: bytePublickey}
+	if keys := cloudServiceProvider.topologyKeys(); keys != nil {
+		reply.OAKeys = util.ProtobufEncodePointList(keys)
+	}
+	cloudServiceProvider.out.Send(addr, util.Encode(proto.NewEvent(proto.OA_REGISTER_REPLY_CSP, reply)))
+}
+
+func (cloudServiceProvider *CloudServiceProvider) handleOARegister(msg *proto.Register, addr net.Addr) {
+	publicKey := cloudServiceProvider.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		fmt.Println("[CSP] Reject the registration, invalid public key:", addr)
+		return
+	}
+	if !cloudServiceProvider.Socket.Authenticated(addr, publicKey) {
+		fmt.Println("[CSP] Reject the registration, the key is not the channel's key:", addr)
+		return
+	}
+	//the APs learn the keys of the OAs from the CSP, so only the OAs of the topology register
+	if !cloudServiceProvider.inTopology(addr) {
+		fmt.Println("[CSP] Reject the registration, the OA is not in the topology:", addr)
+		return
+	}
+	//the key of the topology or of the first registration, an OA that restarts keeps its key
+	if pinned := cloudServiceProvider.Socket.Pinned(addr.String()); pinned != nil && !pinned.Equal(publicKey) {
+		fmt.Println("[CSP] Reject the registration, the key is not the one the OA is known with:", addr)
+		return
+	}
+	_, known := cloudServiceProvider.OAKeyList[addr.String()]
+	cloudServiceProvider.Socket.Pin(addr.String(), publicKey)
+	cloudServiceProvider.AddOA(addr, publicKey)
+	fmt.Println("[CSP] Receive the registration info from OperatorAgent: ", addr)
+	cloudServiceProvider.replyOA(addr)
+
+	//the OAs and APs waiting for the keys get them once the last OA registered
+	if !known && cloudServiceProvider.topologyKeys() != nil {
+		for _, OAAddr := range cloudServiceProvider.OAList {
+			if OAAddr.String() != addr.String() {
+				cloudServiceProvider.replyOA(OAAddr)
+			}
+		}
+		for _, APAddr := range cloudServiceProvider.APList {
+			cloudServiceProvider.replyAP(APAddr)
+		}
+	}
+}
+
+func (cloudServiceProvider *CloudServiceProvider) inTopology(addr net.Addr) bool {
+	for _, OAAddr := range cloudServiceProvider.conf.OAAddrs() {
+		if OAAddr == addr.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func (cloudServiceProvider *CloudServiceProvider) handleDataCollection_AP_Side(msg *proto.DataCollection, addr net.Addr) {
+	Nym := cloudServiceProvider.Suite.Point()
+
+	if msg.Start {
+		fmt.Println("[CSP] Recieve the trust value related data from AccessPoint:", addr)
+
+	}
+	//verify the signature and store the records to local records
+	if err := Nym.UnmarshalBinary(msg.Nym); err != nil {
+		fmt.Println("[CSP] Reject the record, invalid nym:", addr)
+		return
+	}
+	record := util.Record{Nym: Nym, Data: msg.Data}
+	err := util.SchnorrVerify(cloudServiceProvider.Suite, util.ToSignedRecord(record, msg.Round, msg.Seq),
+		cloudServiceProvider.APKeyList[addr.String()], msg.SignRe)
+	if err == nil {
+		//a captured record must not be counted twice
+		err = cloudServiceProvider.Replay.Accept(addr.String(), msg.Round, msg.Seq)
+		if err != nil {
+			fmt.Println("[CSP] Reject the record from AccessPoint:", addr, err)
+			return
+		}
+		//fmt.Println("[CSP] The sign of AccessPoint verify success!", srcAddr)
+		cloudServiceProvider.Records = append(cloudServiceProvider.Records, record)
+		//fmt.Println("[CSP] The records has been stored to local storage...")
+	} else {
+		fmt.Println("[CSP] The sign of AccessPoint verify failed!", addr)
+	}
+
+	if msg.Done {
+		cloudServiceProvider.apDone[addr.String()] = true
+		fmt.Println("[CSP] Data collection over from AccessPoint:", addr)
+	}
+}
+
+//SEND ONE RECORD ONE TIME
+//When recieve all AP's new data and all OA's data collection request,send data to OAs
+func (cloudServiceProvider *CloudServiceProvider) handelDataCollection_OA_Side(msg *proto.DataCollection, addr net.Addr) {
+
+	if msg.Require {
+		if cloudServiceProvider.oaNum <= len(cloudServiceProvider.OAList) {
+			fmt.Println("[CSP] The data collection request from the OA is received.:", addr)
+			//the records are sent for the round the OAs are waiting in
+			if msg.Round > cloudServiceProvider.Round {
+				cloudServiceProvider.Round = msg.Round
+			}
+			cloudServiceProvider.oaNum++
+		} else {
+			fmt.Println("[CSP] Some OA's data collection request wrong!")
+		}
+
+	} else {
+		fmt.Println("[CSP] Data collection request confirmation failed!")
+	}
+}
+
+func (cloudServiceProvider *CloudServiceProvider) dataCollectionToOA() {
+	//send one record one time
+	fmt.Println("[CSP] Send the records to OperatorAgents.")
+	size := len(cloudServiceProvider.Records)
+	var wait = sync.WaitGroup{}
+	/*sync.WaitGroup 是一个计数器，用来等待一组并发操作完成。
+	可以通过 Add 方法增加计数，通过 Done 方法减少计数，通过 Wait 方法阻塞，直到计数器归零。
+	*/
+	for i := cloudServiceProvider.memoryIndex; i < size; i++ {
+		cloudServiceProvider.Seq++
+		byteRecord := util.ToSignedRecord(cloudServiceProvider.Records[i], cloudServiceProvider.Round, cloudServiceProvider.Seq)
+		SignRe := util.SchnorrSign(cloudServiceProvider.Suite, random.New(),
+			byteRecord, cloudServiceProvider.PrivateKey)
+		var start bool = false
+		var done bool = false
+		
+		
+		fmt.Println(cloudServiceProvider.Records[i])
+		//set the start and done flag
+		if i == 0 {
+			start = true
+		} else if i == size-1 {
+			done = true
+		}
+		byteNym, _ := cloudServiceProvider.Records[i].Nym.MarshalBinary()
+		event := proto.NewEvent(proto.DATA_COLLECTION_OA, &proto.DataCollection{
+			Start:  start,
+			Nym:    byteNym,
+			Data:   cloudServiceProvider.Records[i].Data,
+			Round:  cloudServiceProvider.Round,
+			Seq:    cloudServiceProvider.Seq,
+			SignRe: SignRe,
+			Done:   done,
+		})
+
+		//the lock is released while the OAs acknowledge the record, the caller holds it
+		OAList := append([]net.Addr{}, cloudServiceProvider.OAList...)
+		cloudServiceProvider.mu.Unlock()
+		for _, OAAddr := range OAList {
+			wait.Add(1)    //将 sync.WaitGroup 的计数器加 1。这个操作确保 WaitGroup 知道有一个新的 goroutine 需要等待。
+			go func(OAAddr net.Addr) {
+				defer wait.Done()     //确保 goroutine 完成时，将 WaitGroup 的计数器减 1。这是为了确保即使函数内部发生了错误，Done 也会被调用，避免程序死锁。
+				util.Send(cloudServiceProvider.Socket, OAAddr, util.Encode(event))
+			}(OAAddr)
+		}
+		wait.Wait()    //阻塞执行，直到 WaitGroup 的计数器减为零。 every OA has acknowledged the record
+		cloudServiceProvider.mu.Lock()
+
+	}
+	cloudServiceProvider.memoryIndex = size
+	fmt.Println("[CSP] Trust data has been sent.")
+}
+
+//监听然后处理
+func (cloudServiceProvider *CloudServiceProvider) startCSPListener() {
+	fmt.Println("[CSP] CloudServiceProvider Listener started...")
+	for {
+		//events arrive reassembled, acknowledged and without duplicates
+		msg, addr, err := cloudServiceProvider.Socket.Receive()
+		if err != nil {
+			select {
+			case <-cloudServiceProvider.stop:
+			default:
+				fmt.Println("[CSP] Listener stopped:", err)
+			}
+			return
+		}
+		cloudServiceProvider.Handle(msg, addr)
+	}
+}
+
+// New listens on listen ("" is csp_ip:csp_port), privateKey is the long-term key whose public key
+// the config pins
+func New(conf *config.Config, listen string, privateKey kyber.Scalar) (*CloudServiceProvider, error) {
+	if listen == "" {
+		listen = conf.CSPAddr()
+	}
+	Socket, err := transport.Listen(conf.Conn.Transport, listen)
+	if err != nil {
+		return nil, err
+	}
+	//the address the others reach this node at, also when it listens on all interfaces
+	LocalAddr, err := Socket.ResolveAddr(transport.AdvertisedAddr(Socket.LocalAddr(), conf.Conn.CSPIP))
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+	fmt.Println("[CSP] Local address :", LocalAddr)
+
+	suite := edwards25519.NewBlakeSHA256Ed25519() // Use the edwards25519-curve
+	a := privateKey
+	if a == nil {
+		a = suite.Scalar().Pick(suite.RandomStream()) // Alice's private key
+	}
+	A := suite.Point().Mul(a, nil)
+	if !conf.CSPKey().Equal(A) {
+		Socket.Close()
+		return nil, errors.New("the config pins another public key for the CSP, start with -key and its private key")
+	}
+
+	cloudServiceProvider := &CloudServiceProvider{
+		LocalAddr, transport.Secure(Socket, suite, a, A, LocalAddr.String()),
+		suite, a, A, nil,
+		nil, make(map[string]kyber.Point), nil, make(map[string]kyber.Point), nil,
+		0, 0, util.NewReplayGuard(),
+		0, 0, make(map[string]bool), conf, make(chan struct{}), nil, sync.Mutex{}, nil}
+	cloudServiceProvider.phase = sync.NewCond(&cloudServiceProvider.mu)
+	cloudServiceProvider.out = util.NewOutbox(cloudServiceProvider.Socket)
+	//the OAs the topology pins only register with their keys
+	for _, OAAddr := range conf.OAAddrs() {
+		if key := conf.OAKey(OAAddr); key != nil {
+			cloudServiceProvider.Socket.Pin(OAAddr, key)
+		}
+	}
+	fmt.Println("[CSP] Parameter initialization is complete.")
+	fmt.Println("[CSP] My public key is ", cloudServiceProvider.PublicKey)
+	return cloudServiceProvider, nil
+}
+
+// Start runs the listener, OAs and APs can register from now on
+func (cloudServiceProvider *CloudServiceProvider) Start() {
+	go cloudServiceProvider.startCSPListener()
+}
+
+// WaitForRegistrations blocks until oas OAs and aps APs registered
+func (cloudServiceProvider *CloudServiceProvider) WaitForRegistrations(oas, aps int) {
+	cloudServiceProvider.mu.Lock()
+	defer cloudServiceProvider.mu.Unlock()
+	for len(cloudServiceProvider.OAList) < oas || len(cloudServiceProvider.APList) < aps {
+		cloudServiceProvider.phase.Wait()
+	}
+}
+
+// Run sends the records of rounds data sharing cycles to the OAs registered so far
+func (cloudServiceProvider *CloudServiceProvider) Run(rounds int) {
+	cloudServiceProvider.mu.Lock()
+	defer cloudServiceProvider.mu.Unlock()
+	size1 := len(cloudServiceProvider.OAList)
+	fmt.Println()
+	//start the cycle
+	for i := 0; i < rounds; i++ {
+		//the requests of all OAs and the records of all APs, an OA may ask before the APs sent  //等待所有OA的请求和所有AP的数据
+		for cloudServiceProvider.oaNum != size1 || len(cloudServiceProvider.apDone) < len(cloudServiceProvider.APList) {
+			cloudServiceProvider.phase.Wait()
+		}
+		cloudServiceProvider.oaNum = 0
+		cloudServiceProvider.apDone = make(map[string]bool)
+		cloudServiceProvider.dataCollectionToOA()
+
+	}
+}
+
+// Stop sends the queued replies and closes the socket, the listener returns
+func (cloudServiceProvider *CloudServiceProvider) Stop() {
+	close(cloudServiceProvider.stop)
+	cloudServiceProvider.out.Close()
+	cloudServiceProvider.Socket.Close()
+}
@@ -0,0 +1,186 @@
+package nptm
+
+import (
+	"NPTM/ap"
+	"NPTM/blockchain"
+	"NPTM/config"
+	"NPTM/csp"
+	"NPTM/oa"
+	"NPTM/shuffle"
+	"NPTM/ue"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+// TestMemoryDeployment registers a CSP, two OAs, an AP and its UEs over the in-memory transport
+// and runs one list maintenance round
+func TestMemoryDeployment(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a whole round")
+	}
+	runDeployment(t, "pow", 0)
+}
+
+// TestMemoryDeploymentBFT runs the round of TestMemoryDeployment with the block agreed on by BFT
+func TestMemoryDeploymentBFT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a whole round")
+	}
+	conf, oas := runDeployment(t, "bft", 100)
+	//every block of a consensus round carries the collective signature of the OAs
+	blocks := oas[0].BlockChain.Blocks
+	var agreed int
+	for _, b := range blocks {
+		if header := b.Header(); header.Mined() {
+			if b.Mode != blockchain.BFT {
+				t.Fatalf("block %d is mined under bft", b.K0)
+			}
+			//both OAs signed, Quorum(2) is 2
+			if err := header.CheckCommit(conf.OAKeys()); err != nil || header.SignerCount() != 2 {
+				t.Fatalf("block %d: commit of %d signers: %v", b.K0, header.SignerCount(), err)
+			}
+			agreed++
+		}
+	}
+	if agreed == 0 {
+		t.Fatal("no block of a consensus round")
+	}
+}
+
+// runDeployment runs the round with the consensus, the ports of the entities are moved by offset so
+// the deployments do not share addresses. The config and the stopped OAs are returned.
+func runDeployment(t *testing.T, consensus string, offset int) (*config.Config, []*oa.OperatorAgent) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	conf := config.Default()
+	conf.Conn = config.Conn{Transport: "mem", CSPIP: "127.0.0.1", CSPPort: 12345 + offset, OAIP: "127.0.0.1", OAPort: 10000 + offset,
+		APIP: "127.0.0.1", APPort: 8000 + offset, UEIP: "127.0.0.1"}
+	conf.Protocol.Consensus = consensus
+	var keys []kyber.Scalar
+	//with pow only the first key is pinned, the OAs and the AP learn the other one from the CSP.
+	//bft needs all of them.
+	for i := 0; i < 2; i++ {
+		key := suite.Scalar().Pick(suite.RandomStream())
+		keys = append(keys, key)
+		oaConf := config.OA{Addr: fmt.Sprintf("127.0.0.1:%d", 10000+offset+i)}
+		if i == 0 || consensus == "bft" {
+			public, _ := suite.Point().Mul(key, nil).MarshalBinary()
+			oaConf.PublicKey = hex.EncodeToString(public)
+		}
+		conf.Topology = append(conf.Topology, oaConf)
+	}
+	cspKey := suite.Scalar().Pick(suite.RandomStream())
+	public, _ := suite.Point().Mul(cspKey, nil).MarshalBinary()
+	conf.CSP.PublicKey = hex.EncodeToString(public)
+	apAddr := fmt.Sprintf("127.0.0.1:%d", 8000+offset)
+	conf.APs = []config.AP{{Addr: apAddr, Dataset: "datasets/dataset1.csv"}}
+	conf.Protocol.Theta, conf.Protocol.Interval = 600, 300
+	conf.Protocol.ConsensusRounds, conf.Protocol.ListMaintenanceRounds = 1, 1
+	conf.DataDir = t.TempDir()
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := csp.New(conf, "", cspKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop()
+	var oas []*oa.OperatorAgent
+	for i, addr := range conf.OAAddrs() {
+		o, err := oa.New(conf, addr, "", keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Start()
+		oas = append(oas, o)
+	}
+	p, err := ap.New(conf, apAddr, "", conf.Topology[0].Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	defer p.Stop()
+	for i := 0; i < 2; i++ {
+		u, err := ue.New(conf, fmt.Sprintf("127.0.0.1:%d", 20000+offset+i), apAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Start()
+		defer u.Stop()
+		u.WaitRegistered()
+	}
+
+	registered := make(chan struct{})
+	go func() {
+		c.WaitForRegistrations(len(oas), 1)
+		for _, o := range oas {
+			o.WaitForAPs(0)
+		}
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(time.Minute):
+		t.Fatal("the OAs and the AP did not register with the CSP")
+	}
+
+	var wg sync.WaitGroup
+	for _, o := range oas {
+		wg.Add(1)
+		go func(o *oa.OperatorAgent) { defer wg.Done(); o.Run(1, 1) }(o)
+	}
+	go p.Run(1)
+	go c.Run(1)
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Minute):
+		t.Fatal("the round did not end")
+	}
+	for _, o := range oas {
+		o.Stop()
+	}
+
+	//both OAs hold the same chain with the block of the round
+	first := oas[0].BlockChain.Blocks
+	if len(first) < 2 {
+		t.Fatalf("the chain has %d blocks after the round", len(first))
+	}
+	for i, o := range oas[1:] {
+		blocks := o.BlockChain.Blocks
+		if len(blocks) != len(first) || !bytes.Equal(blocks[len(blocks)-1].SignBK, first[len(first)-1].SignBK) {
+			t.Fatalf("OA %d ends the round with another chain", i+1)
+		}
+	}
+
+	//the archived hops of the round verify and link up, the forward shuffle to the reverse one
+	rounds := make(map[bool][]*shuffle.Transcript)
+	for _, addr := range conf.OAAddrs() {
+		transcripts, err := shuffle.LoadTranscripts(conf.TranscriptPath(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range transcripts {
+			if err := tr.Verify(suite, 0, true); err != nil {
+				t.Fatalf("hop %d forward %v: %v", tr.Hop, tr.Forward, err)
+			}
+			rounds[tr.Forward] = append(rounds[tr.Forward], tr)
+		}
+	}
+	if err := shuffle.CheckRound(suite, rounds[false], len(oas), nil); err != nil {
+		t.Fatal("reverse shuffle: ", err)
+	}
+	if err := shuffle.CheckRound(suite, rounds[true], len(oas), rounds[false]); err != nil {
+		t.Fatal("forward shuffle: ", err)
+	}
+	return conf, oas
+}
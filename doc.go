@@ -2,5 +2,13 @@
 
 /*
 NPTM document
+
+The entities are the packages csp, oa, ap and ue, every node owns its state and is started with
+New and Start, so several nodes can run in one process. The binaries in cmd/ wrap them:
+
+	go run ./cmd/csp
+	go run ./cmd/oa
+	go run ./cmd/ap
+	go run ./cmd/ue
 */
-package main
+package nptm
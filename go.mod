@@ -3,21 +3,21 @@ module NPTM
 go 1.20
 
 require (
-	github.com/izqui/helpers v0.0.0-20150821122028-c69cdb8bbd19 // indirect
-	github.com/shopspring/decimal v1.3.1 // indirect
-	github.com/xsleonard/go-merkle v1.1.0 // indirect
+	github.com/izqui/helpers v0.0.0-20150821122028-c69cdb8bbd19
+	github.com/shopspring/decimal v1.3.1
+	go.dedis.ch/kyber/v4 v4.0.0-pre2
+	go.dedis.ch/protobuf v1.0.11
+)
+
+require (
 	go.dedis.ch/fixbuf v1.0.3 // indirect
-	go.dedis.ch/kyber/v4 v4.0.0-pre2 // indirect
-	go.dedis.ch/protobuf v1.0.11 // indirect
 	golang.org/x/crypto v0.0.0-20190123085648-057139ce5d2b // indirect
 	golang.org/x/sys v0.0.0-20190124100055-b90733256f2e // indirect
 )
 
-
 //go.mod 文件是 Go 语言中的一个模块文件，用于管理项目的依赖关系。它是 Go 模块系统的一部分，定义了项目所依赖的模块及其版本，并提供了项目的模块路径。
 //go.mod 文件的内容
 //一个典型的 go.mod 文件包含以下几部分：
 //模块路径：模块的路径，通常是代码库的根路径。
 //Go 版本：项目使用的 Go 版本。
 //依赖模块：项目所依赖的其他模块及其版本。
-
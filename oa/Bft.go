@@ -0,0 +1,551 @@
+package oa
+
+import (
+	"NPTM/blockchain"
+	"NPTM/proto"
+	"NPTM/util"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/sign/cosi"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+// With protocol.consensus "bft" the block of a consensus round is not mined but agreed on in views.
+// The proposer of a view, the OAs take turns by height and view, sends a block. Every OA prevotes
+// it if it holds the records and the list of the round, and precommits it once a quorum of OAs
+// prevoted it. The OAs that precommitted it sign it collectively (CoSi): their precommits carry a
+// commitment, the proposer sends the challenge of a quorum of them, collects the responses and sends
+// the final block with the signature and the bitmap of the signers to every OA. An OA that precommitted a block is locked on it and prevotes no other block until a
+// later view has a quorum of prevotes for one, so two blocks can not both get a quorum of precommits.
+// A view without a decision ends after bft_timeout_ms per phase and the next proposer takes over.
+// //BFT共识：提议、预投票、预提交，各需法定人数的签名投票
+
+//bftHeight is the state of the agreement on the block at one height
+type bftHeight struct {
+	//the proposal and the votes of every view, the votes by signer
+	proposals  map[int64]*blockchain.Block
+	prevotes   map[int64]map[int64]*proto.Vote
+	precommits map[int64]map[int64]*proto.Vote
+	//the block this OA precommitted last and the view it did
+	locked     *blockchain.Block
+	lockedView int64
+	//the CoSi secrets of this OA's precommits by view, each answers one challenge
+	secrets map[int64]kyber.Scalar
+	//the collective signatures this OA collects as proposer, by view
+	cosigns map[int64]*bftCosign
+	//the final block with its commit
+	decided *blockchain.Block
+}
+
+//bftCosign is the collective signature of a precommitted block the proposer of the view collects
+type bftCosign struct {
+	block      *blockchain.Block
+	mask       *cosi.Mask
+	commitment kyber.Point
+	challenge  kyber.Scalar
+	responses  map[int64]kyber.Scalar
+}
+
+//bftAt returns the state of the agreement at height, the heights of the next two blocks are kept:
+//an OA may get the votes of the next round before it added the block of the list  //保留接下来两个高度的投票
+func (operatorAgent *OperatorAgent) bftAt(height int64) *bftHeight {
+	next := int64(len(operatorAgent.BlockChain.Blocks))
+	if height < next || height > next+1 {
+		return nil
+	}
+	round, ok := operatorAgent.bft[height]
+	if !ok {
+		round = &bftHeight{make(map[int64]*blockchain.Block), make(map[int64]map[int64]*proto.Vote),
+			make(map[int64]map[int64]*proto.Vote), nil, -1, make(map[int64]kyber.Scalar), make(map[int64]*bftCosign), nil}
+		operatorAgent.bft[height] = round
+	}
+	return round
+}
+
+//proposer is the OA that proposes the block at height in view
+func (operatorAgent *OperatorAgent) proposer(height, view int64) net.Addr {
+	return operatorAgent.OAList[(height+view)%int64(len(operatorAgent.OAList))]
+}
+
+//signer is the position of the OA at addr in the topology, -1 for another address
+func (operatorAgent *OperatorAgent) signer(addr net.Addr) int64 {
+	for i, oa := range operatorAgent.OAList {
+		if oa.String() == addr.String() {
+			return int64(i)
+		}
+	}
+	return -1
+}
+
+//oaKeys are the keys of the OAs in topology order, nil while one is missing
+func (operatorAgent *OperatorAgent) oaKeys() []kyber.Point {
+	keys := make([]kyber.Point, len(operatorAgent.OAList))
+	for i, oa := range operatorAgent.OAList {
+		key, ok := operatorAgent.OAKeyList[oa.String()]
+		if !ok {
+			return nil
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+//checkCommit verifies the commit of a BFT block with the keys of the topology
+func (operatorAgent *OperatorAgent) checkCommit(block *blockchain.Block) error {
+	keys := operatorAgent.oaKeys()
+	if keys == nil {
+		return errors.New("the keys of the OAs are unknown")
+	}
+	header := block.Header()
+	return header.CheckCommit(keys)
+}
+
+//bftConsensus agrees with the other OAs on the block of this consensus round and leaves it in
+//winner_block. The caller holds the lock.
+func (operatorAgent *OperatorAgent) bftConsensus() {
+	timeout := time.Duration(operatorAgent.conf.Protocol.BFTTimeout) * time.Millisecond
+	n := len(operatorAgent.OAList)
+	quorum := blockchain.Quorum(n)
+	tip := operatorAgent.BlockChain.PreviousBlock()
+	height := tip.Nb + 1
+	round := operatorAgent.bftAt(height)
+	if round.decided != nil && !bytes.Equal(round.decided.PreHash, tip.BlockHash()) {
+		fmt.Println("[OA] Drop the commit of block", round.decided.K0, ", it does not follow the local chain.")
+		round.decided = nil
+	}
+
+	for view := int64(0); round.decided == nil; view++ {
+		proposer := operatorAgent.proposer(height, view)
+		fmt.Println("[OA] BFT height", height, "view", view, ", the proposer is", proposer)
+
+		//propose: the locked block or a new one  //提议
+		if proposer.String() == operatorAgent.LocalAddress.String() {
+			block := round.locked
+			if block == nil {
+				block = operatorAgent.proposeBlock()
+			}
+			round.proposals[view] = block
+			operatorAgent.broadcastOAs(proto.BFT_PROPOSAL, &proto.Proposal{Block: blockchain.ToByteBlock(*block), View: view})
+		}
+		operatorAgent.waitTimeout(timeout, func() bool { return round.proposals[view] != nil || round.decided != nil })
+		if round.decided != nil {
+			break
+		}
+
+		//prevote the proposal if it is valid and does not conflict with the lock  //预投票
+		var prevote []byte
+		if proposal := round.proposals[view]; proposal != nil {
+			hash := proposal.BlockHash()
+			if err := operatorAgent.checkProposal(proposal, tip); err != nil {
+				fmt.Println("[OA] Prevote no block in view", view, ":", err)
+			} else if round.locked != nil && !bytes.Equal(round.locked.BlockHash(), hash) && round.polka(hash, quorum) <= round.lockedView {
+				fmt.Println("[OA] Prevote no block in view", view, ", this OA is locked on block", round.locked.K0, "of view", round.lockedView)
+			} else {
+				prevote = hash
+			}
+		}
+		operatorAgent.sendVote(proto.BFT_PREVOTE, height, view, prevote)
+		operatorAgent.waitTimeout(timeout, func() bool {
+			_, ok := quorumHash(round.prevotes[view], quorum)
+			return ok || len(round.prevotes[view]) == n || round.decided != nil
+		})
+		if round.decided != nil {
+			break
+		}
+
+		//precommit the proposal once a quorum prevoted it  //预提交
+		var precommit []byte
+		if hash, ok := quorumHash(round.prevotes[view], quorum); ok && hash != nil {
+			if proposal := round.proposals[view]; proposal != nil && bytes.Equal(proposal.BlockHash(), hash) {
+				round.locked = proposal
+				round.lockedView = view
+				precommit = hash
+			}
+		}
+		operatorAgent.sendVote(proto.BFT_PRECOMMIT, height, view, precommit)
+		//a quorum of precommits waits for the collective signature
+		operatorAgent.waitTimeout(timeout, func() bool {
+			hash, ok := quorumHash(round.precommits[view], quorum)
+			return round.decided != nil || (len(round.precommits[view]) == n && (!ok || hash == nil))
+		})
+		if round.decided == nil {
+			fmt.Println("[OA] No decision in view", view, ", move to the next view.")
+		}
+	}
+
+	header := round.decided.Header()
+	fmt.Println("[OA] BFT decided block", round.decided.K0, ", signed by", header.SignerCount(), "OAs.")
+	operatorAgent.winner_block = round.decided
+	for h := range operatorAgent.bft {
+		if h <= height {
+			delete(operatorAgent.bft, h)
+		}
+	}
+}
+
+//proposeBlock builds the block of this round without the work, its hash is agreed on  //构造提议区块，无需挖矿
+func (operatorAgent *OperatorAgent) proposeBlock() *blockchain.Block {
+	previousBlock := operatorAgent.BlockChain.PreviousBlock()
+	Nyms, Vals, MerkelRoot1 := operatorAgent.listConversion(nil)
+	items0 := [][]byte{(util.ToByteRecords(operatorAgent.Records))}
+	Pk, _ := operatorAgent.PublicKey.MarshalBinary()
+	block := &blockchain.Block{K0: previousBlock.K0 + 1, Timestamp: time.Now().UnixMilli(), PreHash: previousBlock.BlockHash(), Nb: int64(len(operatorAgent.BlockChain.Blocks)),
+		Npk: operatorAgent.Npk, Nd: int64(len(operatorAgent.Records)), MerkelRoot0: blockchain.GetMerkleRoot(items0), MerkelRoot1: MerkelRoot1,
+		PublicKey: Pk, Mode: blockchain.BFT, Nyms: Nyms, Vals: Vals}
+	operatorAgent.signBlock(block)
+	return block
+}
+
+//checkProposal checks that the proposal follows the tip and holds the records and the list of this round
+func (operatorAgent *OperatorAgent) checkProposal(block *blockchain.Block, tip *blockchain.Block) error {
+	tipHeader := tip.Header()
+	header := block.Header()
+	if err := header.Follows(&tipHeader, int(tip.Nb)+1); err != nil {
+		return err
+	}
+	if err := header.CheckTime(&tipHeader, time.Now().UnixMilli(), int64(operatorAgent.conf.Protocol.ClockSkew)); err != nil {
+		return err
+	}
+	if !operatorAgent.roundBlock(block) {
+		return errors.New("the block does not hold the records and the list of this round")
+	}
+	return nil
+}
+
+//sendVote signs a prevote or precommit, counts it and sends it to the other OAs
+func (operatorAgent *OperatorAgent) sendVote(eventType int, height, view int64, hash []byte) {
+	message := blockchain.PrevoteMessage(height, view, hash)
+	if eventType == proto.BFT_PRECOMMIT {
+		message = blockchain.PrecommitMessage(height, view, hash)
+	}
+	vote := &proto.Vote{Height: height, View: view, Hash: hash,
+		Sign: util.SchnorrSign(operatorAgent.Suite, random.New(), message, operatorAgent.PrivateKey)}
+	if eventType == proto.BFT_PRECOMMIT && hash != nil {
+		//the commitment of this OA's share in the collective signature  //CoSi 承诺
+		secret, commitment := cosi.Commit(operatorAgent.Suite)
+		operatorAgent.bftAt(height).secrets[view] = secret
+		vote.Commitment, _ = commitment.MarshalBinary()
+	}
+	operatorAgent.countVote(eventType, vote, operatorAgent.signer(operatorAgent.LocalAddress))
+	operatorAgent.broadcastOAs(eventType, vote)
+}
+
+//broadcastOAs sends the message to every other OA of the topology
+func (operatorAgent *OperatorAgent) broadcastOAs(eventType int, msg proto.Message) {
+	event := util.Encode(proto.NewEvent(eventType, msg))
+	for _, addr := range operatorAgent.OAList {
+		if addr.String() != operatorAgent.LocalAddress.String() {
+			operatorAgent.out.Send(addr, event)
+		}
+	}
+}
+
+//handleProposal keeps the proposal of a view, it is checked against the round when it is prevoted
+func (operatorAgent *OperatorAgent) handleProposal(msg *proto.Proposal, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus != "bft" {
+		fmt.Println("[OA] Reject the BFT proposal from", addr, ", the blocks are mined.")
+		return
+	}
+	block, err := blockchain.DecodeBlock(msg.Block)
+	if err != nil {
+		fmt.Println("[OA] Reject the proposal, it can not be decoded:", err)
+		return
+	}
+	round := operatorAgent.bftAt(block.Nb)
+	if round == nil {
+		fmt.Println("[OA] Drop the proposal of height", block.Nb, "from", addr)
+		return
+	}
+	if operatorAgent.proposer(block.Nb, msg.View).String() != addr.String() {
+		fmt.Println("[OA] Reject the proposal from", addr, ", it is not the proposer of view", msg.View)
+		return
+	}
+	if _, ok := round.proposals[msg.View]; ok {
+		return
+	}
+	header := block.Header()
+	if block.Mode != blockchain.BFT {
+		err = errors.New("not a BFT block")
+	} else if err = operatorAgent.checkCreator(&header); err == nil {
+		err = block.Check()
+	}
+	if err != nil {
+		fmt.Println("[OA] Reject the proposal from", addr, ":", err)
+		return
+	}
+	round.proposals[msg.View] = &block
+}
+
+//handleVote counts the prevote or precommit of the OA at addr
+func (operatorAgent *OperatorAgent) handleVote(eventType int, msg *proto.Vote, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus != "bft" {
+		fmt.Println("[OA] Reject the BFT vote from", addr, ", the blocks are mined.")
+		return
+	}
+	message := blockchain.PrevoteMessage(msg.Height, msg.View, msg.Hash)
+	if eventType == proto.BFT_PRECOMMIT {
+		message = blockchain.PrecommitMessage(msg.Height, msg.View, msg.Hash)
+	}
+	if err := util.SchnorrVerify(operatorAgent.Suite, message, operatorAgent.OAKeyList[addr.String()], msg.Sign); err != nil {
+		fmt.Println("[OA] Reject the vote from", addr, ": the signature does not verify")
+		return
+	}
+	if eventType == proto.BFT_PRECOMMIT && len(msg.Hash) != 0 {
+		if err := operatorAgent.Suite.Point().UnmarshalBinary(msg.Commitment); err != nil {
+			fmt.Println("[OA] Reject the precommit from", addr, ": invalid commitment")
+			return
+		}
+	}
+	operatorAgent.countVote(eventType, msg, operatorAgent.signer(addr))
+}
+
+//countVote keeps the first vote of every signer in a view, a quorum of precommits starts the collective signature
+func (operatorAgent *OperatorAgent) countVote(eventType int, vote *proto.Vote, signer int64) {
+	round := operatorAgent.bftAt(vote.Height)
+	if round == nil || signer < 0 {
+		return
+	}
+	votes := round.prevotes
+	if eventType == proto.BFT_PRECOMMIT {
+		votes = round.precommits
+	}
+	if votes[vote.View] == nil {
+		votes[vote.View] = make(map[int64]*proto.Vote)
+	}
+	if _, ok := votes[vote.View][signer]; ok {
+		return
+	}
+	votes[vote.View][signer] = vote
+	if eventType == proto.BFT_PRECOMMIT {
+		operatorAgent.startCosign(round, vote.Height, vote.View)
+	}
+}
+
+//startCosign is run by the proposer of the view once a quorum precommitted its block: the signers
+//are fixed and get the challenge of their aggregate commitment  //提议者在法定人数预提交后发起集体签名
+func (operatorAgent *OperatorAgent) startCosign(round *bftHeight, height, view int64) {
+	if round.decided != nil || round.cosigns[view] != nil || operatorAgent.proposer(height, view).String() != operatorAgent.LocalAddress.String() {
+		return
+	}
+	hash, ok := quorumHash(round.precommits[view], blockchain.Quorum(len(operatorAgent.OAList)))
+	block := round.proposals[view]
+	if !ok || hash == nil || block == nil || !bytes.Equal(block.BlockHash(), hash) {
+		return
+	}
+	mask, err := cosi.NewMask(operatorAgent.Suite, operatorAgent.oaKeys(), nil)
+	if err != nil {
+		fmt.Println("[OA] Can not sign block", block.K0, "collectively:", err)
+		return
+	}
+	commitment := operatorAgent.Suite.Point().Null()
+	for signer, vote := range round.precommits[view] {
+		if !bytes.Equal(vote.Hash, hash) {
+			continue
+		}
+		point := operatorAgent.Suite.Point()
+		if err := point.UnmarshalBinary(vote.Commitment); err != nil {
+			continue
+		}
+		mask.SetBit(int(signer), true)
+		commitment.Add(commitment, point)
+	}
+	challenge, err := cosi.Challenge(operatorAgent.Suite, commitment, mask.AggregatePublic, hash)
+	if err != nil {
+		fmt.Println("[OA] Can not sign block", block.K0, "collectively:", err)
+		return
+	}
+	round.cosigns[view] = &bftCosign{block, mask, commitment, challenge, make(map[int64]kyber.Scalar)}
+	fmt.Println("[OA] A quorum precommitted block", block.K0, "in view", view, ", collect the signatures of", mask.CountEnabled(), "OAs.")
+
+	byteCommitment, _ := commitment.MarshalBinary()
+	operatorAgent.broadcastOAs(proto.BFT_CHALLENGE, &proto.Challenge{Height: height, View: view, Hash: hash, Commitment: byteCommitment, Signers: mask.Mask()})
+	self := operatorAgent.signer(operatorAgent.LocalAddress)
+	if enabled, _ := mask.IndexEnabled(int(self)); enabled {
+		response, err := operatorAgent.cosignResponse(round, view, hash, mask, commitment)
+		if err != nil {
+			fmt.Println("[OA] Can not sign block", block.K0, "collectively:", err)
+			return
+		}
+		operatorAgent.addResponse(round, height, view, self, response)
+	}
+}
+
+//cosignResponse is this OA's share of the collective signature of the block it precommitted in view.
+//The secret of the commitment is used for one challenge only.
+func (operatorAgent *OperatorAgent) cosignResponse(round *bftHeight, view int64, hash []byte, mask *cosi.Mask, commitment kyber.Point) (kyber.Scalar, error) {
+	own := round.precommits[view][operatorAgent.signer(operatorAgent.LocalAddress)]
+	if own == nil || !bytes.Equal(own.Hash, hash) {
+		return nil, errors.New("this OA did not precommit the block")
+	}
+	secret, ok := round.secrets[view]
+	if !ok {
+		return nil, errors.New("the commitment was already used")
+	}
+	delete(round.secrets, view)
+	challenge, err := cosi.Challenge(operatorAgent.Suite, commitment, mask.AggregatePublic, hash)
+	if err != nil {
+		return nil, err
+	}
+	return cosi.Response(operatorAgent.Suite, operatorAgent.PrivateKey, secret, challenge)
+}
+
+//handleChallenge answers the challenge of the proposer of the view with this OA's share
+func (operatorAgent *OperatorAgent) handleChallenge(msg *proto.Challenge, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus != "bft" {
+		fmt.Println("[OA] Reject the BFT challenge from", addr, ", the blocks are mined.")
+		return
+	}
+	round := operatorAgent.bftAt(msg.Height)
+	if round == nil || operatorAgent.proposer(msg.Height, msg.View).String() != addr.String() {
+		fmt.Println("[OA] Drop the challenge from", addr, ", it is not the proposer of height", msg.Height, "view", msg.View)
+		return
+	}
+	mask, err := cosi.NewMask(operatorAgent.Suite, operatorAgent.oaKeys(), nil)
+	if err == nil {
+		err = mask.SetMask(msg.Signers)
+	}
+	if err != nil {
+		fmt.Println("[OA] Reject the challenge from", addr, ":", err)
+		return
+	}
+	if enabled, _ := mask.IndexEnabled(int(operatorAgent.signer(operatorAgent.LocalAddress))); !enabled {
+		return
+	}
+	commitment := operatorAgent.Suite.Point()
+	if err := commitment.UnmarshalBinary(msg.Commitment); err != nil {
+		fmt.Println("[OA] Reject the challenge from", addr, ": invalid commitment")
+		return
+	}
+	response, err := operatorAgent.cosignResponse(round, msg.View, msg.Hash, mask, commitment)
+	if err != nil {
+		fmt.Println("[OA] Reject the challenge from", addr, ":", err)
+		return
+	}
+	byteResponse, _ := response.MarshalBinary()
+	event := proto.NewEvent(proto.BFT_RESPONSE, &proto.Response{Height: msg.Height, View: msg.View, Response: byteResponse})
+	operatorAgent.out.Send(addr, util.Encode(event))
+}
+
+//handleResponse takes the share of a signer
+func (operatorAgent *OperatorAgent) handleResponse(msg *proto.Response, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus != "bft" {
+		fmt.Println("[OA] Reject the BFT response from", addr, ", the blocks are mined.")
+		return
+	}
+	round := operatorAgent.bftAt(msg.Height)
+	if round == nil {
+		return
+	}
+	response := operatorAgent.Suite.Scalar()
+	if err := response.UnmarshalBinary(msg.Response); err != nil {
+		fmt.Println("[OA] Reject the response from", addr, ": invalid response")
+		return
+	}
+	operatorAgent.addResponse(round, msg.Height, msg.View, operatorAgent.signer(addr), response)
+}
+
+//addResponse checks a signer's share against its commitment, with all shares the block is final
+//and sent with its collective signature to the other OAs
+func (operatorAgent *OperatorAgent) addResponse(round *bftHeight, height, view, signer int64, response kyber.Scalar) {
+	cosign := round.cosigns[view]
+	if cosign == nil || round.decided != nil || signer < 0 {
+		return
+	}
+	if enabled, err := cosign.mask.IndexEnabled(int(signer)); err != nil || !enabled {
+		return
+	}
+	if _, ok := cosign.responses[signer]; ok {
+		return
+	}
+	//r_i*G = V_i + c*A_i
+	commitment := operatorAgent.Suite.Point()
+	if err := commitment.UnmarshalBinary(round.precommits[view][signer].Commitment); err != nil {
+		return
+	}
+	key := operatorAgent.oaKeys()[signer]
+	expected := operatorAgent.Suite.Point().Add(commitment, operatorAgent.Suite.Point().Mul(cosign.challenge, key))
+	if !operatorAgent.Suite.Point().Mul(response, nil).Equal(expected) {
+		fmt.Println("[OA] Reject the response of", operatorAgent.OAList[signer], ", it does not match its commitment")
+		return
+	}
+	cosign.responses[signer] = response
+	if len(cosign.responses) < cosign.mask.CountEnabled() {
+		return
+	}
+
+	responses := make([]kyber.Scalar, 0, len(cosign.responses))
+	for _, r := range cosign.responses {
+		responses = append(responses, r)
+	}
+	aggregate, err := cosi.AggregateResponses(operatorAgent.Suite, responses)
+	var signature []byte
+	if err == nil {
+		signature, err = cosi.Sign(operatorAgent.Suite, cosign.commitment, aggregate, cosign.mask)
+	}
+	if err != nil {
+		fmt.Println("[OA] Can not sign block", cosign.block.K0, "collectively:", err)
+		return
+	}
+	decided := *cosign.block
+	decided.CoSign = signature[:len(signature)-cosign.mask.Len()]
+	decided.Signers = cosign.mask.Mask()
+	round.decided = &decided
+	fmt.Println("[OA] Block", decided.K0, "is signed by", len(responses), "OAs.")
+	operatorAgent.broadcastOAs(proto.BFT_COMMIT, &proto.Proposal{Block: blockchain.ToByteBlock(decided), View: view})
+}
+
+//handleCommit takes the final block of a height from the proposer, its collective signature proves the quorum
+func (operatorAgent *OperatorAgent) handleCommit(msg *proto.Proposal, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus != "bft" {
+		fmt.Println("[OA] Reject the BFT commit from", addr, ", the blocks are mined.")
+		return
+	}
+	block, err := blockchain.DecodeBlock(msg.Block)
+	if err != nil {
+		fmt.Println("[OA] Reject the commit, it can not be decoded:", err)
+		return
+	}
+	round := operatorAgent.bftAt(block.Nb)
+	if round == nil || round.decided != nil {
+		return
+	}
+	if tip := operatorAgent.BlockChain.PreviousBlock(); block.Nb == tip.Nb+1 && !bytes.Equal(block.PreHash, tip.BlockHash()) {
+		err = errors.New("the block does not follow the local chain")
+	} else if err = block.Check(); err == nil {
+		err = operatorAgent.checkCommit(&block)
+	}
+	if err != nil {
+		fmt.Println("[OA] Reject the commit from", addr, ":", err)
+		return
+	}
+	fmt.Println("[OA] Take the commit of block", block.K0, "from", addr)
+	round.decided = &block
+}
+
+//quorumHash is the hash that at least quorum votes carry, nil for no block
+func quorumHash(votes map[int64]*proto.Vote, quorum int) ([]byte, bool) {
+	count := make(map[string]int)
+	for _, vote := range votes {
+		count[string(vote.Hash)]++
+		if count[string(vote.Hash)] >= quorum {
+			return vote.Hash, true
+		}
+	}
+	return nil, false
+}
+
+//polka is the latest view in which a quorum prevoted the block with this hash, -1 if none did
+func (round *bftHeight) polka(hash []byte, quorum int) int64 {
+	latest := int64(-1)
+	for view, votes := range round.prevotes {
+		if q, ok := quorumHash(votes, quorum); ok && bytes.Equal(q, hash) && view > latest {
+			latest = view
+		}
+	}
+	return latest
+}
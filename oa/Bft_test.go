@@ -0,0 +1,149 @@
+package oa
+
+import (
+	"NPTM/blockchain"
+	"NPTM/config"
+	"NPTM/util"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//testCluster is the config of n OAs at port..port+n-1 of the in-memory network with pinned keys
+//and the private keys of the OAs
+func testCluster(t *testing.T, n, port int, consensus string) (*config.Config, []kyber.Scalar) {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	conf := config.Default()
+	conf.Conn = config.Conn{Transport: "mem", CSPIP: "127.0.0.1", CSPPort: 12345, OAIP: "127.0.0.1", OAPort: port,
+		APIP: "127.0.0.1", APPort: 8000, UEIP: "127.0.0.1"}
+	conf.Protocol.Consensus = consensus
+	conf.Protocol.BFTTimeout = 1000
+	conf.Protocol.NormalModel = "../datasets/normal_model.csv"
+	conf.Protocol.AbnormalModel = "../datasets/abnormal_model.csv"
+	conf.APs = []config.AP{{Addr: "127.0.0.1:8000", Dataset: "../datasets/dataset1.csv"}}
+	cspKey, _ := suite.Point().Pick(suite.RandomStream()).MarshalBinary()
+	conf.CSP.PublicKey = hex.EncodeToString(cspKey)
+	keys := make([]kyber.Scalar, n)
+	for i := range keys {
+		keys[i] = suite.Scalar().Pick(suite.RandomStream())
+		public, _ := suite.Point().Mul(keys[i], nil).MarshalBinary()
+		conf.Topology = append(conf.Topology, config.OA{Addr: fmt.Sprintf("127.0.0.1:%d", port+i), PublicKey: hex.EncodeToString(public)})
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return conf, keys
+}
+
+//startCluster starts the listeners of the OAs of the topology except the silent one, they hold the
+//same genesis block, list and records. The CSP is not needed, the topology pins the keys.
+func startCluster(t *testing.T, conf *config.Config, keys []kyber.Scalar, silent int) []*OperatorAgent {
+	oas := make([]*OperatorAgent, len(keys))
+	list := testNyms("cluster", 3)
+	var genesis *blockchain.Block
+	for i := len(keys) - 1; i >= 0; i-- {
+		if i == silent {
+			continue
+		}
+		o, err := New(conf, conf.Topology[i].Addr, "", keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(o.Stop)
+		for j, nym := range list {
+			o.Listm = append(o.Listm, util.Pair{Nym: nym, Val: float64(j) / 10})
+		}
+		if genesis == nil {
+			genesis = o.genesisBlock(nil)
+		}
+		block := *genesis
+		if _, err := o.BlockChain.AddBlock(&block); err != nil {
+			t.Fatal(err)
+		}
+		go o.startOAListener()
+		oas[i] = o
+	}
+	return oas
+}
+
+//agree runs bftConsensus on every running OA and returns the block each decided
+func agree(t *testing.T, oas []*OperatorAgent) map[int]*blockchain.Block {
+	type decision struct {
+		oa    int
+		block *blockchain.Block
+	}
+	decided := make(chan decision, len(oas))
+	running := 0
+	for i, o := range oas {
+		if o == nil {
+			continue
+		}
+		running++
+		go func(i int, o *OperatorAgent) {
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			o.bftConsensus()
+			decided <- decision{i, o.winner_block}
+		}(i, o)
+	}
+	blocks := make(map[int]*blockchain.Block)
+	for len(blocks) < running {
+		select {
+		case d := <-decided:
+			blocks[d.oa] = d.block
+		case <-time.After(time.Minute):
+			t.Fatalf("%d of %d OAs decided", len(blocks), running)
+		}
+	}
+	return blocks
+}
+
+// four OAs agree on a block of the proposer of the first view, with a silent proposer the view
+// times out and the next proposer's block is agreed on
+func TestBFTConsensus(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		port     int
+		silent   int
+		proposer int
+	}{
+		//the proposer of height 1 in view v is OA (1+v)%4
+		{"all OAs", 11000, -1, 1},
+		{"silent proposer", 11100, 1, 2},
+	} {
+		conf, keys := testCluster(t, 4, c.port, "bft")
+		oas := startCluster(t, conf, keys, c.silent)
+		blocks := agree(t, oas)
+
+		var first *blockchain.Block
+		for i, block := range blocks {
+			if first == nil {
+				first = block
+			}
+			if !bytes.Equal(block.BlockHash(), first.BlockHash()) || !bytes.Equal(block.CoSign, first.CoSign) {
+				t.Fatalf("%s: OA %d decided another block", c.name, i)
+			}
+		}
+		header := first.Header()
+		if err := header.CheckCommit(conf.OAKeys()); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if header.SignerCount() < blockchain.Quorum(4) {
+			t.Fatalf("%s: %d signers", c.name, header.SignerCount())
+		}
+		proposer, _ := conf.Topology[c.proposer].Key.MarshalBinary()
+		if first.Nb != 1 || first.Mode != blockchain.BFT || !bytes.Equal(first.PublicKey, proposer) {
+			t.Fatalf("%s: the block is not the one of OA %d at height 1", c.name, c.proposer)
+		}
+		if c.silent >= 0 {
+			if enabled := first.Signers[0]&(1<<uint(c.silent)) != 0; enabled {
+				t.Fatalf("%s: the silent OA signed", c.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,2444 @@
+// Package oa is the operator agent, OAs shuffle the pseudonyms, evaluate the trust values and keep the blockchain
+package oa
+
+import (
+	"NPTM/blockchain"
+	"NPTM/config"
+	"NPTM/proto"
+	"NPTM/transport"
+	"NPTM/shuffle"
+	"NPTM/util"
+	"bytes"
+	"crypto/cipher"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/proof"
+	"go.dedis.ch/kyber/v4/suites"
+	"go.dedis.ch/kyber/v4/util/random"
+)
+
+type OperatorAgent struct {
+	// client-side config
+	LocalAddress net.Addr
+	Socket       *transport.SecureTransport //every event is encrypted and authenticated with the long-term keys
+	// crypto variables
+	Suite      suites.Suite
+	PrivateKey kyber.Scalar
+	PublicKey  kyber.Point
+	G          kyber.Point
+
+	//num of ready OA
+	ReadyOANum int
+	//Mining status
+	MineStatus int
+	//Shuffle status
+	Status int
+	// network topology for OA cluster
+	OAList []net.Addr
+	//Stored OA's public key&&addr
+	OAKeyList map[string]kyber.Point
+	//APs belongs to this OA
+	APList []net.Addr
+	//Stored AP's pk(adr->pk)
+	APKeyList map[string]kyber.Point
+	//CSP address
+	CSPAddress net.Addr
+	//Stored CSP's pk(adr->pk)
+	CSPKeyList map[string]kyber.Point
+	// we only add new clients at the beginning of each round
+	// store the new clients's one-time pseudonym
+	NewUEsBuffer []kyber.Point
+
+	BlockChain      *blockchain.BlockChain //local blockchain
+	winner_block    *blockchain.Block      //store winner_block
+	Listm           []util.Pair            //store the <nym (point),val(float)>list
+	EnListm         []util.EnPair          //store the <pk (point),val([]byte)>list
+	Npk             int64                  //the number of blocks this OA has created
+	Records         []util.Record          //store the trust value data
+	CandidateBlocks []*blockchain.Block    //stored the lasted blocks from other OAs
+	D               int                    //OA's last obfuscation factor         //信任值混淆时的区间间隔
+	U               map[string]int         //the latest block's serial number which alters UE(i)'s trust value
+
+	// connected flag
+	IsLastOA bool
+	// next hop in topology
+	NextHop net.Addr
+	// previous hop in topology
+	PreviousHop net.Addr
+	// map current public key with previous key(UE)   //将当前公钥映射到以前的密钥（UE）
+	KeyMap map[string]kyber.Point
+
+	// used for modPow encryption   （modPow的意思是模幂运算，在椭圆曲线中就是标量乘法）
+	Roundkey kyber.Scalar       //在initOA函数中随机选取的
+	//the g the Roundkey raised on the forward shuffle, the reverse shuffle proves the removal of the
+	//Roundkey with it. The base point until the first forward shuffle with the Roundkey
+	RoundG kyber.Point
+
+	//consensus round (one data collection each) and sequence number of the last block sent
+	Round int64
+	Seq   int64
+	//newest round and sequence number accepted from the CSP and every OA   //防重放
+	Replay *util.ReplayGuard
+	//peer, nonce and reply of the pending block sync request  //区块同步中等待回复的请求
+	syncFrom  string
+	syncNonce int64
+	syncReply proto.Message
+	//the BFT agreements in progress by height  //进行中的BFT共识
+	bft map[int64]*bftHeight
+	//the archive of the transcripts of this OA's shuffles, nil without a data_dir  //混洗记录
+	transcripts *shuffle.Archive
+	//UE registrations of the previous hop received before its key was known, with the key of the
+	//channel they came on  //上一跳公钥已知前收到的UE注册
+	pendingUEs []pendingUE
+	//sends the events queued under mu after the lock is released  //发送队列，持锁时不阻塞于网络发送
+	out *util.Outbox
+
+	//the factors about trust obfuscation, consensus && list maintence times are in conf.Protocol
+	conf *config.Config
+	//closed by Stop
+	stop chan struct{}
+	//guards the state above between the listener and Run, phase is broadcast after every event
+	//and every change of MineStatus or Status  //监听协程与主循环共享状态的锁，阶段转换用条件变量通知
+	mu    sync.Mutex
+	phase *sync.Cond
+}
+
+//添加
+func (o *OperatorAgent) AddAP(addr net.Addr, key kyber.Point) {
+	o.APList = append(o.APList, addr)
+	o.APKeyList[addr.String()] = key
+}
+func (o *OperatorAgent) AddCSP(addr net.Addr, key kyber.Point) {
+	o.CSPKeyList[addr.String()] = key
+}
+func (o *OperatorAgent) AddOA(addr net.Addr, key kyber.Point) {
+	// delete the OA who has same public key
+	for a, k := range o.OAKeyList {
+		if k == key {
+			delete(o.OAKeyList, a)
+			break
+		}
+	}
+
+	o.OAKeyList[addr.String()] = key
+}
+
+//a UE registration waiting for the key of the previous hop
+type pendingUE struct {
+	msg *proto.UERegister
+	key kyber.Point
+}
+
+//the previous hop can not queue more UE registrations before it registered
+const maxPendingUEs = 4096
+
+//add new NE in buffer
+
+func (o *OperatorAgent) AddUEInBuffer(nym kyber.Point) {
+	o.NewUEsBuffer = append(o.NewUEsBuffer, nym)
+}
+
+func (o *OperatorAgent) AddIntoDecryptedList(nym kyber.Point, val float64) {
+	o.Listm = append(o.Listm, util.Pair{Nym: nym, Val: val})
+}
+
+func (o *OperatorAgent) AddIntoEecryptedList(key kyber.Point, C1, C2 kyber.Point) {
+	o.EnListm = append(o.EnListm, util.EnPair{Nym: key, C1: C1, C2: C2})
+}
+
+//clear the buffer data
+func (operatorAgent *OperatorAgent) clearBuffer() {
+	// clear buffer
+	operatorAgent.NewUEsBuffer = nil
+}
+
+//status setting about blockchain   //声明常量
+const (
+	//status setting about OA's blockchain status
+	FREE    = 0
+	EVALUE  = 1
+	READY   = 2
+	MINE    = 3
+	RECEIVE = 4
+	FINISH  = 5
+	//status setting about normal event
+	DEFAULT             = 5
+	READY_FOR_NEW_ROUND = 6
+	READY_FOR_CONSENSUS = 7
+	CONSENSUS_END       = 8
+)
+
+// Handle processes one event received from addr
+func (operatorAgent *OperatorAgent) Handle(buf []byte, addr net.Addr) {
+	//one event at a time, the phases waiting for it are woken up afterwards
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+	defer operatorAgent.phase.Broadcast()
+
+	// decode the whole message, malformed or incompatible events are dropped instead of stopping the OA
+	event, msg, err := proto.Decode(buf)
+	if err != nil {
+		fmt.Println("[OA] Reject the event from", addr, ":", err)
+		return
+	}
+
+	//protocol events are only accepted from the key the sender registered with
+	if !operatorAgent.authorizedOA(event.EventType, addr) {
+		fmt.Println("[OA] Reject the unauthenticated event", event.EventType, "from", addr)
+		return
+	}
+
+	switch event.EventType {
+	case proto.AP_REGISTER:
+		operatorAgent.handleAPRegister(msg.(*proto.Register), addr)
+		break
+	case proto.OA_REGISTER_REPLY_CSP:
+		operatorAgent.handleOARegisterCSP(msg.(*proto.RegisterReply), addr)
+		break
+	case proto.UE_REGISTER_OASIDE:
+		operatorAgent.handleUERegisterOASide_OA(msg.(*proto.UERegister), addr)
+		break
+	case proto.FORWARD_SHUFFLE:
+		operatorAgent.handleForwardShuffleOA(msg.(*proto.Shuffle))
+		break
+	case proto.SYNC_REPMAP:
+		operatorAgent.handleSyncRepList(msg.(*proto.SyncRepMap))
+		break
+	case proto.DATA_COLLECTION_OA:
+		operatorAgent.handleDataColletionOA(msg.(*proto.DataCollection), addr)
+		break
+	/*
+		case proto.READY_FOR_MINE:
+			operatorAgent.handleSignalSync(event.Params, addr)
+			break
+	*/
+	case proto.RECEIVE_BLOCK:
+		operatorAgent.handleReceiveBlock(msg.(*proto.BlockMessage), addr)
+		break
+	case proto.UNIQUE_LIST_CONFIRMATION:
+		operatorAgent.handleListConfirmation(msg.(*proto.BlockMessage), addr)
+		break
+	case proto.REVERSE_SHUFFLE:
+		operatorAgent.handleReverseShuffleOA(msg.(*proto.Shuffle))
+		break
+	case proto.SYNC_HEADERS_REQUEST:
+		operatorAgent.handleHeadersRequest(msg.(*proto.HeadersRequest), addr)
+		break
+	case proto.SYNC_BODIES_REQUEST:
+		operatorAgent.handleBodiesRequest(msg.(*proto.BodiesRequest), addr)
+		break
+	case proto.SYNC_HEADERS:
+		operatorAgent.handleSyncReply(msg.(*proto.Headers).Nonce, msg, addr)
+		break
+	case proto.SYNC_BODIES:
+		operatorAgent.handleSyncReply(msg.(*proto.Bodies).Nonce, msg, addr)
+		break
+	case proto.BFT_PROPOSAL:
+		operatorAgent.handleProposal(msg.(*proto.Proposal), addr)
+		break
+	case proto.BFT_PREVOTE, proto.BFT_PRECOMMIT:
+		operatorAgent.handleVote(event.EventType, msg.(*proto.Vote), addr)
+		break
+	case proto.BFT_COMMIT:
+		operatorAgent.handleCommit(msg.(*proto.Proposal), addr)
+		break
+	case proto.BFT_CHALLENGE:
+		operatorAgent.handleChallenge(msg.(*proto.Challenge), addr)
+		break
+	case proto.BFT_RESPONSE:
+		operatorAgent.handleResponse(msg.(*proto.Response), addr)
+		break
+	default:
+		fmt.Println("[OA] Unrecognized request")
+		break
+	}
+}
+
+//authorizedOA checks the sender of an event against the key registered for its role
+func (operatorAgent *OperatorAgent) authorizedOA(eventType int, addr net.Addr) bool {
+	switch eventType {
+	case proto.FORWARD_SHUFFLE, proto.REVERSE_SHUFFLE, proto.SYNC_REPMAP, proto.RECEIVE_BLOCK, proto.UNIQUE_LIST_CONFIRMATION,
+		proto.SYNC_HEADERS, proto.SYNC_BODIES, proto.BFT_PROPOSAL, proto.BFT_PREVOTE, proto.BFT_PRECOMMIT, proto.BFT_COMMIT,
+		proto.BFT_CHALLENGE, proto.BFT_RESPONSE:
+		return operatorAgent.Socket.Authenticated(addr, operatorAgent.OAKeyList[addr.String()])
+	case proto.DATA_COLLECTION_OA:
+		return operatorAgent.Socket.Authenticated(addr, operatorAgent.CSPKeyList[addr.String()])
+	case proto.OA_REGISTER_REPLY_CSP:
+		//the CSP's replies hold the keys of the OAs, only the key of the config is trusted with them
+		return addr.String() == operatorAgent.CSPAddress.String() && operatorAgent.Socket.Authenticated(addr, operatorAgent.conf.CSPKey())
+	case proto.UE_REGISTER_OASIDE:
+		//from an AP of this OA or from the previous hop, the handler checks the previous hop's key
+		return operatorAgent.Socket.Authenticated(addr, operatorAgent.APKeyList[addr.String()]) ||
+			(operatorAgent.PreviousHop != nil && addr.String() == operatorAgent.PreviousHop.String())
+	}
+	//registrations carry their key, the handlers compare it with the channel. The chain is public,
+	//sync requests are served to every sender
+	return true
+}
+
+func (operatorAgent *OperatorAgent) handleAPRegister(msg *proto.Register, srcAddr net.Addr) {
+
+	publicKey := operatorAgent.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		fmt.Println("[OA] Reject the registration, invalid public key:", srcAddr)
+		return
+	}
+	//the registered key must be the one that opened the channel
+	if !operatorAgent.Socket.Authenticated(srcAddr, publicKey) {
+		fmt.Println("[OA] Reject the registration, the key is not the channel's key:", srcAddr)
+		return
+	}
+	operatorAgent.AddAP(srcAddr, publicKey)
+	fmt.Println("[OA] Receive the registration info from AccessPoint: ", srcAddr)
+	event := proto.NewEvent(proto.AP_REGISTER_REPLY_OA, &proto.RegisterReply{Reply: true})
+	operatorAgent.out.Send(srcAddr, util.Encode(event))
+
+}
+
+//handleOARegisterCSP confirms the registration with the CSP, once every OA of the topology registered
+//the CSP sends their keys too. The sessions to the OAs are only opened with these keys.
+func (operatorAgent *OperatorAgent) handleOARegisterCSP(msg *proto.RegisterReply, srcAddr net.Addr) {
+	publicKey := operatorAgent.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil || !publicKey.Equal(operatorAgent.conf.CSPKey()) {
+		fmt.Println("[OA] Reject the CSP's reply, the key is not the one of the config:", srcAddr)
+		return
+	}
+	if len(operatorAgent.CSPKeyList) == 0 {
+		operatorAgent.AddCSP(srcAddr, publicKey)
+		fmt.Println("[OA] Register success to CSP:", srcAddr)
+	}
+	if len(msg.OAKeys) == 0 {
+		return
+	}
+	keys, err := util.DecodePointList(msg.OAKeys)
+	if err != nil || len(keys) != len(operatorAgent.OAList) {
+		fmt.Println("[OA] Reject the CSP's reply, it does not hold the keys of the OAs:", srcAddr)
+		return
+	}
+	for i, OAAddr := range operatorAgent.OAList {
+		pinned := operatorAgent.conf.OAKey(OAAddr.String())
+		if OAAddr.String() == operatorAgent.LocalAddress.String() {
+			pinned = operatorAgent.PublicKey
+		}
+		if pinned != nil && !pinned.Equal(keys[i]) {
+			fmt.Println("[OA] Reject the CSP's reply, the key of", OAAddr, "is not the one of the topology")
+			return
+		}
+	}
+	for i, OAAddr := range operatorAgent.OAList {
+		operatorAgent.addOAKey(OAAddr, keys[i])
+	}
+	fmt.Println("[OA] The CSP sent the keys of the OAs.")
+}
+
+//addOAKey trusts key for the OA at addr and registers the UE registrations it passed on before
+func (operatorAgent *OperatorAgent) addOAKey(addr net.Addr, key kyber.Point) {
+	if known := operatorAgent.OAKeyList[addr.String()]; known != nil && known.Equal(key) {
+		return
+	}
+	operatorAgent.Socket.Pin(addr.String(), key)
+	operatorAgent.AddOA(addr, key)
+
+	//the UE registrations the previous hop sent on this key before
+	if operatorAgent.PreviousHop != nil && addr.String() == operatorAgent.PreviousHop.String() {
+		pending := operatorAgent.pendingUEs
+		operatorAgent.pendingUEs = nil
+		for _, p := range pending {
+			if p.key.Equal(key) {
+				operatorAgent.registerUE(p.msg)
+			}
+		}
+	}
+}
+
+func (operatorAgent *OperatorAgent) handleUERegisterOASide_OA(msg *proto.UERegister, srcAddr net.Addr) {
+	//a registration passed on by the previous hop needs its registered key, the ones that arrive
+	//before it are kept until it registered  //来自上一跳的注册需其已注册的公钥
+	if !operatorAgent.Socket.Authenticated(srcAddr, operatorAgent.APKeyList[srcAddr.String()]) {
+		key := operatorAgent.OAKeyList[srcAddr.String()]
+		if key == nil {
+			channelKey, ok := operatorAgent.Socket.PeerKey(srcAddr)
+			if !ok || len(operatorAgent.pendingUEs) >= maxPendingUEs {
+				fmt.Println("[OA] Reject the UE's register info, the previous hop is not registered:", srcAddr)
+				return
+			}
+			operatorAgent.pendingUEs = append(operatorAgent.pendingUEs, pendingUE{msg, channelKey})
+			return
+		}
+		if !operatorAgent.Socket.Authenticated(srcAddr, key) {
+			fmt.Println("[OA] Reject the UE's register info, the key is not the previous hop's key:", srcAddr)
+			return
+		}
+	}
+	operatorAgent.registerUE(msg)
+}
+
+//registerUE replaces the UE's key with its pseudonym of this hop and passes it on
+func (operatorAgent *OperatorAgent) registerUE(msg *proto.UERegister) {
+
+	publicKey := operatorAgent.Suite.Point()
+	if err := publicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		fmt.Println("[OA] Reject the UE's register info, invalid public key:", msg.UEAddr)
+		return
+	}
+	tepUE := msg.UEAddr
+	tepAP := msg.UpperAP
+	newKey := operatorAgent.Suite.Point().Mul(operatorAgent.Roundkey, publicKey)
+	//表示用 operatorAgent.Roundkey 这个标量乘以 publicKey 这个点，并返回结果点（newKey）。
+	byteNewKey, _ := newKey.MarshalBinary()
+	UEAddr, err := operatorAgent.Socket.ResolveAddr(tepUE)
+	//将一个表示 IP 地址和端口的字符串解析为当前传输层的 net.Addr 地址对象。
+	if err != nil {
+		fmt.Println("[OA] Reject the UE's register info, invalid address:", tepUE)
+		return
+	}
+	fmt.Println("[OA] Receive  register request from UserEquipment: ", UEAddr)
+	operatorAgent.KeyMap[newKey.String()] = publicKey
+	//表示将一个 publicKey 存储在 operatorAgent.KeyMap 中，键为 newKey 转换为字符串的结果。
+
+	event := proto.NewEvent(proto.UE_REGISTER_OASIDE, &proto.UERegister{
+		PublicKey: byteNewKey,
+		UEAddr:    tepUE,
+		UpperAP:   tepAP,
+	})
+	if operatorAgent.NextHop != nil {
+		operatorAgent.out.Send(operatorAgent.NextHop, util.Encode(event))
+	} else {
+		/* instead of sending new client to server,
+		we will send it when finishing this round. Currently we just add it into buffer*/
+		APAddr, err := operatorAgent.Socket.ResolveAddr(tepAP)
+		if err != nil {
+			fmt.Println("[OA] Reject the UE's register info, invalid AP address:", tepAP)
+			return
+		}
+		operatorAgent.AddUEInBuffer(newKey)
+		//send the UE'S upper AP the register info
+		fmt.Println("[OA] Send UE's register info to AccessPoint: ", APAddr)
+		operatorAgent.out.Send(APAddr, util.Encode(event))
+	}
+
+}
+
+// the part of shuffle
+//verifyNeffShuffle verifies the proofs of the previous hop and returns the ciphertexts of the
+//values it passed on, C1[i], C2[i] is the value of key i
+func (operatorAgent *OperatorAgent) verifyNeffShuffle(msg *proto.Shuffle, forward bool) (C1, C2 []kyber.Point, err error) {
+
+	//every hop shuffles, also a list of 0 or 1 keys  //每一跳都必须附带混洗证明
+	if !msg.Shuffled {
+		return nil, nil, errors.New("the previous hop did not shuffle the list")
+	}
+
+	// get all the necessary parameters
+	//将字节数组解码为点列表
+	var lists [8][]kyber.Point
+	for i, b := range [][]byte{msg.Xbar, msg.Ybar, msg.PrevKeys, msg.PrevVals, msg.C1, msg.C2, msg.PrevC1, msg.PrevC2} {
+		list, err := util.DecodePointList(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		lists[i] = list
+	}
+	xbarList, ybarList, prevKeyList, prevValList := lists[0], lists[1], lists[2], lists[3]
+	C1, C2, prevC1, prevC2 := lists[4], lists[5], lists[6], lists[7]
+	for _, list := range lists {
+		if len(list) != len(xbarList) {
+			return nil, nil, errors.New("shuffle lists of different length")
+		}
+	}
+	prePublicKey := operatorAgent.Suite.Point()
+	if err := prePublicKey.UnmarshalBinary(msg.PublicKey); err != nil {
+		return nil, nil, err
+	}
+
+	//the previous hop is the OA before this one in the direction of the shuffle, its key removes
+	//its share of the key of the values  //上一跳必须是拓扑中相邻的OA
+	keys := operatorAgent.oaKeys()
+	if keys == nil {
+		return nil, nil, errors.New("the keys of the OAs are unknown")
+	}
+	pos := int(operatorAgent.signer(operatorAgent.LocalAddress))
+	prev, valueKey := pos+1, operatorAgent.valueKey(keys, 0)
+	if forward {
+		prev, valueKey = pos-1, operatorAgent.valueKey(keys, pos)
+	}
+	if prev < 0 || prev >= len(keys) || !prePublicKey.Equal(keys[prev]) {
+		return nil, nil, errors.New("the list was shuffled by another OA than the previous hop")
+	}
+
+	workers := operatorAgent.conf.Protocol.ShuffleWorkers
+	if forward {
+		//the previous hop removed its share of the key from the C2 it got
+		recvC2, err := util.DecodePointList(msg.RecvC2)
+		if err != nil {
+			return nil, nil, err
+		}
+		verifier := shuffle.DecryptVerifier(operatorAgent.Suite, prePublicKey, prevC1, recvC2, prevC2, workers)
+		if err := proof.HashVerify(operatorAgent.Suite, "Decrypt", verifier, msg.DecProof); err != nil {
+			return nil, nil, errors.New("Decryption verify failed: " + err.Error())
+		}
+	}
+
+	// verify the shuffle, the values are shuffled with the keys
+	verifier := shuffle.ColumnsVerifier(operatorAgent.Suite, nil, prePublicKey, prevKeyList, prevValList, xbarList, ybarList,
+		[]kyber.Point{operatorAgent.Suite.Point().Base(), valueKey}, [][]kyber.Point{prevC1, prevC2}, [][]kyber.Point{C1, C2},
+		workers, operatorAgent.conf.Protocol.ShuffleBatch)
+
+	err = proof.HashVerify(operatorAgent.Suite, "PairShuffle", verifier, msg.Proof)
+	if err != nil {
+		return nil, nil, errors.New("Shuffle verify failed: " + err.Error())
+	}
+	return C1, C2, nil
+}
+
+//valueKey is the key the values are encrypted under once the OAs before position pos removed
+//their shares: the sum of the keys of the OAs from pos on, the identity after the last OA
+//值密文当前的联合公钥
+func (operatorAgent *OperatorAgent) valueKey(keys []kyber.Point, pos int) kyber.Point {
+	key := operatorAgent.Suite.Point().Null()
+	for _, k := range keys[pos:] {
+		key.Add(key, k)
+	}
+	return key
+}
+
+//encodeValue embeds a value in a point, the plaintext of its ElGamal ciphertext
+func (operatorAgent *OperatorAgent) encodeValue(val float64) kyber.Point {
+	return operatorAgent.Suite.Point().Embed(util.Float64ToByte(val), random.New())
+}
+
+//decodeValue is the value embedded in M
+func decodeValue(M kyber.Point) (float64, error) {
+	data, err := M.Data()
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%d bytes embedded instead of a value", len(data))
+	}
+	return util.ByteToFloat64(data), nil
+}
+
+//decodeValues decodes the ciphertexts of the values of a message, one per key
+func decodeValues(msg *proto.Shuffle, size int) (C1, C2 []kyber.Point, err error) {
+	if C1, err = util.DecodePointList(msg.C1); err != nil {
+		return nil, nil, err
+	}
+	if C2, err = util.DecodePointList(msg.C2); err != nil {
+		return nil, nil, err
+	}
+	if len(C1) != size || len(C2) != size {
+		return nil, nil, errors.New("keys and values do not match")
+	}
+	return C1, C2, nil
+}
+
+//recordShuffle archives the transcript of this OA's hop, the message carries all of it but the
+//key the values were shuffled under and the keys the hop got. The proof that the keys it got were
+//raised to the Roundkey, or that it was removed from them, is only archived  //记录混洗及轮密钥证明
+func (operatorAgent *OperatorAgent) recordShuffle(forward bool, pm *proto.Shuffle, valueKey kyber.Point, recvKeys []kyber.Point) {
+	if operatorAgent.transcripts == nil {
+		return
+	}
+	keys, err := util.DecodePointList(pm.PrevVals)
+	util.CheckErr(err)
+	X, Xbar := recvKeys, keys
+	if !forward {
+		X, Xbar = keys, recvKeys
+	}
+	g := operatorAgent.Suite.Point().Mul(operatorAgent.Roundkey, operatorAgent.RoundG)
+	prover := shuffle.ExponentProver(operatorAgent.Suite, operatorAgent.Roundkey, operatorAgent.RoundG, X, Xbar,
+		operatorAgent.conf.Protocol.ShuffleWorkers)
+	expPrf, err := proof.HashProve(operatorAgent.Suite, "Exponent", prover)
+	util.CheckErr(err)
+	byteRecvG, err := operatorAgent.RoundG.MarshalBinary()
+	util.CheckErr(err)
+	byteG, err := g.MarshalBinary()
+	util.CheckErr(err)
+
+	var K0 int64 = -1
+	if tip := operatorAgent.BlockChain.PreviousBlock(); tip != nil {
+		K0 = tip.K0
+	}
+	byteValueKey, err := valueKey.MarshalBinary()
+	util.CheckErr(err)
+	transcript := &shuffle.Transcript{K0: K0, Forward: forward, Hop: int(operatorAgent.signer(operatorAgent.LocalAddress)),
+		PublicKey: pm.PublicKey, RecvKeys: util.ProtobufEncodePointList(recvKeys), X: pm.PrevKeys, Y: pm.PrevVals, Xbar: pm.Xbar, Ybar: pm.Ybar,
+		Keys: pm.Keys, RecvG: byteRecvG, G: byteG, ExpProof: expPrf, Proof: pm.Proof,
+		ValueKey: byteValueKey, C1: pm.PrevC1, C2: pm.PrevC2, C1bar: pm.C1, C2bar: pm.C2, RecvC2: pm.RecvC2, DecProof: pm.DecProof}
+	if err := operatorAgent.transcripts.Append(transcript); err != nil {
+		fmt.Println("[OA] The transcript of the shuffle can not be stored:", err)
+	}
+}
+
+//该函数将 YbarEn 中的每个点减去 Ytmp 中的对应点，并将结果存储在 Ytmp 中。
+func convertToOrigin(YbarEn, Ytmp []kyber.Point) []kyber.Point {
+	size := len(YbarEn)
+	yyy := make([]kyber.Point, size)
+
+	for i := 0; i < size; i++ {
+		yyy[i] = YbarEn[i]
+		Ytmp[i].Sub(yyy[i], Ytmp[i])
+	}
+	return Ytmp
+}
+
+// Y is the keys want to shuffle, the ciphertexts C1, C2 of their values are shuffled with them
+// and re-encrypted under valueKey with factors independent of the keys'  //引入外部包函数为内部函数
+func (operatorAgent *OperatorAgent) neffShuffle(X []kyber.Point, Y []kyber.Point, C1, C2 []kyber.Point, valueKey kyber.Point,
+	rand cipher.Stream) (Xbar, Ybar, Ytmp, C1bar, C2bar []kyber.Point, prover proof.Prover) {
+
+	var Cbar [][]kyber.Point
+	Xbar, Ybar, Ytmp, Cbar, prover = shuffle.ShuffleColumns(operatorAgent.Suite, nil, operatorAgent.PublicKey, X, Y,
+		[]kyber.Point{operatorAgent.Suite.Point().Base(), valueKey}, [][]kyber.Point{C1, C2}, rand, operatorAgent.conf.Protocol.ShuffleWorkers)
+
+	return Xbar, Ybar, Ytmp, Cbar[0], Cbar[1], prover //函数直接返回 Shuffle 函数的结果
+}
+
+//处理后向混洗
+func (operatorAgent *OperatorAgent) handleReverseShuffleOA(msg *proto.Shuffle) {
+	//	msg := &proto.Shuffle{
+	//	Keys:      byteKeys,
+	//	PlainVals: vals,
+	//	IsStart:   true,
+	//}
+
+	//解码
+	keyList, err := util.DecodePointList(msg.Keys)
+	if err != nil {
+		fmt.Println("[OA] Reject the reverse shuffle, invalid keys:", err)
+		return
+	}
+	size := len(keyList)
+	keys := operatorAgent.oaKeys()
+	if keys == nil {
+		fmt.Println("[OA] Reject the reverse shuffle, the keys of the OAs are unknown.")
+		return
+	}
+	//the values are encrypted under the keys of all OAs while they are shuffled back  //值在后向混洗中以所有OA的联合公钥加密
+	valueKey := operatorAgent.valueKey(keys, 0)
+	var C1, C2 []kyber.Point
+	//if reverse_shffle just start(last OA),no need to verify previous shuffle  //如果reverse_shffle刚刚开始(最后一次OA)，则不需要验证之前的洗牌
+	//检查消息的 IsStart 标志
+	//如果为 true，则执行后续代码 （对值的序列化） // 数据验证与反序列化
+	if msg.IsStart {  //起始节点：直接处理原始浮点数值
+		//取出明文的 float64 声誉值
+		intValList := msg.PlainVals
+		if len(intValList) != size {
+			fmt.Println("[OA] Reject the reverse shuffle, keys and values do not match.")
+			return
+		}
+		//the values of the list are public, they start as ciphertexts with randomness 0 and the
+		//shuffle re-randomizes them  //初始密文的随机数为0，由混洗重随机化
+		C1 = make([]kyber.Point, size)
+		C2 = make([]kyber.Point, size)
+		for i := 0; i < len(intValList); i++ {
+			C1[i] = operatorAgent.Suite.Point().Null()
+			C2[i] = operatorAgent.encodeValue(intValList[i])
+		}
+	} else {  //中间节点：先验证前驱节点的Neff混洗证明，再反序列化数据
+		// verify neff shuffle if needed
+		C1, C2, err = operatorAgent.verifyNeffShuffle(msg, false)
+		if err != nil {
+			fmt.Println("[OA] Reject the reverse shuffle:", err)
+			return
+		}
+		if len(C1) != size {
+			fmt.Println("[OA] Reject the reverse shuffle, keys and values do not match.")
+			return
+		}
+	}
+
+	// 密钥重加密
+	newKeys := make([]kyber.Point, size)
+	for i := 0; i < size; i++ {
+		// decrypt the public key    //    // 解密公钥
+		newKeys[i] = operatorAgent.KeyMap[keyList[i].String()]
+		if newKeys[i] == nil {
+			fmt.Println("[OA] Reject the reverse shuffle, unknown key:", keyList[i])
+			return
+		}
+	}
+	//密钥重加密：使用本地密钥映射解密接收到的公钥；值的密文留在其键的位置，与键一起混洗
+
+	//序列化
+	byteNewKeys := util.ProtobufEncodePointList(newKeys)
+
+	Xori := make([]kyber.Point, size) //store the ori publickey of sever  //// 存“原始（等价）公钥”的镜像
+	for i := 0; i < size; i++ {
+		Xori[i] = operatorAgent.Suite.Point().Mul(operatorAgent.PrivateKey, nil) //same as publickey    (OA的公钥：这里的每一个元素都等于当前 OA 节点自己的公钥)
+	}
+
+	byteOri := util.ProtobufEncodePointList(Xori)
+
+	rand := random.New()
+
+	// *** perform neff shuffle here ***   正式洗牌
+
+	//prover：零知识证明
+	Xbar, Ybar, Ytmp, C1bar, C2bar, prover := operatorAgent.neffShuffle(Xori, newKeys, C1, C2, valueKey, rand)
+
+	//生成可验证的哈希式 ZK 证明，证明“我对键和值的密文做了同一置换 + 正确的重加密”，但不泄露置换本身。
+	prf, err := proof.HashProve(operatorAgent.Suite, "PairShuffle", prover)
+	util.CheckErr(err)
+
+	// this is the shuffled key
+	finalKeys := convertToOrigin(Ybar, Ytmp)
+
+	//打包要回传给上一跳的数据
+	// send data to the next server
+	byteXbar := util.ProtobufEncodePointList(Xbar)
+	byteYbar := util.ProtobufEncodePointList(Ybar)
+	byteFinalKeys := util.ProtobufEncodePointList(finalKeys)
+	bytePublicKey, _ := operatorAgent.PublicKey.MarshalBinary()
+	
+	// prev keys means the key before shuffle
+	pm := &proto.Shuffle{     //pm是一个临时变量
+		Xbar:      byteXbar,
+		Ybar:      byteYbar,
+		Keys:      byteFinalKeys,
+		C1:        util.ProtobufEncodePointList(C1bar),
+		C2:        util.ProtobufEncodePointList(C2bar),
+		Proof:     prf,
+		PrevKeys:  byteOri,              //（洗牌前的键）
+		PrevVals:  byteNewKeys,
+		PrevC1:    util.ProtobufEncodePointList(C1),
+		PrevC2:    util.ProtobufEncodePointList(C2),
+		Shuffled:  true,
+		PublicKey: bytePublicKey,
+	}
+	event := proto.NewEvent(proto.REVERSE_SHUFFLE, pm)
+	operatorAgent.recordShuffle(false, pm, valueKey, keyList)
+	
+	// reset RoundKey and key map  重置状态并回传；
+	operatorAgent.Roundkey = operatorAgent.Suite.Scalar().Pick(random.New())
+	operatorAgent.RoundG = operatorAgent.Suite.Point().Base()
+	operatorAgent.KeyMap = make(map[string]kyber.Point)
+
+	//继续进行后向洗牌
+	if operatorAgent.PreviousHop != nil {
+		fmt.Println("[OA] The shuffle of reverse direction is going on.Pass the list to the previous OperatorAgent.")
+		operatorAgent.out.Send(operatorAgent.PreviousHop, util.Encode(event))
+		//Handle_OA(event, operatorAgent.PreviousHop)
+
+	} else {     //后向洗牌完成，
+		fmt.Println("[OA] The shuffle of reverse direction is done.")
+		//when finishing reverse shuffle,the first OA should store the encrypted listm.
+		operatorAgent.EnListm = nil     //加密列表
+		for i := 0; i < len(finalKeys); i++ {
+
+			operatorAgent.AddIntoEecryptedList(finalKeys[i], C1bar[i], C2bar[i])  //当完成反向洗牌时，第一个OA应该存储加密的列表。
+		}
+
+		operatorAgent.forwardShuffle()        // 开始进行前向洗牌
+		return
+	}
+
+}
+
+//进行前向洗牌
+func (operatorAgent *OperatorAgent) handleForwardShuffleOA(msg *proto.Shuffle) {
+
+	g := operatorAgent.Suite.Point()
+	keyList, err := util.DecodePointList(msg.Keys)
+	if err != nil {
+		fmt.Println("[OA] Reject the forward shuffle, invalid keys:", err)
+		return
+	}
+	size := len(keyList)
+	keys := operatorAgent.oaKeys()
+	if keys == nil {
+		fmt.Println("[OA] Reject the forward shuffle, the keys of the OAs are unknown.")
+		return
+	}
+	var C1, C2 []kyber.Point
+
+	//如果消息中包含 g，则解码 g，并对其进行一些处理。如果没有包含 g，则创建一个新的点 g。
+	if len(msg.G) != 0 {
+		// contains g
+		g = operatorAgent.Suite.Point()
+		if err := g.UnmarshalBinary(msg.G); err != nil {
+			fmt.Println("[OA] Reject the forward shuffle, invalid g:", err)
+			return
+		}
+		// verify the previous shuffle
+		C1, C2, err = operatorAgent.verifyNeffShuffle(msg, true)
+		if err != nil {
+			fmt.Println("[OA] Reject the forward shuffle:", err)
+			return
+		}
+		operatorAgent.RoundG = g
+		g = operatorAgent.Suite.Point().Mul(operatorAgent.Roundkey, g)
+	} else {
+		//the first hop shuffles its own list
+		C1, C2, err = decodeValues(msg, size)
+		if err != nil {
+			fmt.Println("[OA] Reject the forward shuffle:", err)
+			return
+		}
+		//gm
+		operatorAgent.RoundG = operatorAgent.Suite.Point().Base()
+		g = operatorAgent.Suite.Point().Mul(operatorAgent.Roundkey, nil)
+	}
+	if len(C1) != size {
+		fmt.Println("[OA] Reject the forward shuffle, keys and values do not match.")
+		return
+	}
+
+	//store the en/decrypt key&&val
+	newKeys := make([]kyber.Point, size)
+
+	for i := 0; i < len(keyList); i++ {
+		// encrypt the public key using modPow
+		newKeys[i] = operatorAgent.Suite.Point().Mul(operatorAgent.Roundkey, keyList[i])
+		// update key map (nym->publickey)   //假名和公钥的链接
+		operatorAgent.KeyMap[newKeys[i].String()] = keyList[i]       //维护一个映射表，把新生成的化名公钥（newKeys[i]）和原来的化名公钥（keyList[i]）对应起来
+	}
+	// remove the share of this OA from the key of the values, with a proof  //部分解密声誉值并证明
+	workers := operatorAgent.conf.Protocol.ShuffleWorkers
+	decC2, decProver := shuffle.Decrypt(operatorAgent.Suite, operatorAgent.PrivateKey, C1, C2, workers)
+	decPrf, err := proof.HashProve(operatorAgent.Suite, "Decrypt", decProver)
+	util.CheckErr(err)
+	valueKey := operatorAgent.valueKey(keys, int(operatorAgent.signer(operatorAgent.LocalAddress))+1)
+
+	//turn the nym//val and gm to byte    //将数据编码为字节数组:
+	byteNewKeys := util.ProtobufEncodePointList(newKeys)
+	byteG, err := g.MarshalBinary()
+	util.CheckErr(err)
+
+	//store the OA's privateKey (copy it to the num of vals)
+	Xori := make([]kyber.Point, size)
+	for i := 0; i < size; i++ {
+		Xori[i] = operatorAgent.Suite.Point().Mul(operatorAgent.PrivateKey, nil)
+		//Mul(scalar, point) 是 椭圆曲线上的标量乘法；如果 point == nil，kyber 的约定是使用群的生成元 G（也就是基点）。
+		//这行等价于私钥*基点=公钥。
+	}
+	
+	//turn it to byte
+	byteOri := util.ProtobufEncodePointList(Xori)
+	//has problem,just use the randomstream
+	rand := random.New()
+	//rand := operatorAgent.Suite.Cipher(abstract.RandomKey)
+	
+	// *** perform neff shuffle here ***
+	Xbar, Ybar, Ytmp, C1bar, C2bar, prover := operatorAgent.neffShuffle(Xori, newKeys, C1, decC2, valueKey, rand)
+	prf, err := proof.HashProve(operatorAgent.Suite, "PairShuffle", prover)
+	util.CheckErr(err)
+
+	// this is the shuffled key list, the values follow it
+	finalKeys := convertToOrigin(Ybar, Ytmp)
+
+	// send data to the next server
+	byteXbar := util.ProtobufEncodePointList(Xbar)
+	byteYbar := util.ProtobufEncodePointList(Ybar)
+	byteFinalKeys := util.ProtobufEncodePointList(finalKeys)
+	bytePublicKey, _ := operatorAgent.PublicKey.MarshalBinary()
+	
+	// prev keys means the key before shuffle
+	pm := &proto.Shuffle{     //pm是一个临时变量
+		Xbar:      byteXbar,
+		Ybar:      byteYbar,
+		Keys:      byteFinalKeys,
+		C1:        util.ProtobufEncodePointList(C1bar),
+		C2:        util.ProtobufEncodePointList(C2bar),
+		Proof:     prf,
+		PrevKeys:  byteOri,
+		PrevVals:  byteNewKeys,
+		PrevC1:    util.ProtobufEncodePointList(C1),
+		PrevC2:    util.ProtobufEncodePointList(decC2),
+		RecvC2:    util.ProtobufEncodePointList(C2),
+		DecProof:  decPrf,
+		Shuffled:  true,
+		PublicKey: bytePublicKey,
+		G:         byteG,
+	}
+	event := proto.NewEvent(proto.FORWARD_SHUFFLE, pm)
+	operatorAgent.recordShuffle(true, pm, valueKey, keyList)
+
+	if operatorAgent.NextHop != nil {
+		fmt.Println("[OA] The shuffle of forward direction is going on.Pass the list to the next OperatorAgent.")
+		operatorAgent.out.Send(operatorAgent.NextHop, util.Encode(event))
+		//Handle_OA(event, operatorAgent.NextHop)
+	} else {
+		fmt.Println("[OA] The shuffle of forward direction is done.")
+		//after the last share C2 is the value  //最后一跳后C2即为明文
+		vals := make([]float64, size)
+		for i := range vals {
+			if vals[i], err = decodeValue(C2bar[i]); err != nil {
+				fmt.Println("[OA] Reject the forward shuffle, a value can not be decoded:", err)
+				return
+			}
+		}
+		//when finishing forward shuffle,the first OA should store the new listm.
+		operatorAgent.Listm = nil
+		operatorAgent.U = make(map[string]int)   //更改UE(i)信任值的最新块序列号
+		for i := 0; i < len(finalKeys); i++ {
+			operatorAgent.AddIntoDecryptedList(finalKeys[i], vals[i])
+			operatorAgent.U[finalKeys[i].String()] = 0
+		}
+
+		operatorAgent.G = g
+		operatorAgent.syncListm(byteG)      //   ？
+		return
+	}
+
+}
+
+//Sync Rep List to APs   //实现了同步新的声誉列表到访问点 (Access Points) 和处理区块链的逻辑。
+func (operatorAgent *OperatorAgent) handleSyncRepList(msg *proto.SyncRepMap) {
+
+	lenth := len(operatorAgent.OAList)
+
+	//every OA appends the block of the last OA, so all chains are the same  //所有OA追加最后一个OA创建的同一个区块
+	block, err := blockchain.DecodeBlock(msg.Block)
+	if err == nil {
+		err = operatorAgent.checkListBlock(&block, msg)
+	}
+	if err != nil {
+		fmt.Println("[OA] Reject the reputation list, invalid block:", err)
+		return
+	}
+
+	//如果当前节点不是最后一个 OA 节点，则将新列表存储在 operatorAgent 中，并初始化 U 映射。
+	if operatorAgent.LocalAddress != operatorAgent.OAList[lenth-1] {
+		//except last oa,other oas should stored the new list first    //除最后一个oa外，其他oa应首先存储新列表
+		nymList, err := util.DecodePointList(msg.Nyms)
+		valList := msg.Vals
+		if err != nil || len(nymList) != len(valList) {
+			fmt.Println("[OA] Reject the reputation list, nyms and values do not match.")
+			return
+		}
+		operatorAgent.Listm = nil
+		operatorAgent.U = make(map[string]int)
+		for i := 0; i < len(nymList); i++ {
+			operatorAgent.Listm = append(operatorAgent.Listm, util.Pair{Nym: nymList[i], Val: valList[i]})
+			operatorAgent.U[nymList[i].String()] = 0
+
+		}
+
+		fmt.Println("[OA] Stored the new reputation list success!", operatorAgent.PublicKey)
+		operatorAgent.sleep(2.0 * time.Second)
+	}
+
+	if len(operatorAgent.BlockChain.Blocks) == 0 {
+		//if it's the first round ,the block is the genesis block
+		fmt.Println("[OA] Initial the blockchain.", operatorAgent.PublicKey)
+	} else {
+		//else add the new block to block chain  //否则将新区块添加到区块链中
+		fmt.Println("[OA] Insert the attached new list to block and add this block to blockchain.")
+	}
+	//the block holding the new list is the latest one that altered the trust values, addBlock sets U  //新列表所在区块的序号
+	if err := operatorAgent.addBlock(&block); err != nil {
+		fmt.Println("[OA] Reject the reputation list, the block can not be added:", err)
+		return
+	}
+
+	//send the new list to aps that deployed by it      //将新列表发送给它部署的ap
+	//the header links the list to the chain, the APs check it with their header chain  //区块头将列表与区块链关联
+	event := proto.NewEvent(proto.SYNC_REPMAP, &proto.SyncRepMap{G: msg.G, Nyms: msg.Nyms, Vals: msg.Vals,
+		Header: blockchain.ToByteHeader(block.Header())})
+	for _, APAddr := range operatorAgent.APList {
+		fmt.Println("[OA] Send the new reputation list to AccessPoint:", APAddr)
+		operatorAgent.out.Send(APAddr, util.Encode(event))
+	}
+	//Wait for the APs, from the delivery of the list on
+	operatorAgent.flush()
+	operatorAgent.sleep(3 * time.Second)
+	operatorAgent.Status = READY_FOR_NEW_ROUND
+}
+
+/*
+//handle the signal sync event    //处理同步事件
+func (operatorAgent *OperatorAgent) handleSignalSync(params map[string]interface{}, addr net.Addr) {
+	if ok, _ := params["READY"].(bool); ok == true {
+		fmt.Println("[OA] Recieve the sync signal from:", addr)
+		operatorAgent.ReadyOANum++
+	} else {
+		fmt.Println("[OA] Signal verify failed:", addr)
+	}
+
+}
+*/
+
+///////////////////////////////////////////////////////////////////////////////////////
+//function of blockchain
+///////////////////////////////////
+
+/////////GenesisBlock and BlockChain
+
+//checkListBlock checks the block of a new list: created by the last OA on top of the local chain
+//and holding the list and g of the message  //检查新列表的区块
+func (operatorAgent *OperatorAgent) checkListBlock(block *blockchain.Block, msg *proto.SyncRepMap) error {
+	if block.Mined() {
+		return errors.New("a mined block can not hold a new list")
+	}
+	header := block.Header()
+	if err := operatorAgent.checkCreator(&header); err != nil {
+		return err
+	}
+	if err := block.Check(); err != nil {
+		return err
+	}
+	if previous := operatorAgent.BlockChain.PreviousBlock(); previous == nil {
+		if len(block.PreHash) != 0 || block.K0 != 0 || block.Nb != 0 {
+			return errors.New("the first block is not a genesis block")
+		}
+	} else if !bytes.Equal(block.PreHash, previous.BlockHash()) || block.K0 != previous.K0+1 || block.Nb != int64(len(operatorAgent.BlockChain.Blocks)) {
+		return errors.New("the block does not follow the local chain")
+	}
+	if !bytes.Equal(block.Nyms, msg.Nyms) || !reflect.DeepEqual(block.Vals, msg.Vals) || !bytes.Equal(block.G, msg.G) {
+		return errors.New("the block does not hold the list of the message")
+	}
+	return nil
+}
+
+//loadBlockChain opens the block store of this OA and restores U and Npk of an earlier run
+//重启时从磁盘加载区块链并恢复 U 和 Npk
+func (operatorAgent *OperatorAgent) loadBlockChain() error {
+	path := operatorAgent.conf.ChainPath(operatorAgent.LocalAddress.String())
+	if path == "" {
+		operatorAgent.BlockChain = &blockchain.BlockChain{}
+		return nil
+	}
+	bc, err := blockchain.Open(path)
+	if err != nil {
+		return err
+	}
+	operatorAgent.BlockChain = bc
+	if len(bc.Blocks) == 0 {
+		return nil
+	}
+
+	//a chain that was changed on disk is not used  //磁盘上被篡改的区块链不会被使用
+	if err := bc.Validate(operatorAgent.conf.Difficulty()); err != nil {
+		bc.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	//a block from the future is only possible with a wrong clock
+	if err := bc.CheckTimes(time.Now().UnixMilli(), int64(operatorAgent.conf.Protocol.ClockSkew)); err != nil {
+		bc.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	//the commits of BFT blocks are checked with the keys the topology pins, without them a stored
+	//BFT block is rejected  //未固定公钥时无法验证BFT区块的集体签名
+	if err := bc.CheckCommits(operatorAgent.conf.OAKeys()); err != nil {
+		bc.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	//The live list is not restored, its nyms can only be shuffled back with the KeyMaps of this run,
+	//the UEs register again and start a new list
+	if err := operatorAgent.replayChain(); err != nil {
+		bc.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Println("[OA] Reload", len(bc.Blocks), "blocks from", path, ", this OA created", operatorAgent.Npk, "of them.")
+	return nil
+}
+
+//addBlock adds a block to the tree of the chain. When the canonical chain changes, U, Npk and a live
+//Listm follow its new tip  //区块加入区块树，主链切换时 Listm、U 和 Npk 随新的链尾更新
+func (operatorAgent *OperatorAgent) addBlock(block *blockchain.Block) error {
+	if err := operatorAgent.checkTime(block); err != nil {
+		return err
+	}
+	//the list may become the live one, a block with a malformed list is rejected
+	if _, err := blockList(block); err != nil {
+		return fmt.Errorf("list: %v", err)
+	}
+	if block.Mode == blockchain.BFT {
+		if err := operatorAgent.checkCommit(block); err != nil {
+			return fmt.Errorf("commit: %v", err)
+		}
+	}
+	if err := operatorAgent.BlockChain.CheckTarget(block, operatorAgent.conf.Difficulty()); err != nil && err != blockchain.ErrUnknownParent {
+		return err
+	}
+	reorg, err := operatorAgent.BlockChain.AddBlock(block)
+	if err != nil {
+		return err
+	}
+	if reorg == nil {
+		fmt.Println("[OA] Keep block", block.K0, "on a side branch.")
+		return nil
+	}
+	if len(reorg.Removed) == 0 {
+		return operatorAgent.applyBlock(block)
+	}
+
+	tip := operatorAgent.BlockChain.PreviousBlock()
+	fmt.Println("[OA] Reorganise the chain: leave", len(reorg.Removed), "blocks, take", len(reorg.Added), "blocks of the branch up to block", tip.K0)
+	if len(operatorAgent.Listm) != 0 {
+		if err := operatorAgent.setListm(tip); err != nil {
+			return err
+		}
+	}
+	return operatorAgent.replayChain()
+}
+
+//checkTime checks the timestamp of a block against its parent in the tree and the local clock
+func (operatorAgent *OperatorAgent) checkTime(block *blockchain.Block) error {
+	var prev *blockchain.Header
+	if parent := operatorAgent.BlockChain.Lookup(block.PreHash); parent != nil {
+		parentHeader := parent.Header()
+		prev = &parentHeader
+	}
+	header := block.Header()
+	return header.CheckTime(prev, time.Now().UnixMilli(), int64(operatorAgent.conf.Protocol.ClockSkew))
+}
+
+//replayChain computes U and Npk from the canonical chain
+func (operatorAgent *OperatorAgent) replayChain() error {
+	operatorAgent.U = make(map[string]int)
+	operatorAgent.Npk = 0
+	for _, block := range operatorAgent.BlockChain.Blocks {
+		if err := operatorAgent.applyBlock(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//applyBlock updates U and Npk for a block joining the canonical chain: U is the latest block that
+//carried a nym, Npk counts the blocks this OA mined
+func (operatorAgent *OperatorAgent) applyBlock(block *blockchain.Block) error {
+	nymList, err := util.DecodePointList(block.Nyms)
+	if err != nil || len(nymList) != len(block.Vals) {
+		return fmt.Errorf("block %d: nyms and values do not match", block.K0)
+	}
+	for _, nym := range nymList {
+		operatorAgent.U[nym.String()] = int(block.K0)
+	}
+	bytePK, _ := operatorAgent.PublicKey.MarshalBinary()
+	if block.Mined() && bytes.Equal(block.PublicKey, bytePK) {
+		operatorAgent.Npk++
+	}
+	return nil
+}
+
+//setListm accepts the list <nym,val> of a block
+func (operatorAgent *OperatorAgent) setListm(block *blockchain.Block) error {
+	list, err := blockList(block)
+	if err != nil {
+		return err
+	}
+	operatorAgent.Listm = list
+	return nil
+}
+
+//blockList decodes the list of a block, the blocks come from the peers  //假名列表需要反序列后再存储
+func blockList(block *blockchain.Block) ([]util.Pair, error) {
+	nymList, err := util.DecodePointList(block.Nyms)
+	if err != nil {
+		return nil, err
+	}
+	if len(nymList) != len(block.Vals) {
+		return nil, fmt.Errorf("%d nyms but %d values", len(nymList), len(block.Vals))
+	}
+	list := make([]util.Pair, len(nymList))
+	for i := range list {
+		list[i].Nym = nymList[i]
+		list[i].Val = block.Vals[i]
+	}
+	return list, nil
+}
+
+//该函数用于将操作代理的 Listm 转换为编码后的假名列表、信任值列表和 g 为 gm 时的 Merkle 根1
+func (operatorAgent *OperatorAgent) listConversion(gm []byte) ([]byte, []float64, []byte) {
+	//初始化
+	nymList := []kyber.Point{}    // kyber.Point 类型的空切片
+	valList := []float64{}
+
+	//遍历 Listm 并填充列表
+	for _, v := range operatorAgent.Listm {
+		nymList = append(nymList, v.Nym)
+		valList = append(valList, v.Val)
+	}
+	byteNym := util.ProtobufEncodePointList(nymList)
+
+	//one leaf per <nym,val>, g is the last leaf of a list block  //每个 <nym,val> 一个叶子
+	mr1, err := blockchain.ListRoot(byteNym, valList, gm)
+	util.CheckErr(err)
+	return byteNym, valList, mr1
+}
+
+//创建区块链的创世区块，初始化了区块链的一些基础数据
+func (operatorAgent *OperatorAgent) genesisBlock(gm []byte) *blockchain.Block {
+	var K0 int64 = 0 //set the GenesisBlock 's serial number is 1  //序列号设置
+	var timestamp int64 = time.Now().UnixMilli()   // 创建时间
+	var prehash []byte = []byte{}    // 前一个区块的哈希值为空，因为这是第一个区块
+	var mr0 []byte = []byte{}    // Merkle根0初始化为空
+
+	//mr1 is the merkle root constructed with Lm and gm    // mr1 是用 Lm 和 gm 构造的 Merkle 根
+	var D int64 = 0
+	nyms, vals, mr1 := operatorAgent.listConversion(gm)    // 获取当前假名和信任值的转换后的字节数组并计算 Merkle 根1
+
+	pk, _ := operatorAgent.PublicKey.MarshalBinary()
+	var npk int64 = 0
+	var nb int64 = 0
+	var nd int64 = 0
+
+	Gblock := blockchain.Block{K0: K0, Timestamp: timestamp, PreHash: prehash, D: D, Nb: nb, Npk: npk, Nd: nd,
+		MerkelRoot0: mr0, MerkelRoot1: mr1, PublicKey: pk, Nyms: nyms, Vals: vals, G: gm}
+	operatorAgent.signBlock(&Gblock)
+	return &Gblock
+}
+
+func (operatorAgent *OperatorAgent) createNewBlock(gm []byte) *blockchain.Block {
+	//the serial numbers go on over the list blocks, so K0 indexes the chain
+	var K0 int64 = operatorAgent.BlockChain.PreviousBlock().K0 + 1
+	var timestamp int64 = time.Now().UnixMilli()
+	var prehash []byte = operatorAgent.BlockChain.PreviousBlock().BlockHash()
+	var mr0 []byte = []byte{}
+
+	//mr1 is the merkle root constructed with Lm and gm
+
+	nyms, vals, mr1 := operatorAgent.listConversion(gm)
+	var D int64 = int64(operatorAgent.D)
+	pk, _ := operatorAgent.PublicKey.MarshalBinary()
+	var npk int64 = operatorAgent.Npk
+	var nb int64 = int64(len(operatorAgent.BlockChain.Blocks))
+	var nd int64 = 0
+
+	block := blockchain.Block{K0: K0, Timestamp: timestamp, PreHash: prehash, D: D, Nb: nb, Npk: npk, Nd: nd,
+		MerkelRoot0: mr0, MerkelRoot1: mr1, PublicKey: pk, Nyms: nyms, Vals: vals, G: gm}
+	operatorAgent.signBlock(&block)
+	return &block
+}
+
+//signBlock signs the block as its creator, the signature stays in the chain  //创建者对区块哈希签名
+func (operatorAgent *OperatorAgent) signBlock(block *blockchain.Block) {
+	block.SignBK = util.SchnorrSign(operatorAgent.Suite, random.New(), block.BlockHash(), operatorAgent.PrivateKey)
+}
+
+//consensus part
+//verify block
+func (operatorAgent *OperatorAgent) VerifyBlock(signBK []byte, block *blockchain.Block, publicKey kyber.Point, round, seq int64) bool {
+	//the verify part of sign, the signature also covers the round and the sender's sequence number
+	err := util.SchnorrVerify(operatorAgent.Suite, util.Fresh(block.BlockHash(), round, seq), publicKey, signBK)
+	if err != nil {
+		//fmt.Println("[OA] Signature verification failed!")
+		return false
+	}
+	//the block itself: the creator's signature, the work proof and the root of its own list  //区块自身：创建者签名、工作量证明和列表的 Merkle 根
+	if err := block.Check(); err != nil {
+		fmt.Println("[OA] Block verify failed:", err)
+		return false
+	}
+	//the target of the work follows from the blocks before it, a block after an unknown one is not used  //难度目标由之前的区块决定
+	if err := operatorAgent.BlockChain.CheckTarget(block, operatorAgent.conf.Difficulty()); err != nil && err != blockchain.ErrUnknownParent {
+		fmt.Println("[OA] Block verify failed:", err)
+		return false
+	}
+	return true
+}
+
+//roundBlock checks that a mined block holds the records and the updated list of this round,
+//every OA computed them from the same data  //检查区块的记录和列表与本地计算的一致
+func (operatorAgent *OperatorAgent) roundBlock(block *blockchain.Block) bool {
+	//records's and listm's merkle root ,verify the correction of block's update  //记录和计算哈希值
+	items0 := [][]byte{(util.ToByteRecords(operatorAgent.Records))}   //信任值数据转化为字节
+	_, _, MerkelRoot1 := operatorAgent.listConversion(nil)
+	MerkelRoot0 := blockchain.GetMerkleRoot(items0)
+	return bytes.Equal(MerkelRoot0, block.MerkelRoot0) && bytes.Equal(MerkelRoot1, block.MerkelRoot1)
+}
+
+//listening to OA's status,stop receive blocks after theta seconds  //监听OA的状态，在theta秒后停止接收阻塞；实现了一个倒计时监听器，用于在特定时间后停止接收区块
+func (operatorAgent *OperatorAgent) CountDownListening() {
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+
+	//当操作代理的状态是 EVALUE、READY 或 MINE 时，等待状态变化
+	for operatorAgent.MineStatus == EVALUE || operatorAgent.MineStatus == READY || operatorAgent.MineStatus == MINE {
+		//wait util status change to receive
+		operatorAgent.phase.Wait()
+	}
+	//状态变为 RECEIVE 时启动倒计时
+	if operatorAgent.MineStatus == RECEIVE {
+		for i := operatorAgent.conf.Protocol.Theta; i >= operatorAgent.conf.Protocol.Interval; i = i - operatorAgent.conf.Protocol.Interval {
+			fmt.Printf("[OA] Stop receive blocks after %d milliseconds...\n", i)
+			operatorAgent.sleep(time.Duration(operatorAgent.conf.Protocol.Interval) * time.Millisecond)
+			//进入倒计时，间隔时间 Interval 毫秒，每次减少这个间隔，直到总时间 Theta 用完。
+		}
+		operatorAgent.MineStatus = FINISH
+		operatorAgent.phase.Broadcast()
+		fmt.Println("[OA] Stop receive blocks now!")
+
+	} else {
+		fmt.Println("[OA] CountDownListening failed!")
+		//wg1.Done()
+		return
+	}
+
+	//after receive time,do not recieve blocks
+
+	//wg1.Done()
+	return
+}
+
+//实现了共识过程结束后的操作，具体包括将赢家区块添加到区块链、接受赢家区块的列表、重置状态和存储等步骤
+func (operatorAgent *OperatorAgent) consensusEnd() {
+
+	//添加胜出的区块，addBlock 统计 Npk
+	if err := operatorAgent.addBlock(operatorAgent.winner_block); err != nil {
+		fmt.Println("[OA] The winner block can not be added:", err)
+	}
+
+	//accept the listm of the tip, the winner block unless the fork choice prefers another branch
+	if err := operatorAgent.setListm(operatorAgent.BlockChain.PreviousBlock()); err != nil {
+		fmt.Println("[OA] Keep the list, the list of the tip can not be decoded:", err)
+	}
+
+	//when finish a consensus round, OA should reset status and storage     //重置状态和存储 //这里检查当前操作代理的公钥是否与前一个区块的公钥相同
+	bytePK, _ := operatorAgent.PublicKey.MarshalBinary()
+	if reflect.DeepEqual(operatorAgent.BlockChain.PreviousBlock().PublicKey, bytePK) {       //reflect.DeepEqual 深度比较两个值是否相等的函数
+		fmt.Println("[OA] The block you created is chosen as the winner block of this round.")
+	}
+
+	//reset
+	operatorAgent.winner_block = nil
+	operatorAgent.MineStatus = FREE
+	operatorAgent.Records = nil
+	operatorAgent.Status = CONSENSUS_END
+
+
+}
+
+//mine
+
+func (operatorAgent *OperatorAgent) startMine() {
+
+	//检查挖矿状态
+	if operatorAgent.MineStatus == READY {
+		fmt.Println("[OA] Start mining...")
+		operatorAgent.MineStatus = MINE
+
+		//获取最新区块信息
+		previousBlock := operatorAgent.BlockChain.PreviousBlock() //get the latest block
+		//设置新块的各种属性
+		K0 := previousBlock.K0 + 1
+		var D int64 = 0
+		var Nb int64 = int64(len(operatorAgent.BlockChain.Blocks))
+		var Npk int64 = operatorAgent.Npk
+		var Nd int64 = int64(len(operatorAgent.Records))
+		//计算HASH
+		var hash []byte
+		PreHash := previousBlock.BlockHash() //set the prehash
+		//the target follows the intervals of the last mined blocks, Npk and Nb make it fair  //根据出块间隔调整的难度目标
+		Target := operatorAgent.BlockChain.Target(PreHash, operatorAgent.conf.Difficulty())
+		var intHash big.Int
+		var intDiff big.Int
+		var intTarget big.Int
+		intTarget.SetBytes(Target)
+		intDiff.SetBytes(blockchain.ComputeDiff(intTarget, Npk, Nb))
+
+		MerkelRoot0 := []byte{}
+		//mr1 is root of updated listm
+		Nyms, Vals, MerkelRoot1 := operatorAgent.listConversion(nil)
+		items0 := [][]byte{(util.ToByteRecords(operatorAgent.Records))}
+		//mr0 is root of records
+		MerkelRoot0 = blockchain.GetMerkleRoot(items0)
+		Pk, _ := operatorAgent.PublicKey.MarshalBinary()
+		var t int64 = 0     //timestamp
+		var nonce int64 = 0
+
+		//find the nonce
+		//计算目标哈希值 (intDiff)，并在一个循环中逐步增加随机数 nonce 进行哈希计算，直到找到满足条件的哈希值。如果在这个过程中操作代理的状态变为 RECEIVE，则停止挖矿。
+		//the timestamp follows the clock while searching, so it is the time the block was found  //时间戳随时钟更新，即找到区块的时间
+		//the lock is released while searching, the listener turns the status to RECEIVE  //挖矿期间释放锁，监听协程才能接收其他区块
+		found := false
+		operatorAgent.mu.Unlock()
+		for nonce < math.MaxInt64 && !operatorAgent.received() {
+			if nonce%1024 == 0 {
+				t = time.Now().UnixMilli()
+			}
+			hash = blockchain.SetHash(K0, PreHash, MerkelRoot0, MerkelRoot1, Pk, t, nonce)
+			intHash.SetBytes(hash[:])
+			if intHash.Cmp(&intDiff) == -1 {
+				found = true
+				break
+			}
+			nonce++
+		}
+		operatorAgent.mu.Lock()
+
+		//当找到符合难度要求的哈希值时，构建一个新的区块，将其设置为赢家区块 (winner_block)，并发布该区块。
+		if found {
+			operatorAgent.MineStatus = RECEIVE
+			operatorAgent.phase.Broadcast()
+			//insert data to the new block
+			new_block := &blockchain.Block{K0: K0, Timestamp: t, Nonce: nonce, Target: Target, PreHash: PreHash, D: D, Nb: Nb, Npk: Npk, Nd: Nd,
+				MerkelRoot0: MerkelRoot0, MerkelRoot1: MerkelRoot1, PublicKey: Pk, Nyms: Nyms, Vals: Vals}
+			operatorAgent.signBlock(new_block)
+
+			fmt.Println("[OA] Mining success !")
+			if operatorAgent.winner_block != nil {
+				operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, new_block) //选择赢的区块
+			} else {
+				operatorAgent.winner_block = new_block
+			}
+			fmt.Println("[OA] Publish the block !")
+			operatorAgent.PublishBlock(new_block, proto.RECEIVE_BLOCK)
+		} else {
+			fmt.Println("[OA] Recieve the other block and stop mining!")
+		}
+	} else {
+		fmt.Println("[OA] The initial state of mining is abnormal...")
+	}
+
+}
+
+//received reports whether the listener accepted another block while mining, the caller does not hold the lock
+func (operatorAgent *OperatorAgent) received() bool {
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+	return operatorAgent.MineStatus == RECEIVE
+}
+
+//receive block and verify , then select winner block
+//收到新块时处理相应逻辑。该函数根据当前的挖矿状态 (MineStatus)，决定是否接受新的区块并进行验证和处理。
+func (operatorAgent *OperatorAgent) handleReceiveBlock(msg *proto.BlockMessage, addr net.Addr) {
+	if operatorAgent.conf.Protocol.Consensus == "bft" {
+		fmt.Println("[OA] Reject the mined block from", addr, ", the blocks are agreed on by BFT.")
+		return
+	}
+
+	if operatorAgent.MineStatus == FREE {   //在 FREE 状态下，操作代理不处于区块共识阶段，但如果接收到块，将调用 ReceiveBlock 处理，并通过 BlockWinnnerSelection 方法选择获胜块。
+		fmt.Println("[OA] This is not the block consensus stage but recieve a block.")
+		ok, block := operatorAgent.ReceiveBlock(msg, addr)
+		ok = ok && operatorAgent.extendsTip(block, addr)
+		if ok && !operatorAgent.roundBlock(block) {
+			fmt.Println("[OA] The block from", addr, "does not hold the records and the list of this round.")
+			ok = false
+		}
+		ok = ok && operatorAgent.freshBlock(block, addr)
+		if ok {
+			if operatorAgent.winner_block != nil {
+				operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, block)
+			} else {
+				operatorAgent.winner_block = block
+			}
+		}
+
+	} else {     //根据不同的挖矿状态 (EVALUE、MINE、READY、RECEIVE、FINISH) 执行相应的逻辑。
+		fmt.Println("[OA] Recieve new block from :", addr, "start to verify block and check accept window.")
+		ok, block := operatorAgent.ReceiveBlock(msg, addr)
+		ok = ok && operatorAgent.extendsTip(block, addr)
+		if ok && !operatorAgent.roundBlock(block) {
+			fmt.Println("[OA] The block from", addr, "does not hold the records and the list of this round.")
+			ok = false
+		}
+		ok = ok && operatorAgent.freshBlock(block, addr)
+		if ok {
+			if operatorAgent.MineStatus == EVALUE {
+				fmt.Println("[OA] Receiving other blocks while trust evaluation...")
+				operatorAgent.MineStatus = RECEIVE
+				if operatorAgent.winner_block != nil {
+					operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, block)
+				} else {
+					operatorAgent.winner_block = block
+				}
+				fmt.Println("[OA] Receive block success,turning OA's status to RECEIVE...")
+			} else if operatorAgent.MineStatus == MINE {
+				fmt.Println("[OA] Receiving other blocks while mining...")
+				//if ok,set the status to RECEIVE
+				operatorAgent.MineStatus = RECEIVE
+				if operatorAgent.winner_block != nil {
+					operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, block)
+
+				} else {
+					operatorAgent.winner_block = block
+				}
+				fmt.Println("[OA] Receive block success,turning OA's status to RECEIVE...")
+			} else if operatorAgent.MineStatus == READY {
+				fmt.Println("[OA] A block was received during ready time...")
+				operatorAgent.MineStatus = RECEIVE
+				if operatorAgent.winner_block != nil {
+					operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, block)
+				} else {
+					operatorAgent.winner_block = block
+				}
+				fmt.Println("[OA] Receive block success,turning OA's status to RECEIVE...", operatorAgent.PublicKey)
+
+			} else if operatorAgent.MineStatus == RECEIVE {
+				fmt.Println("[OA] A block was received during the accept window...", operatorAgent.PublicKey)
+
+				if operatorAgent.winner_block != nil {
+					operatorAgent.winner_block = blockchain.BlockWinnnerSelection(operatorAgent.winner_block, block)
+				} else {
+					operatorAgent.winner_block = block
+				}
+				fmt.Println("[OA] Receive block success...")
+
+			} else if operatorAgent.MineStatus == FINISH {
+
+				fmt.Println("[OA] The receive window is closed! Blocks are not received at this time!")
+			}
+		}
+	}
+}
+
+//freshBlock reports whether a block received in the round was just found: its timestamp follows the
+//parent and is the local time, both up to the clock skew  //本轮收到的区块时间戳须接近本地时间
+func (operatorAgent *OperatorAgent) freshBlock(block *blockchain.Block, addr net.Addr) bool {
+	err := operatorAgent.checkTime(block)
+	if skew := int64(operatorAgent.conf.Protocol.ClockSkew); err == nil && block.Timestamp < time.Now().UnixMilli()-skew {
+		err = fmt.Errorf("the timestamp %d is more than %d ms old", block.Timestamp, skew)
+	}
+	if err != nil {
+		fmt.Println("[OA] Reject the block from", addr, ":", err)
+		return false
+	}
+	return true
+}
+
+//extendsTip reports whether a received block extends the local tip. Only those compete in this round,
+//a block on another branch goes into the tree if its parent is known  //只有接在本地链尾之后的区块参与本轮竞争
+func (operatorAgent *OperatorAgent) extendsTip(block *blockchain.Block, addr net.Addr) bool {
+	if bytes.Equal(block.PreHash, operatorAgent.BlockChain.PreviousBlock().BlockHash()) {
+		return true
+	}
+	fmt.Println("[OA] The block from", addr, "does not extend the local tip.")
+	if operatorAgent.BlockChain.Known(block.PreHash) {
+		if err := operatorAgent.addBlock(block); err != nil {
+			fmt.Println("[OA] The block from", addr, "can not be added:", err)
+		}
+	}
+	return false
+}
+
+//用于接收、解析和验证传入的区块，并返回验证结果和区块本身。该函数根据提供的参数对区块进行解码，并验证其有效性。
+func (operatorAgent *OperatorAgent) ReceiveBlock(msg *proto.BlockMessage, addr net.Addr) (bool, *blockchain.Block) {
+
+	block, err := blockchain.DecodeBlock(msg.Block)
+	if err != nil {
+		fmt.Println("[OA] Reject the block, it can not be decoded:", err)
+		return false, nil
+	}
+
+	var ok bool = operatorAgent.VerifyBlock(msg.SignBK, &block, operatorAgent.OAKeyList[addr.String()], msg.Round, msg.Seq)
+	if ok {
+		//a block of another round or one that was already received is rejected  //拒绝旧轮次和重放的区块
+		if msg.Round != operatorAgent.Round {
+			fmt.Println("[OA] Reject the block of round", msg.Round, "from", addr, "in round", operatorAgent.Round)
+			return false, &block
+		}
+		if err := operatorAgent.Replay.Accept(addr.String(), msg.Round, msg.Seq); err != nil {
+			fmt.Println("[OA] Reject the block from", addr, ":", err)
+			return false, &block
+		}
+	}
+	return ok, &block
+}
+
+//publish block to OAs   //用于在区块生成后，将区块广播给网络中的其他操作代理
+func (operatorAgent *OperatorAgent) PublishBlock(block *blockchain.Block, eventType int) {
+	
+	operatorAgent.Seq++
+	signBK := util.SchnorrSign(operatorAgent.Suite, random.New(), util.Fresh(block.BlockHash(), operatorAgent.Round, operatorAgent.Seq), operatorAgent.PrivateKey)  //使用 Schnorr 签名算法对区块的哈希值进行签名
+	byteBlock := blockchain.ToByteBlock(*block)    //将区块转换为字节数组
+	//bytePublickey, _ := operatorAgent.PublicKey.MarshalBinary()
+	event := proto.NewEvent(eventType, &proto.BlockMessage{Block: byteBlock, Round: operatorAgent.Round, Seq: operatorAgent.Seq, SignBK: signBK})   //创建一个包含区块和签名的事件 event，并指定事件类型 eventType
+
+	//send to OA except itself    //遍历所有的 OA 地址（除了自身），将事件编码并通过网络发送给这些 OA。
+	for _, Addr := range operatorAgent.OAList {
+		if Addr.String() != operatorAgent.LocalAddress.String() {
+
+			fmt.Println("[OA] Send the block to OA:", Addr)
+			operatorAgent.out.Send(Addr, util.Encode(event))
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////
+/////////function of trust value update
+
+//用于向云服务提供商（CSP，Cloud Service Provider）发送数据收集请求。这个函数将请求封装为一个事件，并通过网络发送给 CSP。
+func (operatorAgent *OperatorAgent) dataCollectionOA() {
+
+	//a new consensus round starts with the data collection
+	operatorAgent.Round++
+	event := proto.NewEvent(proto.DATA_COLLECTION_OA, &proto.DataCollection{Require: true, Round: operatorAgent.Round})
+	fmt.Println("[OA] Send the data collection require to Cloud Service Provider.")
+	operatorAgent.out.Send(operatorAgent.CSPAddress, util.Encode(event))
+
+}
+
+//用于处理从云服务提供商（CSP）接收的数据收集请求，并验证接收的数据的签名是否有效。如果数据有效，则将其存储到本地记录中。
+func (operatorAgent *OperatorAgent) handleDataColletionOA(msg *proto.DataCollection, srcAddr net.Addr) {
+	//检查是否接收到 "Start" 标志
+	Nym := operatorAgent.Suite.Point()
+	if msg.Start {
+		fmt.Println("[OA] Recieve the trust value related data from Cloud Service Provider...", srcAddr)
+
+	}
+	//verify the signature and store the record to local records
+	// 签名验证成功，将记录存储到本地
+	if err := Nym.UnmarshalBinary(msg.Nym); err != nil {
+		fmt.Println("[OA] Reject the record, invalid nym:", srcAddr)
+		return
+	}
+	record := util.Record{Nym: Nym, Data: msg.Data}
+	err := util.SchnorrVerify(operatorAgent.Suite, util.ToSignedRecord(record, msg.Round, msg.Seq),
+		operatorAgent.CSPKeyList[srcAddr.String()], msg.SignRe)
+	if err == nil {
+		//records of another round or replayed ones must not change the trust values
+		if msg.Round != operatorAgent.Round {
+			fmt.Println("[OA] Reject the record of round", msg.Round, "in round", operatorAgent.Round)
+			return
+		}
+		if err := operatorAgent.Replay.Accept(srcAddr.String(), msg.Round, msg.Seq); err != nil {
+			fmt.Println("[OA] Reject the record:", err)
+			return
+		}
+	}
+	//==========================================================test===============================
+	//fmt.Println(operatorAgent.CSPKeyList[srcAddr.String()])
+	if err == nil {
+		// 签名验证成功，将记录存储到本地
+		//fmt.Println("[OA] The sign of Cloud Service Provider verify success!")
+		//store record to local
+		//=================================================test================================
+		fmt.Println("=================record output test point==================")
+		fmt.Println(record)
+		operatorAgent.Records = append(operatorAgent.Records, record)
+		fmt.Println(operatorAgent.Records)
+	} else {
+		//fmt.Println("[OA] The sign of Cloud Service Provider verify failed!")
+	}
+
+	//  检查是否接收到 "Done" 标志
+	if msg.Done {
+		//print data time
+		fmt.Println(record)
+		fmt.Println("[OA] Data collection done!")
+		operatorAgent.MineStatus = EVALUE
+		operatorAgent.trustValueUpdate()
+
+	}
+
+}
+
+func (operatorAgent *OperatorAgent) trustValueUpdate() {
+
+	fmt.Println("[OA] Start trust value update...")
+	//do the trust value update
+
+	//read normal model  //读取正常模型
+	var normal_model []float64 = nil
+	opencast1, err1 := os.Open(operatorAgent.conf.Protocol.NormalModel)
+	if err1 != nil {
+		fmt.Println("[OA] Normal model open failed!")
+	}
+
+	ReadCsv1 := csv.NewReader(opencast1)
+	read1, err1 := ReadCsv1.Read()
+	util.CheckErr(err1)
+
+	//turn the string to float64
+	for j := 0; j < len(read1)-1; j++ {
+		tempdata, _ := strconv.ParseFloat(read1[j], 64)
+		normal_model = append(normal_model, tempdata)
+	}
+	opencast1.Close()
+
+	//read abnormal model
+	var abnormal_model []float64 = nil
+	opencast2, err2 := os.Open(operatorAgent.conf.Protocol.AbnormalModel)
+	if err2 != nil {
+		fmt.Println("[OA] Abnormal model open failed!")
+	}
+
+	ReadCsv2 := csv.NewReader(opencast2)
+	read2, err2 := ReadCsv2.Read()
+	util.CheckErr(err2)
+
+	//turn the string to float64
+	for j := 0; j < len(read2)-1; j++ {
+		tempdata, _ := strconv.ParseFloat(read2[j], 64)
+		abnormal_model = append(abnormal_model, tempdata)
+	}
+	opencast2.Close()
+	
+	
+	//=============================================================================================testtestetstetstestse
+	
+	fmt.Println(normal_model, abnormal_model)
+
+	////初始化正常/异常行为的计数器
+	//the number of normal/abnormal behavious
+	var IN map[string]int = make(map[string]int)
+	var IA map[string]int = make(map[string]int)
+	for index := 0; index < len(operatorAgent.Listm); index++ {
+		IN[operatorAgent.Listm[index].Nym.String()] = 0
+		IA[operatorAgent.Listm[index].Nym.String()] = 0
+	}
+
+	//the number to adjust the influence of abnormal behavious
+	//var k float64 = 0.17
+	//related number
+	var K int = int(operatorAgent.BlockChain.PreviousBlock().K0)
+
+	//time delay
+	//var t float64 = 0.5
+	
+	//================================================================test=================================================
+	fmt.Println(len(operatorAgent.Records))
+	fmt.Println(operatorAgent.Listm)
+	//stastic the number of 2 type behavious
+	////统计正常和异常行为的数量
+	for i := 0; i < len(operatorAgent.Records); i++ {
+
+		//计算异常模型和正常模型与记录数据之间的距离，然后根据距离判断该记录是异常行为还是正常行为。
+		if computeDistance(abnormal_model, operatorAgent.Records[i].Data) <= computeDistance(normal_model, operatorAgent.Records[i].Data) {
+			IA[operatorAgent.Records[i].Nym.String()]++
+		} else {
+			IN[operatorAgent.Records[i].Nym.String()]++
+		}
+		//=================================================================testestest=============================================================================
+		fmt.Println(operatorAgent.Records[i].Nym.String(),IA[operatorAgent.Records[i].Nym.String()])
+		fmt.Println(operatorAgent.Records[i].Nym.String(),IN[operatorAgent.Records[i].Nym.String()])
+	}
+
+	//更新每个组的信任值
+	for index, group := range operatorAgent.Listm {
+
+		var newTrustValue float64 = 0.0
+		//time_factor 计算时间因子，它基于当前轮次 K 和存储在 operatorAgent.U 中的时间差值。时间因子用于调整信任值，基于行为的时间变化进行指数衰减。
+		time_factor := math.Exp(-1.0 * math.Abs(float64(K-operatorAgent.U[group.Nym.String()])) / operatorAgent.conf.Protocol.TimeDelay)
+		//避免除以零的情况：
+		if float64(IN[group.Nym.String()]) == 0.0 {
+			IN[group.Nym.String()] = 1
+		}
+		//计算异常行为因子：
+		abnormal_factor := operatorAgent.conf.Protocol.AbnormalFactor * float64(IA[group.Nym.String()])
+
+		newTrustValue = (1.0/(time_factor+1.0))*
+			(float64(IN[group.Nym.String()])-abnormal_factor)/(float64(IN[group.Nym.String()])+abnormal_factor) +
+			(time_factor/(time_factor+1.0))*group.Val
+		/*第一部分 ((1.0/(time_factor+1.0)) * (float64(IN[group.Nym.String()])-abnormal_factor) / (float64(IN[group.Nym.String()])+abnormal_factor)) 计算行为对信任值的影响，考虑了正常和异常行为的数量。
+		第二部分 (time_factor/(time_factor+1.0))*group.Val 计算时间对信任值的影响，平滑地将旧信任值 group.Val 与新计算的部分结合起来。
+		*/
+
+		//更新信任值和时间：
+		operatorAgent.Listm[index].Val = util.FloatRound(newTrustValue)
+		operatorAgent.U[group.Nym.String()] = int(operatorAgent.BlockChain.PreviousBlock().K0 + 1)
+	}
+
+	fmt.Println("[OA] Trust value update success!")
+	fmt.Println("[OA] Change the status to READY_FOR_CONSENSUS!")
+	operatorAgent.Status = READY_FOR_CONSENSUS
+	//operatorAgent.sendSinalToOAs()
+
+}
+
+/*
+//向其他操作代理（OA）发送准备挖矿的信号
+func (operatorAgent *OperatorAgent) sendSinalToOAs() {
+	// set the parameters to register
+	params := map[string]interface{}{
+		"READY": true,
+	}
+	event := &proto.Event{proto.READY_FOR_MINE, params}
+	//send the ready siganl to other OA
+	for _, OAAddr := range operatorAgent.OAList {
+
+		operatorAgent.out.Send(OAAddr, util.Encode(event))
+		fmt.Println("[OA]Send the signal to OA:", OAAddr)
+
+	}
+
+}
+*/
+//计算两个浮点数数组之间的欧几里得距离
+func computeDistance(a, b []float64) float64 {
+
+	//Distance
+
+	var d float64 = 0.0
+
+	for i := 0; i < len(a); i++ {
+		d = d + math.Abs(a[i]-b[i])*math.Abs(a[i]-b[i])
+		//对于每个元素，计算 a[i] 和 b[i] 之间的差值的绝对值，并将其平方，然后加到距离变量 d 中。
+	}
+
+	d = math.Sqrt(d)
+
+	return d
+}
+
+///////////////////////////////////////////////////////////////
+/////////list maintenance
+
+//unique list confirmation
+//将最新的区块发布给其他OA
+func (operatorAgent *OperatorAgent) listPublish() {
+	//construct candidate blocks    //将最新区块添加到候选区块列表中
+	operatorAgent.CandidateBlocks = append(operatorAgent.CandidateBlocks, operatorAgent.BlockChain.PreviousBlock())
+	fmt.Println("[OA] Publish the latest block to other OperatorAgents!")
+	//将最新区块发布给其他操作代理
+	operatorAgent.PublishBlock(operatorAgent.BlockChain.PreviousBlock(), proto.UNIQUE_LIST_CONFIRMATION)
+}
+
+//接收所有操作代理（Operator Agent, OA）的最新区块（所投票认同的区块），并选择出一个最终的区块作为共识结果
+func (operatorAgent *OperatorAgent) listConfirmation() {
+	size := len(operatorAgent.OAList)
+	//wait for recieve all OA's latest block   //获取 OA 列表的长度，并等待 operatorAgent.CandidateBlocks 列表的长度等于 OA 列表的长度。
+	//an OA that stopped or fell behind does not block the others, they vote with the blocks received in time
+	timeout := time.Duration(operatorAgent.conf.Protocol.SyncTimeout) * time.Millisecond
+	complete := operatorAgent.waitTimeout(timeout, func() bool { return len(operatorAgent.CandidateBlocks) >= size })
+	//the tips of the other OAs go into the tree, the fork choice gives every OA the same tip  //其他OA的链尾加入区块树
+	behind := false
+	for _, block := range operatorAgent.CandidateBlocks {
+		if operatorAgent.BlockChain.Known(block.BlockHash()) {
+			continue
+		}
+		if !operatorAgent.BlockChain.Known(block.PreHash) {
+			behind = true
+		} else if err := operatorAgent.addBlock(block); err != nil {
+			fmt.Println("[OA] The published block", block.K0, "can not be added:", err)
+		}
+	}
+	if complete {
+		fmt.Println("[OA] Recieve all OA's new block!")
+	} else {
+		fmt.Println("[OA] Recieve", len(operatorAgent.CandidateBlocks), "of", size, "OA's new block in time.")
+	}
+	if !complete || behind {
+		//catch up before the next round  //下一轮之前先同步区块链
+		operatorAgent.syncChain()
+	}
+	size = len(operatorAgent.CandidateBlocks)
+
+	//初始化计数器：初始化一个 rank 字典来记录每个区块（按哈希）出现的次数。
+	var rank map[string]int = make(map[string]int, size)
+	for _, block := range operatorAgent.CandidateBlocks {
+		rank[string(block.BlockHash())] = 0
+	}
+
+	//统计每个区块出现的次数：
+	for i := 0; i < size; i++ {
+		rank[string(operatorAgent.CandidateBlocks[i].BlockHash())]++
+	}
+
+	//find the highest val   //找到最高投票数
+	var highest_val int = 0
+	for _, v := range rank {
+
+		if v > highest_val {
+			highest_val = v
+		}
+	}
+
+	//选择具有最高投票数的区块：
+	var Choosen_Block *blockchain.Block = nil
+	var Candidate_Blocks []*blockchain.Block = nil   //一个区块指针的切片，用于存储所有投票数最高的候选区块
+
+	for k, v := range rank {
+		if v == highest_val {     //找到最高的v对应的k
+			for _, val := range operatorAgent.CandidateBlocks {
+				if k == string(val.BlockHash()) {      //找到k对应的区块，val是此时遍历的区块
+					Candidate_Blocks = append(Candidate_Blocks, val)
+					break
+				}
+			}
+		}
+	}
+
+	//在多个候选区块中选择最终区块：
+	size2 := len(Candidate_Blocks)
+	Choosen_Block = Candidate_Blocks[0]   //将 Candidate_Blocks 列表中的第一个区块赋值给 Choosen_Block
+	if size2 > 1 {
+		for index := 1; index < size2; index++ {
+			Choosen_Block = blockchain.BlockWinnnerSelection(Choosen_Block, Candidate_Blocks[index])
+		}
+	}
+
+	//the list follows the canonical chain  //列表以主链为准
+	if tip := operatorAgent.BlockChain.PreviousBlock(); !bytes.Equal(Choosen_Block.BlockHash(), tip.BlockHash()) {
+		fmt.Println("[OA] The confirmed block", Choosen_Block.K0, "is not the tip of the chain, keep the list of block", tip.K0)
+		Choosen_Block = tip
+	}
+
+	//accept the winner block's listm   //接收赢家区块的 listm
+	if err := operatorAgent.setListm(Choosen_Block); err != nil {
+		fmt.Println("[OA] Keep the list, the list of the confirmed block can not be decoded:", err)
+	}
+
+	//重置候选区块列表
+	operatorAgent.CandidateBlocks = nil
+
+}
+
+//处理接收到的区块发布确认消息
+func (operatorAgent *OperatorAgent) handleListConfirmation(msg *proto.BlockMessage, addr net.Addr) {
+
+	ok, block := operatorAgent.ReceiveBlock(msg, addr)    //验证和解析接收到的区块
+	if ok {
+		fmt.Println("[OA] Recieve the pulished block from:", addr)
+		operatorAgent.CandidateBlocks = append(operatorAgent.CandidateBlocks, block)
+	} else {
+		fmt.Println("[OA] The pulished block verify failed!", addr)
+	}
+}
+
+//trust obfuscation
+//在给定数据集上找到合适的 d 值，以便对信任值进行混淆，使其达到特定的匿名性水平
+func (operatorAgent *OperatorAgent) find_d(d int, DataSet []float64) int {
+	//pth := 0.5
+	TrustValueSet := make([]float64, len(DataSet))
+	copy(TrustValueSet, DataSet)     //将 DataSet 复制到 TrustValueSet 中。
+	fmt.Println("**************************************************")
+	//fmt.Println("d of this round is:", d)
+	var Ntv float64 = 1.0 / float64(d)      //计算每个区间的大小。
+	//fmt.Println("Ntv of this round is:", Ntv)
+
+	RN := 1.0 - float64(d)*Ntv     //计算剩余概率
+	//record the num and p
+	NUM := make([]int, d)
+	P := make([]float64, d)
+
+	//do obfuscation  // 对数据集进行混淆  //根据 d 将每个值混淆到相应的区间。
+	for index, ele := range TrustValueSet {
+		for c := 1; c <= d; c++ {
+			if ele > float64(c-1)*Ntv && ele <= float64(c)*Ntv {
+				TrustValueSet[index] = float64(c-1) * Ntv
+
+			} else if ele > 1.0-RN && ele <= 1.0 {
+				TrustValueSet[index] = 1.0 - RN - Ntv
+			}
+		}
+	}
+	//count the number of NUM[i]     // 统计每个区间的数量
+	for _, ele := range TrustValueSet {
+		for i := 0; i < d; i++ {
+			if ele >= float64(i)*Ntv && ele < float64(i+1)*Ntv {
+				NUM[i]++
+			}
+		}
+	}
+
+	//calcuelate p[i]     计算每个区间的概率
+	for i := 0; i < len(NUM); i++ {
+		if NUM[i] != 0 {
+			P[i] = 1.0 / float64(NUM[i])
+		} else {
+			P[i] = 0.0
+		}
+	}
+
+	//找到最大的概率值
+	max := P[0]
+	for i := 0; i < len(P); i++ {
+		if P[i] > max {
+			max = P[i]
+		}
+	}
+
+	//fmt.Printf("After obfuscation of Ntv [%.6f],the worst anonymous probability is: %.6f\n", Ntv, max)
+
+	if max <= operatorAgent.conf.Protocol.Pth {
+		//如果最大的概率值小于等于pth，返回当前的d值
+		//fmt.Println("Choose the d:", d, "to do obfuscation.")
+		return d
+	} else {
+		return 0
+	}
+
+}
+
+//对操作代理 (operatorAgent) 中的信任值列表 (Listm) 进行混淆，以增强隐私保护
+func (operatorAgent *OperatorAgent) trustObfuscation() {
+	//初始化和准备数据集
+	size := len(operatorAgent.Listm)
+	var DataSet = make([]float64, size)
+
+	//将 Listm 中的信任值拷贝到 DataSet 中
+	var d int = 0
+	for i := 0; i < size; i++ {
+		DataSet[i] = operatorAgent.Listm[i].Val
+	}
+
+	//查找合适的 d 值：如果找到了合适的 d 值，立即跳出循环；如果没有找到，d 将保持为 0。
+	for j := 30; j >= 10; j-- {
+		d := operatorAgent.find_d(j, DataSet)
+		if d != 0 {
+			break
+		}
+	}
+
+	//使用默认值（30）或找到的 d 值
+	if d == 0 {
+		fmt.Println("[OA] Use the default value(30) to do obfuscation.")
+		d = 30
+	} else {
+		fmt.Printf("[OA] Use the chossen value(%d) to do obfuscation.\n", d)
+	}
+
+	//计算区间大小和剩余概率
+	var Ntv float64 = 1.0 / float64(d)
+	RN := 1.0 - float64(d)*Ntv
+	operatorAgent.D = d
+	//进行信任值混淆
+	for index := 0; index < size; index++ {
+		for c := 1; c <= d; c++ {
+			if operatorAgent.Listm[index].Val > float64(c-1)*Ntv && operatorAgent.Listm[index].Val <= float64(c)*Ntv {
+				operatorAgent.Listm[index].Val = util.FloatRound(float64(c-1) * Ntv)
+
+			} else if operatorAgent.Listm[index].Val > 1.0-RN && operatorAgent.Listm[index].Val <= 1.0 {
+				operatorAgent.Listm[index].Val = util.FloatRound(1.0 - RN - Ntv)
+			}
+		}
+	}
+	fmt.Println("[OA] Obfuscation success!", operatorAgent.LocalAddress)
+}
+
+//trust evaluation - time delay
+//用于对操作代理 (operatorAgent) 中的信任值列表 (Listm) 进行基于时间延迟的信任值评估。
+func (operatorAgent *OperatorAgent) timeDelayEvaluation() {
+	//trust evaluation
+	fmt.Println("[OA] Start time delay trust evaluation .", operatorAgent.LocalAddress)
+	//related number
+
+	var K int = int(operatorAgent.BlockChain.PreviousBlock().K0)
+
+	//time delay
+	//var t float64 = 0.1
+	//遍历 Listm 并进行信任值更新
+	for index, group := range operatorAgent.Listm {
+		var newTrustValue float64 = 0.0
+		time_factor := math.Exp(-1.0 * math.Abs(float64(K-operatorAgent.U[group.Nym.String()])) / operatorAgent.conf.Protocol.TimeDelay)
+		newTrustValue = (time_factor / (time_factor + 1.0)) * group.Val
+		operatorAgent.Listm[index].Val = util.FloatRound(newTrustValue)
+		operatorAgent.U[group.Nym.String()] = 0
+	}
+
+	fmt.Println("[OA] Time delay trust evaluation done!", operatorAgent.LocalAddress)
+}
+
+/////////////////////////////////
+/////////////normal function
+//逆向洗牌操作
+func (operatorAgent *OperatorAgent) reverseShuffle() {
+
+	if len(operatorAgent.Listm) == 0 {
+		fmt.Println("[OA] The initial list creation started...")
+	}
+
+	// add new clients into reputation map
+	//遍历 operatorAgent.NewUEsBuffer 中的新客户，将其添加到解密列表中，并设置初始声誉
+	for _, nym := range operatorAgent.NewUEsBuffer {
+		operatorAgent.AddIntoDecryptedList(nym, 0.1)
+	}
+	
+	operatorAgent.clearBuffer()    //清空缓冲区
+
+	// add previous clients into reputation map
+	// construct the parameters    //构建参数列表
+	size := len(operatorAgent.Listm)
+	keys := make([]kyber.Point, size)
+	vals := make([]float64, size)
+
+	for index, _ := range operatorAgent.Listm {
+		keys[index] = operatorAgent.Listm[index].Nym
+		vals[index] = operatorAgent.Listm[index].Val
+	}
+
+	//编码和发送参数：
+	byteKeys := util.ProtobufEncodePointList(keys)
+	// send signal to OA
+	msg := &proto.Shuffle{
+		Keys:      byteKeys,
+		PlainVals: vals,
+		IsStart:   true,
+	}
+	fmt.Println("[OA] The shuffle of reverse direction  started...")
+	//fistly, last OA should process this by self
+	operatorAgent.handleReverseShuffleOA(msg)
+
+}
+
+func (operatorAgent *OperatorAgent) forwardShuffle() {
+
+	//construct TrustValue list (public & encrypted reputation)
+	//构建参数列表
+	size := len(operatorAgent.EnListm)
+	keys := make([]kyber.Point, size)
+	C1 := make([]kyber.Point, size)
+	C2 := make([]kyber.Point, size)
+
+	//存储
+	for index, _ := range operatorAgent.EnListm {
+		keys[index] = operatorAgent.EnListm[index].Nym
+		C1[index] = operatorAgent.EnListm[index].C1
+		C2[index] = operatorAgent.EnListm[index].C2
+	}
+
+	//编码
+	bytekeys := util.ProtobufEncodePointList(keys)
+	msg := &proto.Shuffle{Keys: bytekeys, C1: util.ProtobufEncodePointList(C1), C2: util.ProtobufEncodePointList(C2)}
+	fmt.Println("[OA] The shuffle of forward direction  started...")
+	//event := proto.NewEvent(proto.FORWARD_SHUFFLE, msg)
+	//Handle_OA(event, operatorAgent)
+	
+	//处理
+	operatorAgent.handleForwardShuffleOA(msg)
+
+}
+
+//负责将操作代理 (operatorAgent) 中的 Listm（声誉列表）同步到所有其他操作代理（包括自身）
+func (operatorAgent *OperatorAgent) syncListm(byteG []byte) {
+	// add clients into reputation map
+	// construct the parameters
+	size := len(operatorAgent.Listm)
+	nyms := make([]kyber.Point, size)
+	vals := make([]float64, size)
+
+	for index, _ := range operatorAgent.Listm {
+		nyms[index] = operatorAgent.Listm[index].Nym
+		vals[index] = operatorAgent.Listm[index].Val
+	}
+
+	byteNyms := util.ProtobufEncodePointList(nyms)
+
+	//the last OA creates the block of the new list, every OA appends it  //最后一个OA创建新列表的区块
+	var block *blockchain.Block
+	if len(operatorAgent.BlockChain.Blocks) == 0 {
+		block = operatorAgent.genesisBlock(byteG)
+	} else {
+		block = operatorAgent.createNewBlock(byteG)
+	}
+
+	// send signal to OA
+	msg := &proto.SyncRepMap{G: byteG, Nyms: byteNyms, Vals: vals, Block: blockchain.ToByteBlock(*block)}
+	fmt.Println("[OA] Sync the new listm to OAs.")
+	event := proto.NewEvent(proto.SYNC_REPMAP, msg)
+
+	//the last OA sends new listm to all OAs(including itself)
+	//发送同步事件
+	for _, OAAddr := range operatorAgent.OAList {
+		operatorAgent.out.Send(OAAddr, util.Encode(event))
+	}
+}
+
+//负责更新操作代理（operatorAgent）的拓扑结构，包括其前一跳（PreviousHop）、后一跳（NextHop）以及是否为最后一个操作代理（IsLastOA）
+func (operatorAgent *OperatorAgent) updateTopology() error {
+	//the topology of the config is in shuffle order  //配置中的拓扑已按混洗顺序排列
+	//每个值解析为传输层地址，并将其添加到 operatorAgent.OAList 中，同时处理解析过程中可能发生的错误。
+	for _, v := range operatorAgent.conf.OAAddrs() {
+		addr, err := operatorAgent.Socket.ResolveAddr(v)
+		if err != nil {
+			return err
+		}
+		operatorAgent.OAList = append(operatorAgent.OAList, addr)
+	}
+
+	//设置 operatorAgent 的前跳和后跳代理，并确定它是否是最后一个代理
+	found := false
+	for index, OAAddr := range operatorAgent.OAList {
+		//检查当前OA地址是否等于本地地址
+		if reflect.DeepEqual(OAAddr.String(), operatorAgent.LocalAddress.String()) {
+			// 如果当前OA是列表中的第一个
+			if index == 0 {
+				operatorAgent.PreviousHop = nil  // 前跳代理为空
+				operatorAgent.NextHop = operatorAgent.OAList[1]    // 后跳代理为列表中的第二个
+			} else if index == len(operatorAgent.OAList)-1 {        // 如果当前OA是列表中的最后一个
+				operatorAgent.PreviousHop = operatorAgent.OAList[index-1]    // 前跳代理为列表中的倒数第二个
+				operatorAgent.NextHop = nil    //// 后跳代理为空
+				operatorAgent.IsLastOA = true    // 标记为最后一个OA
+			} else {         // 如果当前OA既不是第一个也不是最后一个
+				operatorAgent.PreviousHop = operatorAgent.OAList[index-1]    // 前跳代理为列表中的前一个
+				operatorAgent.NextHop = operatorAgent.OAList[index+1]        // 后跳代理为列表中的后一个
+			} 
+			found = true
+			break    // 找到本地的OA地址后退出循环
+		}
+
+	}
+	if !found {
+		return errors.New("the local address " + operatorAgent.LocalAddress.String() + " is not in the topology of the config")
+	}
+
+	fmt.Println("[OA] The OA topology list is updated!", operatorAgent.LocalAddress)
+	fmt.Println("[OA] OA topology list:", operatorAgent.OAList)
+	return nil
+}
+
+//启动 OperatorAgent 的监听器，接收来自其他操作代理的 UDP 消息，并处理这些消息。
+func (operatorAgent *OperatorAgent) startOAListener() {
+	fmt.Println("[OA] OperatorAgent listener started...")
+	for {
+		//events arrive reassembled, acknowledged and without duplicates
+		msg, addr, err := operatorAgent.Socket.Receive()
+		if err != nil {
+			select {
+			case <-operatorAgent.stop:
+			default:
+				fmt.Println("[OA] Listener stopped:", err)
+			}
+			return
+		}
+		operatorAgent.Handle(msg, addr)
+	}
+}
+
+//将 OperatorAgent（OA）注册到云服务提供商（CSP）
+func (operatorAgent *OperatorAgent) registerOAToCSP() {
+
+	// set the parameters to register  设置注册所需的参数
+	bytePublicKey, _ := operatorAgent.PublicKey.MarshalBinary()
+	event := proto.NewEvent(proto.OA_REGISTER_CSP, &proto.Register{PublicKey: bytePublicKey})
+	//register to CSP
+	util.SendUntilDelivered(operatorAgent.Socket, operatorAgent.CSPAddress, util.Encode(event))
+}
+
+
+// New listens on listen ("" is the first free port from oa_ip:oa_port) and prepares the OA of the
+// topology at that address, CSPAddr "" is csp_ip:csp_port, privateKey nil picks a new long-term key
+func New(conf *config.Config, listen, CSPAddr string, privateKey kyber.Scalar) (*OperatorAgent, error) {
+	Socket, err := listenOA(conf, listen)
+	if err != nil {
+		return nil, err
+	}
+	//the address the others reach this node at, also when it listens on all interfaces
+	LocalAddr, err := Socket.ResolveAddr(transport.AdvertisedAddr(Socket.LocalAddr(), conf.Conn.OAIP))
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+	fmt.Println("[OA] Local address is:", LocalAddr)
+
+	operatorAgent, err := initOA(conf, LocalAddr, Socket, CSPAddr, privateKey)
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+	return operatorAgent, nil
+}
+
+func listenOA(conf *config.Config, listen string) (transport.Transport, error) {
+	if listen != "" {
+		return transport.Listen(conf.Conn.Transport, listen)
+	}
+	// check available port      //检查可用端口
+	Port := conf.Conn.OAPort
+	for i := Port; i <= Port+1000; i++ {
+		//在配置的传输层（UDP/TCP/内存）上监听一个IP地址和端口号
+		conn, err := transport.Listen(conf.Conn.Transport, net.JoinHostPort(conf.Conn.OAIP, strconv.Itoa(i)))
+		if err == nil {    //监听未报错
+			return conn, nil
+		}
+	}
+	return nil, errors.New("no free port from " + net.JoinHostPort(conf.Conn.OAIP, strconv.Itoa(Port)))
+}
+
+//初始化 OperatorAgent（OA）的各种参数
+//privateKey is the long-term key, nil picks a new one
+func initOA(conf *config.Config, LocalAddr net.Addr, Socket transport.Transport, CSPStr string, privateKey kyber.Scalar) (*OperatorAgent, error) {
+
+	//get csp's ip address    // 获取CSP的IP地址
+	if CSPStr == "" {
+		CSPStr = conf.CSPAddr()
+	}
+	CSPAddr, err := Socket.ResolveAddr(CSPStr)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("[OA] CSP's IP address :", CSPAddr)
+
+	//initlize suite
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()  // Use the edwards25519-curve
+	a := privateKey
+	if a == nil {
+		a = suite.Scalar().Pick(suite.RandomStream()) // Alice's private key
+	}
+	A := suite.Point().Mul(a, nil)
+	if pinned := conf.OAKey(LocalAddr.String()); pinned != nil && !pinned.Equal(A) {
+		return nil, errors.New("the topology pins another public key for " + LocalAddr.String() + ", start with -key and its private key")
+	}
+
+	Roundkey := suite.Scalar().Pick(random.New())
+
+	operatorAgent := &OperatorAgent{
+		LocalAddr, transport.Secure(Socket, suite, a, A, LocalAddr.String()),
+		suite, a, A, nil,
+		0, FREE, DEFAULT, nil, make(map[string]kyber.Point), nil, make(map[string]kyber.Point), CSPAddr, make(map[string]kyber.Point), nil,
+		nil, nil, nil, nil, 0, nil, nil, 0, make(map[string]int),
+		false, nil, nil, make(map[string]kyber.Point), Roundkey, suite.Point().Base(),
+		0, 0, util.NewReplayGuard(), "", 0, nil, make(map[int64]*bftHeight), nil, nil, nil,
+		conf, make(chan struct{}), sync.Mutex{}, nil}
+	operatorAgent.phase = sync.NewCond(&operatorAgent.mu)
+	operatorAgent.out = util.NewOutbox(operatorAgent.Socket)
+	// 更新拓扑
+	if err := operatorAgent.updateTopology(); err != nil {
+		return nil, err
+	}
+	//the CSP and the OAs are only trusted with pinned keys, the keys the topology does not pin
+	//come from the CSP  //CSP和OA的会话只接受固定的公钥
+	operatorAgent.Socket.Pin(CSPAddr.String(), conf.CSPKey())
+	for _, OAAddr := range operatorAgent.OAList {
+		if OAAddr.String() == LocalAddr.String() {
+			operatorAgent.addOAKey(OAAddr, A)
+		} else if key := conf.OAKey(OAAddr.String()); key != nil {
+			operatorAgent.addOAKey(OAAddr, key)
+		} else {
+			operatorAgent.Socket.Pin(OAAddr.String(), nil)
+		}
+	}
+	if err := operatorAgent.loadBlockChain(); err != nil {
+		return nil, err
+	}
+	if path := conf.TranscriptPath(LocalAddr.String()); path != "" {
+		if operatorAgent.transcripts, err = shuffle.OpenArchive(path); err != nil {
+			operatorAgent.BlockChain.Close()
+			return nil, err
+		}
+	}
+	fmt.Println("[OA] Parameter initialization is complete.")
+	fmt.Println("[OA] My public key is ", operatorAgent.PublicKey)
+	return operatorAgent, nil
+}
+
+// Start runs the listener and registers with the CSP, which sends the keys of the other OAs of the
+// topology once they all registered
+func (operatorAgent *OperatorAgent) Start() {
+	go operatorAgent.startOAListener()
+	operatorAgent.registerOAToCSP()
+}
+
+// WaitForAPs blocks until the CSP accepted this OA and aps APs registered
+func (operatorAgent *OperatorAgent) WaitForAPs(aps int) {
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+	for len(operatorAgent.CSPKeyList) == 0 || len(operatorAgent.APList) < aps {
+		operatorAgent.phase.Wait()
+	}
+}
+
+// Run waits for the keys of the other OAs of the topology and runs rounds list maintenance rounds,
+// each with consensusRounds consensus rounds
+func (operatorAgent *OperatorAgent) Run(rounds, consensusRounds int) {
+	//Run holds the lock except while it waits, sleeps or mines  //主循环持有锁，等待、休眠和挖矿时释放
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+
+	//the shuffles need the keys of all OAs in the topology  // 等待拓扑中所有OA的公钥
+	for len(operatorAgent.OAKeyList) < len(operatorAgent.OAList) {
+		operatorAgent.phase.Wait()
+	}
+
+	//the cycle of listMaintence
+	for k := 0; k < rounds; k++ {
+		//a late or restarted OA catches up before it shuffles  //落后或重启的OA先同步区块链再进入混洗
+		operatorAgent.syncChain()
+		if operatorAgent.IsLastOA == true {
+			operatorAgent.reverseShuffle()   //最后一个OA开启后向混洗
+		}
+		
+		for operatorAgent.Status != READY_FOR_NEW_ROUND {
+			//wait for nym update done   // 检测状态，等待假名更新完成
+			operatorAgent.phase.Wait()
+		}
+		//the cycle of consensus
+		for i := 0; i < consensusRounds; i++ {
+			operatorAgent.sleep(1.0 * time.Second)
+			
+			operatorAgent.dataCollectionOA()
+
+			for operatorAgent.Status != READY_FOR_CONSENSUS {
+				//wait for data collection && evaluation done     //  检测状态，等待数据收集和评估完成
+				operatorAgent.phase.Wait()
+			}
+
+			//consensus  //共识
+			operatorAgent.MineStatus = READY
+			
+			if operatorAgent.conf.Protocol.Consensus == "bft" {
+				//the OAs vote on the block of a proposer  //BFT 投票共识
+				operatorAgent.bftConsensus()
+			} else {
+				go operatorAgent.CountDownListening()
+
+				operatorAgent.startMine()
+
+				for operatorAgent.MineStatus != FINISH {
+					//wait for OA's status turn to FINISH
+					operatorAgent.phase.Wait()
+				}
+			}
+			
+			operatorAgent.consensusEnd()
+			
+		}
+		//list maintenance
+		operatorAgent.listPublish()
+		operatorAgent.listConfirmation()
+		operatorAgent.timeDelayEvaluation()
+		operatorAgent.printTrustValue()
+		operatorAgent.trustObfuscation()
+		operatorAgent.Status = DEFAULT
+
+	}
+}
+
+//sleep releases the lock for d, the caller holds it
+func (operatorAgent *OperatorAgent) sleep(d time.Duration) {
+	operatorAgent.mu.Unlock()
+	time.Sleep(d)
+	operatorAgent.mu.Lock()
+}
+
+//flush releases the lock until the queued events were sent, the caller holds it
+func (operatorAgent *OperatorAgent) flush() {
+	operatorAgent.mu.Unlock()
+	operatorAgent.out.Flush()
+	operatorAgent.mu.Lock()
+}
+
+// Stop sends the queued events and closes the socket, the block store and the transcripts, the listener returns
+func (operatorAgent *OperatorAgent) Stop() {
+	close(operatorAgent.stop)
+	operatorAgent.out.Close()
+	operatorAgent.Socket.Close()
+	operatorAgent.mu.Lock()
+	defer operatorAgent.mu.Unlock()
+	operatorAgent.BlockChain.Close()
+	if operatorAgent.transcripts != nil {
+		operatorAgent.transcripts.Close()
+	}
+}
+
+func (operatorAgent *OperatorAgent) printTrustValue(){
+	fmt.Println("[OA] Nodes' Trust Value as follow:")
+	fmt.Println("[OA] ============================================")
+	for seq, trustValue := range operatorAgent.Listm {
+		fmt.Println("[OA] ================= Node", seq, "==================\n|---Pseudonyms:", trustValue.Nym, "\n|---TrustValue:", trustValue.Val)
+	}
+	fmt.Println("[OA] ============================================")
+	
+	/*输出示例
+	[OA] Nodes' Trust Value as follow:
+	[OA] ============================================
+	[OA] ================= Node 0 ==================
+	|---Pseudonyms: Nym1
+	|---TrustValue: 0.123456
+	[OA] ================= Node 1 ==================
+	|---Pseudonyms: Nym2
+	|---TrustValue: 0.654321
+	[OA] ============================================
+	*/
+}
+
+
@@ -0,0 +1,156 @@
+package oa
+
+import (
+	"NPTM/blockchain"
+	"NPTM/config"
+	"NPTM/util"
+	"net"
+	"testing"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+)
+
+//testOA is an OA with an empty chain in memory, enough for the chain handling
+func testOA() *OperatorAgent {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	private := suite.Scalar().Pick(suite.RandomStream())
+	return &OperatorAgent{Suite: suite, PrivateKey: private, PublicKey: suite.Point().Mul(private, nil),
+		BlockChain: &blockchain.BlockChain{}, U: make(map[string]int), conf: config.Default()}
+}
+
+//testNyms are n nyms drawn from a seed
+func testNyms(seed string, n int) []kyber.Point {
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	nyms := make([]kyber.Point, n)
+	for i := range nyms {
+		nyms[i] = suite.Point().Pick(suite.XOF(append([]byte(seed), byte(i))))
+	}
+	return nyms
+}
+
+//listBlock is a block after parent holding the nyms, mined by creator when it is not nil
+func listBlock(o *OperatorAgent, parent *blockchain.Block, timestamp int64, nyms []kyber.Point, creator kyber.Point) *blockchain.Block {
+	vals := make([]float64, len(nyms))
+	for i := range vals {
+		vals[i] = float64(i+1) / 10
+	}
+	block := &blockchain.Block{Timestamp: timestamp, Nyms: util.ProtobufEncodePointList(nyms), Vals: vals}
+	if parent != nil {
+		block.K0, block.Nb, block.PreHash = parent.K0+1, parent.Nb+1, parent.BlockHash()
+	}
+	if creator != nil {
+		block.PublicKey, _ = creator.MarshalBinary()
+		block.MerkelRoot0 = []byte("records")
+		block.Target = o.BlockChain.Target(block.PreHash, o.conf.Difficulty())
+	}
+	return block
+}
+
+//a branch that wins the fork choice replaces the live list, U and Npk follow the new tip
+func TestAddBlockSwitch(t *testing.T) {
+	o := testOA()
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	other := suite.Point().Pick(suite.XOF([]byte("other OA")))
+	now := time.Now().UnixMilli()
+	shared, ours, theirs := testNyms("shared", 2), testNyms("ours", 2), testNyms("theirs", 3)
+
+	genesis := listBlock(o, nil, now-3000, shared, nil)
+	if err := o.addBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	mine := listBlock(o, genesis, now-1000, ours, o.PublicKey)
+	if err := o.addBlock(mine); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.setListm(mine); err != nil {
+		t.Fatal(err)
+	}
+	if o.Npk != 1 || len(o.U) != 4 {
+		t.Fatalf("Npk %d and %d nyms in U after the own block, want 1 and 4", o.Npk, len(o.U))
+	}
+
+	//the other OA's block of the same height is earlier and wins the tie
+	theirBlock := listBlock(o, genesis, now-2000, theirs, other)
+	if err := o.addBlock(theirBlock); err != nil {
+		t.Fatal(err)
+	}
+	if o.BlockChain.PreviousBlock() != theirBlock {
+		t.Fatal("the earlier block of the same height is not the tip")
+	}
+	if len(o.Listm) != len(theirs) {
+		t.Fatalf("Listm has %d pairs, want the %d of the new tip", len(o.Listm), len(theirs))
+	}
+	for i, pair := range o.Listm {
+		if !pair.Nym.Equal(theirs[i]) || pair.Val != theirBlock.Vals[i] {
+			t.Fatalf("pair %d of Listm is not the one of the new tip", i)
+		}
+	}
+	if o.Npk != 0 {
+		t.Fatalf("Npk is %d after the own block left the chain, want 0", o.Npk)
+	}
+	for _, nym := range ours {
+		if _, ok := o.U[nym.String()]; ok {
+			t.Fatal("U keeps a nym of the block that left the chain")
+		}
+	}
+	for _, nym := range theirs {
+		if o.U[nym.String()] != int(theirBlock.K0) {
+			t.Fatal("U misses a nym of the new tip")
+		}
+	}
+	for _, nym := range shared {
+		if o.U[nym.String()] != int(genesis.K0) {
+			t.Fatal("U misses a nym of the genesis block")
+		}
+	}
+
+	//the own branch grows longer and comes back
+	next := listBlock(o, mine, now, ours[:1], other)
+	if err := o.addBlock(next); err != nil {
+		t.Fatal(err)
+	}
+	if o.BlockChain.PreviousBlock() != next || o.Npk != 1 || len(o.Listm) != 1 || !o.Listm[0].Nym.Equal(ours[0]) {
+		t.Fatal("the longer branch did not bring back its list and Npk")
+	}
+	if o.U[ours[0].String()] != int(next.K0) || o.U[ours[1].String()] != int(mine.K0) {
+		t.Fatal("U does not hold the latest block of every nym")
+	}
+}
+
+//the timestamp of a block is checked against its parent and the clock, both up to the clock skew
+func TestCheckTime(t *testing.T) {
+	o := testOA()
+	o.conf.Protocol.ClockSkew = 2000
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10001}
+	for _, c := range []struct {
+		name          string
+		parent, block int64 //ms from now
+		valid, fresh  bool
+	}{
+		{"now", -10000, 0, true, true},
+		{"ahead within the skew", -10000, 1500, true, true},
+		{"ahead beyond the skew", -10000, 2500, false, false},
+		{"behind within the skew", -10000, -1500, true, true},
+		//a block of an earlier round is valid in the chain but not the block just found
+		{"behind beyond the skew", -10000, -2500, true, false},
+		{"before the parent within the skew", 1000, -500, true, true},
+		{"before the parent beyond the skew", 1000, -1500, false, false},
+		{"parent ahead beyond the skew", 3000, 1500, true, true},
+	} {
+		now := time.Now().UnixMilli()
+		o.BlockChain = &blockchain.BlockChain{}
+		parent := &blockchain.Block{Timestamp: now + c.parent}
+		if _, err := o.BlockChain.AddBlock(parent); err != nil {
+			t.Fatal(err)
+		}
+		block := &blockchain.Block{K0: 1, Nb: 1, Timestamp: now + c.block, PreHash: parent.BlockHash()}
+		if err := o.checkTime(block); (err == nil) != c.valid {
+			t.Fatalf("%s: checkTime gives %v", c.name, err)
+		}
+		if fresh := o.freshBlock(block, addr); fresh != c.fresh {
+			t.Fatalf("%s: freshBlock gives %v, want %v", c.name, fresh, c.fresh)
+		}
+	}
+}
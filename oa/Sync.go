@@ -0,0 +1,244 @@
+package oa
+
+import (
+	"NPTM/blockchain"
+	"NPTM/proto"
+	"NPTM/util"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// a late or restarted OA pulls the blocks it misses from the other OAs: the headers after its tip
+// first, checked against the tip, then the blocks of these headers. The requests are answered
+// from the canonical chain.  //落后或重启的OA从其他OA同步区块
+
+//syncBatch is the largest number of headers or blocks in one reply
+const syncBatch = 64
+
+//handleHeadersRequest sends the headers after msg.From, or from msg.Height on  //按高度或哈希范围返回区块头
+func (operatorAgent *OperatorAgent) handleHeadersRequest(msg *proto.HeadersRequest, addr net.Addr) {
+	blocks := operatorAgent.BlockChain.Blocks
+	start := int(msg.Height)
+	if len(msg.From) != 0 {
+		from := operatorAgent.BlockChain.BlockByHash(msg.From)
+		if from == nil {
+			//the requester's tip is not in this chain, it gets no headers
+			start = len(blocks)
+		} else {
+			start = int(from.Nb) + 1
+		}
+	}
+	max := int(msg.Max)
+	if max == 0 || max > syncBatch {
+		max = syncBatch
+	}
+
+	reply := &proto.Headers{Nonce: msg.Nonce, Height: int64(len(blocks))}
+	if tip := operatorAgent.BlockChain.PreviousBlock(); tip != nil {
+		reply.Tip = tip.BlockHash()
+	}
+	for i := start; i < len(blocks) && len(reply.Headers) < max; i++ {
+		reply.Headers = append(reply.Headers, blockchain.ToByteHeader(blocks[i].Header()))
+		if len(msg.To) != 0 && bytes.Equal(blocks[i].BlockHash(), msg.To) {
+			break
+		}
+	}
+	operatorAgent.out.Send(addr, util.Encode(proto.NewEvent(proto.SYNC_HEADERS, reply)))
+}
+
+//handleBodiesRequest sends the blocks of the requested hashes  //返回区块体
+func (operatorAgent *OperatorAgent) handleBodiesRequest(msg *proto.BodiesRequest, addr net.Addr) {
+	reply := &proto.Bodies{Nonce: msg.Nonce}
+	for _, hash := range msg.Hashes {
+		block := operatorAgent.BlockChain.BlockByHash(hash)
+		if block == nil || len(reply.Blocks) == syncBatch {
+			break
+		}
+		reply.Blocks = append(reply.Blocks, blockchain.ToByteBlock(*block))
+	}
+	operatorAgent.out.Send(addr, util.Encode(proto.NewEvent(proto.SYNC_BODIES, reply)))
+}
+
+//handleSyncReply hands the reply of the pending request to syncChain, other replies are stale
+func (operatorAgent *OperatorAgent) handleSyncReply(nonce int64, msg proto.Message, addr net.Addr) {
+	if addr.String() != operatorAgent.syncFrom || nonce != operatorAgent.syncNonce {
+		fmt.Println("[OA] Drop the stale sync reply from", addr)
+		return
+	}
+	operatorAgent.syncReply = msg
+}
+
+//request sends msg to addr and waits for the reply with its nonce, nil if none came in time.
+//The caller holds the lock.
+func (operatorAgent *OperatorAgent) request(addr net.Addr, eventType int, msg proto.Message) proto.Message {
+	operatorAgent.syncFrom = addr.String()
+	operatorAgent.syncReply = nil
+	operatorAgent.out.Send(addr, util.Encode(proto.NewEvent(eventType, msg)))
+
+	timeout := time.Duration(operatorAgent.conf.Protocol.SyncTimeout) * time.Millisecond
+	operatorAgent.waitTimeout(timeout, func() bool { return operatorAgent.syncReply != nil })
+	reply := operatorAgent.syncReply
+	operatorAgent.syncFrom = ""
+	operatorAgent.syncReply = nil
+	return reply
+}
+
+//waitTimeout waits on phase until done holds or d passed and reports done, the caller holds the lock
+func (operatorAgent *OperatorAgent) waitTimeout(d time.Duration, done func() bool) bool {
+	expired := false
+	timer := time.AfterFunc(d, func() {
+		operatorAgent.mu.Lock()
+		expired = true
+		operatorAgent.mu.Unlock()
+		operatorAgent.phase.Broadcast()
+	})
+	defer timer.Stop()
+	for !done() && !expired {
+		operatorAgent.phase.Wait()
+	}
+	return done()
+}
+
+//syncChain fetches the blocks the other OAs have after the local tip, a peer on another branch
+//is followed back to the fork point and its branch goes into the tree, where the fork choice
+//decides. A peer that does not answer in time is skipped. The caller holds the lock.
+func (operatorAgent *OperatorAgent) syncChain() {
+	for _, peer := range operatorAgent.OAList {
+		if peer.String() == operatorAgent.LocalAddress.String() {
+			continue
+		}
+		var anchor *blockchain.Header
+		if tip := operatorAgent.BlockChain.PreviousBlock(); tip != nil {
+			header := tip.Header()
+			anchor = &header
+		}
+		step := 1
+		for {
+			last, added, reply, err := operatorAgent.syncFromPeer(peer, anchor)
+			if err != nil {
+				fmt.Println("[OA] Sync with", peer, "failed:", err)
+				break
+			}
+			if last != nil {
+				if added != 0 {
+					fmt.Println("[OA] Synced", added, "blocks from", peer, ", the chain has", len(operatorAgent.BlockChain.Blocks), "blocks, the peer", reply.Height, ".")
+				}
+				anchor = last
+				continue
+			}
+			//no headers: the peer's tip is known or the peer's chain does not hold the anchor
+			if anchor == nil || operatorAgent.BlockChain.Known(reply.Tip) {
+				break
+			}
+			//the peer is on another branch, look for the fork point further back  //对方在另一分支上，向前寻找分叉点
+			fmt.Println("[OA] The chain of", peer, "does not hold block", anchor.K0, ", look further back.")
+			back := int(anchor.Nb) - step
+			step *= 2
+			if back < 0 {
+				anchor = nil
+			} else {
+				header := operatorAgent.BlockChain.Blocks[back].Header()
+				anchor = &header
+			}
+		}
+	}
+}
+
+//syncFromPeer fetches one batch of headers after anchor (from the genesis block if nil) and the
+//blocks of the headers that are not in the tree yet. It returns the last header, the number of
+//blocks added and the peer's reply.
+func (operatorAgent *OperatorAgent) syncFromPeer(peer net.Addr, anchor *blockchain.Header) (*blockchain.Header, int, *proto.Headers, error) {
+	request := &proto.HeadersRequest{Max: syncBatch}
+	height := 0
+	if anchor != nil {
+		request.From = anchor.Hash()
+		height = int(anchor.Nb) + 1
+	}
+	operatorAgent.syncNonce++
+	request.Nonce = operatorAgent.syncNonce
+	reply, ok := operatorAgent.request(peer, proto.SYNC_HEADERS_REQUEST, request).(*proto.Headers)
+	if !ok {
+		return nil, 0, nil, errors.New("no headers in time")
+	}
+
+	//the headers have to continue the anchor one after the other  //区块头必须依次接在锚点区块之后
+	prev := anchor
+	var missing [][]byte
+	for i, byteHeader := range reply.Headers {
+		header, err := blockchain.DecodeHeader(byteHeader)
+		if err != nil {
+			return nil, 0, reply, fmt.Errorf("header %d can not be decoded: %v", height+i, err)
+		}
+		if err := header.Follows(prev, height+i); err != nil {
+			return nil, 0, reply, fmt.Errorf("header %d: %v", height+i, err)
+		}
+		if err := header.Check(); err != nil {
+			return nil, 0, reply, fmt.Errorf("header %d: %v", height+i, err)
+		}
+		if err := operatorAgent.checkCreator(&header); err != nil {
+			return nil, 0, reply, fmt.Errorf("header %d: %v", height+i, err)
+		}
+		if hash := header.Hash(); !operatorAgent.BlockChain.Known(hash) {
+			missing = append(missing, hash)
+		}
+		prev = &header
+	}
+	if len(reply.Headers) == 0 {
+		return nil, 0, reply, nil
+	}
+	if len(missing) == 0 {
+		return prev, 0, reply, nil
+	}
+
+	operatorAgent.syncNonce++
+	bodies, ok := operatorAgent.request(peer, proto.SYNC_BODIES_REQUEST, &proto.BodiesRequest{Nonce: operatorAgent.syncNonce, Hashes: missing}).(*proto.Bodies)
+	if !ok {
+		return nil, 0, reply, errors.New("no blocks in time")
+	}
+	if len(bodies.Blocks) != len(missing) {
+		return nil, 0, reply, fmt.Errorf("got %d of %d blocks", len(bodies.Blocks), len(missing))
+	}
+	for i, byteBlock := range bodies.Blocks {
+		block, err := blockchain.DecodeBlock(byteBlock)
+		if err != nil {
+			return nil, i, reply, fmt.Errorf("block %x can not be decoded: %v", missing[i], err)
+		}
+		if !bytes.Equal(block.BlockHash(), missing[i]) {
+			return nil, i, reply, fmt.Errorf("block %d does not match its header", block.K0)
+		}
+		if err := block.Check(); err != nil {
+			return nil, i, reply, fmt.Errorf("block %d: %v", block.K0, err)
+		}
+		if err := operatorAgent.addBlock(&block); err != nil {
+			return nil, i, reply, fmt.Errorf("block %d: %v", block.K0, err)
+		}
+	}
+	return prev, len(bodies.Blocks), reply, nil
+}
+
+//checkCreator checks that an OA of the topology created the block, the blocks of the lists are
+//created by the last OA
+func (operatorAgent *OperatorAgent) checkCreator(header *blockchain.Header) error {
+	if !header.Mined() {
+		lastOA := operatorAgent.OAList[len(operatorAgent.OAList)-1]
+		creator, ok := operatorAgent.OAKeyList[lastOA.String()]
+		if !ok {
+			return errors.New("the key of the last OA is unknown")
+		}
+		bytePK, _ := creator.MarshalBinary()
+		if !bytes.Equal(header.PublicKey, bytePK) {
+			return errors.New("the list block is not created by the last OA")
+		}
+		return nil
+	}
+	for _, key := range operatorAgent.OAKeyList {
+		bytePK, _ := key.MarshalBinary()
+		if bytes.Equal(header.PublicKey, bytePK) {
+			return nil
+		}
+	}
+	return errors.New("the block is not created by an OA of the topology")
+}
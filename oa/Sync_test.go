@@ -0,0 +1,73 @@
+package oa
+
+import (
+	"NPTM/blockchain"
+	"bytes"
+	"testing"
+	"time"
+)
+
+//extendList appends n list blocks of the last OA to the chains of the running OAs
+func extendList(t *testing.T, oas []*OperatorAgent, n int) {
+	last := oas[len(oas)-1]
+	tip := last.BlockChain.PreviousBlock()
+	start := time.Now().UnixMilli() - int64(n)
+	for i := 0; i < n; i++ {
+		nyms, vals, root := last.listConversion(nil)
+		pk, _ := last.PublicKey.MarshalBinary()
+		block := &blockchain.Block{K0: tip.K0 + 1, Timestamp: start + int64(i), PreHash: tip.BlockHash(), Nb: tip.Nb + 1,
+			MerkelRoot1: root, PublicKey: pk, Nyms: nyms, Vals: vals}
+		last.signBlock(block)
+		for _, o := range oas {
+			if o == nil {
+				continue
+			}
+			copied := *block
+			if err := o.addBlock(&copied); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tip = block
+	}
+}
+
+// an OA that joins late pulls the chain of the others in batches and ends on their tip
+func TestSyncLateOA(t *testing.T) {
+	conf, keys := testCluster(t, 3, 11200, "pow")
+	oas := startCluster(t, conf, keys, 0)
+	//more blocks than one batch of headers holds
+	extendList(t, oas, syncBatch+6)
+	tip := oas[1].BlockChain.PreviousBlock()
+
+	late, err := New(conf, conf.Topology[0].Addr, "", keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(late.Stop)
+	go late.startOAListener()
+	late.mu.Lock()
+	late.syncChain()
+	late.mu.Unlock()
+
+	blocks := late.BlockChain.Blocks
+	if len(blocks) != len(oas[1].BlockChain.Blocks) || !bytes.Equal(blocks[len(blocks)-1].BlockHash(), tip.BlockHash()) {
+		t.Fatalf("the late OA has %d blocks, the others %d", len(blocks), len(oas[1].BlockChain.Blocks))
+	}
+	if err := late.BlockChain.Validate(conf.Difficulty()); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range oas[1].Listm {
+		if late.U[pair.Nym.String()] != int(tip.K0) {
+			t.Fatal("U of the late OA does not follow the synced chain")
+		}
+	}
+
+	//a later block reaches the late OA with the next sync
+	extendList(t, oas[1:], 1)
+	late.mu.Lock()
+	late.syncChain()
+	late.mu.Unlock()
+	if !bytes.Equal(late.BlockChain.PreviousBlock().BlockHash(), oas[2].BlockChain.PreviousBlock().BlockHash()) {
+		t.Fatal("the late OA does not catch up with the next block")
+	}
+}
@@ -1,8 +1,57 @@
 package proto
 
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+// Version of the wire format, events of other versions are rejected
+const Version = 7
+
+var (
+	ErrVersion      = errors.New("incompatible protocol version")
+	ErrUnknownEvent = errors.New("unknown event type")
+	ErrMalformed    = errors.New("malformed message")
+)
+
 type Event struct {
+	// protocol version of the sender
+	Version int
 	// event type
 	EventType int
-	// parameters
-	Params map[string]interface{}
+	// gob encoding of the message belonging to the event type
+	Body []byte
+}
+
+// NewEvent wraps msg, the message type must be the one of the event type
+func NewEvent(eventType int, msg Message) *Event {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(msg); err != nil {
+		panic(err.Error())
+	}
+	return &Event{Version, eventType, body.Bytes()}
+}
+
+// Decode reads an event and its message, any error means the packet has to be dropped
+func Decode(buf []byte) (*Event, Message, error) {
+	event := &Event{}
+	if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(event); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if event.Version != Version {
+		return event, nil, fmt.Errorf("%w: got %d, want %d", ErrVersion, event.Version, Version)
+	}
+	msg, err := newMessage(event.EventType)
+	if err != nil {
+		return event, nil, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(event.Body)).Decode(msg); err != nil {
+		return event, nil, fmt.Errorf("%w: event %d: %v", ErrMalformed, event.EventType, err)
+	}
+	if err := msg.Validate(); err != nil {
+		return event, nil, fmt.Errorf("%w: event %d: %v", ErrMalformed, event.EventType, err)
+	}
+	return event, msg, nil
 }
@@ -46,3 +46,33 @@ const REVERSE_SHUFFLE = 15
 const UNIQUE_LIST_CONFIRMATION = 16
 
 //const READY_FOR_MINE = 17
+
+//ask an OA for block headers by height or after a hash
+const SYNC_HEADERS_REQUEST = 18
+
+//the requested block headers
+const SYNC_HEADERS = 19
+
+//ask an OA for the blocks of some headers
+const SYNC_BODIES_REQUEST = 20
+
+//the requested blocks
+const SYNC_BODIES = 21
+
+//the block the proposer of a BFT view proposes
+const BFT_PROPOSAL = 22
+
+//a prevote of a BFT view
+const BFT_PREVOTE = 23
+
+//a precommit of a BFT view
+const BFT_PRECOMMIT = 24
+
+//a block agreed on by BFT with its commit
+const BFT_COMMIT = 25
+
+//the proposer asks the OAs that precommitted a block to sign it collectively
+const BFT_CHALLENGE = 26
+
+//the response of an OA to the challenge of the collective signature
+const BFT_RESPONSE = 27
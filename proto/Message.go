@@ -0,0 +1,312 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Message is the typed content of an event
+type Message interface {
+	// Validate checks the fields every handler relies on
+	Validate() error
+}
+
+// Register carries the long-term key of the sender
+// (AP_REGISTER, OA_REGISTER_CSP, OA_REGISTER_OAS, UE_REGISTER_APSIDE)
+type Register struct {
+	PublicKey []byte
+}
+
+// RegisterReply accepts a registration
+// (AP_REGISTER_REPLY_OA, AP_REGISTER_REPLY_CSP, OA_REGISTER_REPLY_CSP, the CSP adds its key)
+type RegisterReply struct {
+	Reply     bool
+	PublicKey []byte
+	//the keys of the OAs in topology order, in the CSP's reply to an AP
+	OAKeys []byte
+}
+
+// UERegister passes a UE's key along the OAs (UE_REGISTER_OASIDE)
+type UERegister struct {
+	PublicKey []byte
+	UEAddr    string
+	UpperAP   string
+}
+
+// UERegisterConfirmation tells a UE it is registered (UE_REGISTER_CONFIRMATION)
+type UERegisterConfirmation struct{}
+
+// Shuffle is one hop of the forward or reverse shuffle (FORWARD_SHUFFLE, REVERSE_SHUFFLE)
+type Shuffle struct {
+	//point list of the keys
+	Keys []byte
+	//point lists of the ElGamal ciphertexts (C1, C2) of the values, the value of key i is (C1[i], C2[i])
+	C1, C2 []byte
+	//values in clear, only on the first hop of the reverse shuffle
+	PlainVals []float64
+	IsStart   bool
+	//generator of this round, forward shuffle only
+	G []byte
+
+	//proof of the previous hop
+	Shuffled bool
+	Xbar     []byte
+	Ybar     []byte
+	PrevKeys []byte
+	PrevVals []byte
+	//the ciphertexts it shuffled to C1, C2, in the same proof
+	PrevC1, PrevC2 []byte
+	//forward shuffle only: the C2 it got, before it removed its share of the key of the values to
+	//PrevC2, and the proof of that
+	RecvC2    []byte
+	DecProof  []byte
+	Proof     []byte
+	PublicKey []byte
+}
+
+// SyncRepMap distributes the reputation list and g of a round (SYNC_REPMAP), UEs only get g
+type SyncRepMap struct {
+	G    []byte
+	Nyms []byte
+	Vals []float64
+	//the block of the list created by the last OA, OAs only
+	Block []byte
+	//the header of that block, APs only
+	Header []byte
+}
+
+// DataCollection is a data request of an OA or one signed record
+// (DATA_COLLECTION_AP, DATA_COLLECTION_OA)
+type DataCollection struct {
+	Require bool
+	Start   bool
+	Done    bool
+	Nym     []byte
+	Data    []float64
+	//the signature covers the record, the round and the sender's sequence number
+	Round  int64
+	Seq    int64
+	SignRe []byte
+}
+
+// BlockMessage carries a block signed by its creator (RECEIVE_BLOCK, UNIQUE_LIST_CONFIRMATION)
+type BlockMessage struct {
+	Block []byte
+	//the signature covers the block hash, the round and the sender's sequence number
+	Round  int64
+	Seq    int64
+	SignBK []byte
+}
+
+// HeadersRequest asks for the headers of the sender's chain (SYNC_HEADERS_REQUEST), starting
+// after the block with hash From or, without From, at height Height. At most Max headers are sent,
+// the range ends early at the block with hash To.
+type HeadersRequest struct {
+	//echoed in the reply
+	Nonce  int64
+	Height int64
+	From   []byte
+	To     []byte
+	Max    int64
+}
+
+// Headers answers a HeadersRequest (SYNC_HEADERS), no headers if From is not in the chain
+type Headers struct {
+	Nonce   int64
+	Headers [][]byte
+	//number of blocks in the sender's chain and the hash of its tip
+	Height int64
+	Tip    []byte
+}
+
+// BodiesRequest asks for the blocks of the given hashes (SYNC_BODIES_REQUEST)
+type BodiesRequest struct {
+	Nonce  int64
+	Hashes [][]byte
+}
+
+// Bodies answers a BodiesRequest in its order, up to the first unknown hash (SYNC_BODIES)
+type Bodies struct {
+	Nonce  int64
+	Blocks [][]byte
+}
+
+// Proposal carries the block of a BFT view, signed by its creator (BFT_PROPOSAL), or the final
+// block with its commit (BFT_COMMIT)
+type Proposal struct {
+	Block []byte
+	View  int64
+}
+
+// Vote is a prevote or precommit of the block with hash Hash, no block if Hash is empty
+// (BFT_PREVOTE, BFT_PRECOMMIT). Sign is the Schnorr signature of the PrevoteMessage or PrecommitMessage.
+type Vote struct {
+	Height int64
+	View   int64
+	Hash   []byte
+	Sign   []byte
+	//the CoSi commitment of the sender, precommits of a block only
+	Commitment []byte
+}
+
+// Challenge fixes the signers of the collective signature of the block with hash Hash and their
+// aggregate commitment (BFT_CHALLENGE)
+type Challenge struct {
+	Height     int64
+	View       int64
+	Hash       []byte
+	Commitment []byte
+	Signers    []byte
+}
+
+// Response is the share of one signer in the collective signature (BFT_RESPONSE)
+type Response struct {
+	Height   int64
+	View     int64
+	Response []byte
+}
+
+func newMessage(eventType int) (Message, error) {
+	switch eventType {
+	case AP_REGISTER, OA_REGISTER_CSP, OA_REGISTER_OAS, UE_REGISTER_APSIDE:
+		return &Register{}, nil
+	case AP_REGISTER_REPLY_OA, AP_REGISTER_REPLY_CSP, OA_REGISTER_REPLY_CSP:
+		return &RegisterReply{}, nil
+	case UE_REGISTER_OASIDE:
+		return &UERegister{}, nil
+	case UE_REGISTER_CONFIRMATION:
+		return &UERegisterConfirmation{}, nil
+	case FORWARD_SHUFFLE, REVERSE_SHUFFLE:
+		return &Shuffle{}, nil
+	case SYNC_REPMAP:
+		return &SyncRepMap{}, nil
+	case DATA_COLLECTION_AP, DATA_COLLECTION_OA:
+		return &DataCollection{}, nil
+	case RECEIVE_BLOCK, UNIQUE_LIST_CONFIRMATION:
+		return &BlockMessage{}, nil
+	case SYNC_HEADERS_REQUEST:
+		return &HeadersRequest{}, nil
+	case SYNC_HEADERS:
+		return &Headers{}, nil
+	case SYNC_BODIES_REQUEST:
+		return &BodiesRequest{}, nil
+	case SYNC_BODIES:
+		return &Bodies{}, nil
+	case BFT_PROPOSAL, BFT_COMMIT:
+		return &Proposal{}, nil
+	case BFT_PREVOTE, BFT_PRECOMMIT:
+		return &Vote{}, nil
+	case BFT_CHALLENGE:
+		return &Challenge{}, nil
+	case BFT_RESPONSE:
+		return &Response{}, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownEvent, eventType)
+}
+
+func (m *Register) Validate() error {
+	if len(m.PublicKey) == 0 {
+		return errors.New("missing public key")
+	}
+	return nil
+}
+
+func (m *RegisterReply) Validate() error {
+	return nil
+}
+
+func (m *UERegister) Validate() error {
+	if len(m.PublicKey) == 0 || m.UEAddr == "" || m.UpperAP == "" {
+		return errors.New("missing public key or addresses")
+	}
+	return nil
+}
+
+func (m *UERegisterConfirmation) Validate() error {
+	return nil
+}
+
+func (m *Shuffle) Validate() error {
+	//the lists and the proof of an empty list are empty, the verifier checks their lengths
+	if m.Shuffled && len(m.PublicKey) == 0 {
+		return errors.New("incomplete shuffle proof")
+	}
+	if m.IsStart && (len(m.C1) != 0 || len(m.C2) != 0) {
+		return errors.New("encrypted values on the first hop")
+	}
+	return nil
+}
+
+func (m *SyncRepMap) Validate() error {
+	if len(m.G) == 0 {
+		return errors.New("missing g")
+	}
+	return nil
+}
+
+func (m *DataCollection) Validate() error {
+	if !m.Require && (len(m.Nym) == 0 || len(m.SignRe) == 0) {
+		return errors.New("record without nym or signature")
+	}
+	return nil
+}
+
+func (m *BlockMessage) Validate() error {
+	if len(m.Block) == 0 || len(m.SignBK) == 0 {
+		return errors.New("missing block or signature")
+	}
+	return nil
+}
+
+func (m *HeadersRequest) Validate() error {
+	if m.Height < 0 || m.Max < 0 {
+		return errors.New("negative height or limit")
+	}
+	return nil
+}
+
+func (m *Headers) Validate() error {
+	if m.Height < 0 {
+		return errors.New("negative height")
+	}
+	return nil
+}
+
+func (m *BodiesRequest) Validate() error {
+	if len(m.Hashes) == 0 {
+		return errors.New("no hashes")
+	}
+	return nil
+}
+
+func (m *Bodies) Validate() error {
+	return nil
+}
+
+func (m *Proposal) Validate() error {
+	if len(m.Block) == 0 || m.View < 0 {
+		return errors.New("missing block or negative view")
+	}
+	return nil
+}
+
+func (m *Vote) Validate() error {
+	if len(m.Sign) == 0 || m.Height < 0 || m.View < 0 {
+		return errors.New("missing signature or negative height or view")
+	}
+	return nil
+}
+
+func (m *Challenge) Validate() error {
+	if len(m.Hash) == 0 || len(m.Commitment) == 0 || len(m.Signers) == 0 {
+		return errors.New("missing hash, commitment or signers")
+	}
+	return nil
+}
+
+func (m *Response) Validate() error {
+	if len(m.Response) == 0 {
+		return errors.New("missing response")
+	}
+	return nil
+}
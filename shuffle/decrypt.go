@@ -0,0 +1,164 @@
+package shuffle
+
+import (
+	"bytes"
+	"errors"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// Values shuffled with the keys are ElGamal ciphertexts (C1, C2) = (r·B, M + r·Q) under the sum Q
+// of the keys of the OAs. Every OA removes its share x of Q with C2 - x·C1 and proves that it used
+// the x of its key h = x·B. One Chaum-Pedersen proof covers all ciphertexts: with random weights
+// e_i drawn after the statement is fixed, log_B(h) = log_{Σe_i·C1_i}(Σe_i·(C2_i - C2bar_i)).
+// A wrong element makes the combination fail unless the weights hit one value out of 2^124.  //部分解密及其证明
+
+// P step 1: digest of the statement
+type dec1 struct {
+	Digest []byte
+}
+
+// V step 2: seed of the weights
+type dec2 struct {
+	Seed []byte
+}
+
+// P step 3: commitments
+type dec3 struct {
+	T1, T2 kyber.Point
+}
+
+// V step 4: random challenge
+type dec4 struct {
+	C kyber.Scalar
+}
+
+// P step 5: response
+type dec5 struct {
+	R kyber.Scalar
+}
+
+// Decrypt removes the share x from the ciphertexts (C1, C2), C2bar[i] = C2[i] - x·C1[i], and
+// returns a prover that x is the key of x·B. The combinations are computed by workers goroutines.
+func Decrypt(suite Suite, x kyber.Scalar, C1, C2 []kyber.Point, workers int) (C2bar []kyber.Point, P proof.Prover) {
+	k := len(C1)
+	if k != len(C2) {
+		panic("C1,C2 vectors have inconsistent length")
+	}
+	C2bar = make([]kyber.Point, k)
+	parallel(k, chunks(k, workers), func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			C2bar[i] = suite.Point().Mul(x, C1[i])
+			C2bar[i].Sub(C2[i], C2bar[i])
+		}
+	})
+	h := suite.Point().Mul(x, nil)
+
+	P = func(ctx proof.ProverContext) error {
+		p1 := dec1{decryptDigest(suite, h, C1, C2, C2bar)}
+		if err := ctx.Put(p1); err != nil {
+			return err
+		}
+		v2 := dec2{make([]byte, suite.Hash().Size())}
+		if err := ctx.PubRand(&v2); err != nil {
+			return err
+		}
+		C := combine(suite, randomWeights(k, suite.XOF(v2.Seed)), C1, workers)
+
+		var v kyber.Scalar
+		if err := ctx.PriRand(&v); err != nil {
+			return err
+		}
+		p3 := dec3{suite.Point().Mul(v, nil), suite.Point().Mul(v, C)}
+		if err := ctx.Put(p3); err != nil {
+			return err
+		}
+		v4 := dec4{suite.Scalar()}
+		if err := ctx.PubRand(&v4); err != nil {
+			return err
+		}
+		// r = v - c·x
+		p5 := dec5{suite.Scalar().Sub(v, suite.Scalar().Mul(v4.C, x))}
+		return ctx.Put(p5)
+	}
+	return C2bar, P
+}
+
+// DecryptVerifier checks that C2bar is C2 with the share of the key h removed, see Decrypt
+func DecryptVerifier(suite Suite, h kyber.Point, C1, C2, C2bar []kyber.Point, workers int) proof.Verifier {
+	return func(ctx proof.VerifierContext) error {
+		k := len(C1)
+		if len(C2) != k || len(C2bar) != k {
+			return errors.New("invalid decryption: lists of different length")
+		}
+
+		p1 := dec1{make([]byte, suite.Hash().Size())}
+		if err := ctx.Get(&p1); err != nil {
+			return err
+		}
+		if !bytes.Equal(p1.Digest, decryptDigest(suite, h, C1, C2, C2bar)) {
+			return errors.New("invalid decryption proof: it proves another statement")
+		}
+		v2 := dec2{make([]byte, suite.Hash().Size())}
+		if err := ctx.PubRand(&v2); err != nil {
+			return err
+		}
+		weights := randomWeights(k, suite.XOF(v2.Seed))
+		D := make([]kyber.Point, k)
+		parallel(k, chunks(k, workers), func(_, lo, hi int) {
+			for i := lo; i < hi; i++ {
+				D[i] = suite.Point().Sub(C2[i], C2bar[i])
+			}
+		})
+		C := combine(suite, weights, C1, workers)
+		Dc := combine(suite, weights, D, workers)
+
+		p3 := dec3{suite.Point(), suite.Point()}
+		if err := ctx.Get(&p3); err != nil {
+			return err
+		}
+		v4 := dec4{suite.Scalar()}
+		if err := ctx.PubRand(&v4); err != nil {
+			return err
+		}
+		p5 := dec5{suite.Scalar()}
+		if err := ctx.Get(&p5); err != nil {
+			return err
+		}
+
+		// r·B + c·h = T1 and r·C + c·Dc = T2
+		if !dleqHolds(suite, p5.R, v4.C, suite.Point().Base(), suite.Point().Null(), h, p3.T1) ||
+			!dleqHolds(suite, p5.R, v4.C, C, suite.Point().Null(), Dc, p3.T2) {
+			return errors.New("invalid decryption proof")
+		}
+		return nil
+	}
+}
+
+// combine is Σ weights[i]·points[i], one multi-scalar multiplication per chunk
+func combine(grp kyber.Group, weights []weight, points []kyber.Point, workers int) kyber.Point {
+	k := len(points)
+	n := chunks(k, workers)
+	sums := make([]kyber.Point, n)
+	parallel(k, n, func(chunk, lo, hi int) {
+		sums[chunk] = msm(grp, weights[lo:hi], points[lo:hi])
+	})
+	sum := grp.Point().Null()
+	for _, s := range sums {
+		sum.Add(sum, s)
+	}
+	return sum
+}
+
+// decryptDigest is the hash of the key and the lists of a decryption
+func decryptDigest(suite Suite, h kyber.Point, lists ...[]kyber.Point) []byte {
+	H := suite.Hash()
+	h.MarshalTo(H)
+	for _, list := range lists {
+		for _, P := range list {
+			P.MarshalTo(H)
+		}
+	}
+	return H.Sum(nil)
+}
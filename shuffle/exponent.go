@@ -0,0 +1,103 @@
+package shuffle
+
+import (
+	"bytes"
+	"errors"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// Every OA raises the keys of a list to its round key r on the forward shuffle and removes r again on
+// the next reverse shuffle, g of the round is raised with them. One Chaum-Pedersen proof shows that
+// every key was raised to the r of gbar = r·g: with random weights e_i drawn after the statement is
+// fixed, log_g(gbar) = log_{Σe_i·X_i}(Σe_i·Xbar_i). A key that is not r·X_i makes the combination fail
+// unless the weights hit one value out of 2^124, so no OA can swap a pseudonym for another.  //轮密钥指数证明
+
+// ExponentProver proves that Xbar[i] = r·X[i] for every i, with the r of r·g. The combinations are
+// computed by workers goroutines.
+func ExponentProver(suite Suite, r kyber.Scalar, g kyber.Point, X, Xbar []kyber.Point, workers int) proof.Prover {
+	k := len(X)
+	if k != len(Xbar) {
+		panic("X,Xbar vectors have inconsistent length")
+	}
+	gbar := suite.Point().Mul(r, g)
+
+	return func(ctx proof.ProverContext) error {
+		p1 := dec1{exponentDigest(suite, g, gbar, X, Xbar)}
+		if err := ctx.Put(p1); err != nil {
+			return err
+		}
+		v2 := dec2{make([]byte, suite.Hash().Size())}
+		if err := ctx.PubRand(&v2); err != nil {
+			return err
+		}
+		C := combine(suite, randomWeights(k, suite.XOF(v2.Seed)), X, workers)
+
+		var v kyber.Scalar
+		if err := ctx.PriRand(&v); err != nil {
+			return err
+		}
+		p3 := dec3{suite.Point().Mul(v, g), suite.Point().Mul(v, C)}
+		if err := ctx.Put(p3); err != nil {
+			return err
+		}
+		v4 := dec4{suite.Scalar()}
+		if err := ctx.PubRand(&v4); err != nil {
+			return err
+		}
+		// s = v - c·r
+		p5 := dec5{suite.Scalar().Sub(v, suite.Scalar().Mul(v4.C, r))}
+		return ctx.Put(p5)
+	}
+}
+
+// ExponentVerifier checks that Xbar[i] = r·X[i] for every i with the r of gbar = r·g, see ExponentProver
+func ExponentVerifier(suite Suite, g, gbar kyber.Point, X, Xbar []kyber.Point, workers int) proof.Verifier {
+	return func(ctx proof.VerifierContext) error {
+		k := len(X)
+		if len(Xbar) != k {
+			return errors.New("invalid exponentiation: lists of different length")
+		}
+
+		p1 := dec1{make([]byte, suite.Hash().Size())}
+		if err := ctx.Get(&p1); err != nil {
+			return err
+		}
+		if !bytes.Equal(p1.Digest, exponentDigest(suite, g, gbar, X, Xbar)) {
+			return errors.New("invalid exponentiation proof: it proves another statement")
+		}
+		v2 := dec2{make([]byte, suite.Hash().Size())}
+		if err := ctx.PubRand(&v2); err != nil {
+			return err
+		}
+		weights := randomWeights(k, suite.XOF(v2.Seed))
+		C := combine(suite, weights, X, workers)
+		Cbar := combine(suite, weights, Xbar, workers)
+
+		p3 := dec3{suite.Point(), suite.Point()}
+		if err := ctx.Get(&p3); err != nil {
+			return err
+		}
+		v4 := dec4{suite.Scalar()}
+		if err := ctx.PubRand(&v4); err != nil {
+			return err
+		}
+		p5 := dec5{suite.Scalar()}
+		if err := ctx.Get(&p5); err != nil {
+			return err
+		}
+
+		// s·g + c·gbar = T1 and s·C + c·Cbar = T2
+		if !dleqHolds(suite, p5.R, v4.C, g, suite.Point().Null(), gbar, p3.T1) ||
+			!dleqHolds(suite, p5.R, v4.C, C, suite.Point().Null(), Cbar, p3.T2) {
+			return errors.New("invalid exponentiation proof")
+		}
+		return nil
+	}
+}
+
+// exponentDigest is the hash of g, gbar and the lists of an exponentiation
+func exponentDigest(suite Suite, g, gbar kyber.Point, lists ...[]kyber.Point) []byte {
+	return decryptDigest(suite, g, append([][]kyber.Point{{gbar}}, lists...)...)
+}
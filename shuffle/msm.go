@@ -0,0 +1,95 @@
+package shuffle
+
+import (
+	"crypto/cipher"
+	"math/bits"
+
+	"go.dedis.ch/kyber/v4"
+)
+
+// A batched check replaces k equations P_i = Q_i by one, Σ e_i·P_i = Σ e_i·Q_i with weights e_i
+// the prover can not predict. A wrong equation makes the sums differ unless the weights hit one
+// value out of 2^124. The sums are multi-scalar multiplications with short weights, the bucket
+// method computes them with additions instead of one scalar multiplication per point.  //随机线性组合批量验证
+
+// weightBits is the length of a weight
+const weightBits = 124
+
+// weight is the random weight hi·2^62 + lo
+type weight struct {
+	hi, lo uint64
+}
+
+// randomWeights picks k weights from rand
+func randomWeights(k int, rand cipher.Stream) []weight {
+	weights := make([]weight, k)
+	for i := range weights {
+		weights[i] = weight{randUint64(rand) >> 2, randUint64(rand) >> 2}
+	}
+	return weights
+}
+
+// scalar is the weight as a scalar of grp
+func (w weight) scalar(grp kyber.Group) kyber.Scalar {
+	s := grp.Scalar().SetInt64(int64(w.hi))
+	s.Mul(s, grp.Scalar().SetInt64(1<<62))
+	return s.Add(s, grp.Scalar().SetInt64(int64(w.lo)))
+}
+
+// digit is the c bits of the weight from offset up
+func (w weight) digit(offset, c uint) uint64 {
+	//the weight in two words, low and high
+	low, high := w.lo|w.hi<<62, w.hi>>2
+	var d uint64
+	if offset < 64 {
+		d = low>>offset | high<<(64-offset)
+	} else {
+		d = high >> (offset - 64)
+	}
+	return d & (1<<c - 1)
+}
+
+// msm returns Σ weights[i]·points[i]. Every window of c bits of the weights sorts the points into
+// buckets by their digit, the sum of bucket d counts d times.
+func msm(grp kyber.Group, weights []weight, points []kyber.Point) kyber.Point {
+	if len(points) == 0 {
+		return grp.Point().Null()
+	}
+	c := uint(bits.Len(uint(len(points))))
+	if c > 2 {
+		c -= 2
+	}
+	if c > 12 {
+		c = 12
+	}
+	result := grp.Point().Null()
+	buckets := make([]kyber.Point, 1<<c-1)
+	for offset := int((weightBits+c-1)/c*c - c); offset >= 0; offset -= int(c) {
+		for j := uint(0); j < c; j++ {
+			result.Add(result, result)
+		}
+		for b := range buckets {
+			buckets[b] = nil
+		}
+		for i, P := range points {
+			if d := weights[i].digit(uint(offset), c); d != 0 {
+				if buckets[d-1] == nil {
+					buckets[d-1] = grp.Point().Set(P)
+				} else {
+					buckets[d-1].Add(buckets[d-1], P)
+				}
+			}
+		}
+		//running sums from the highest bucket: bucket d is added d times
+		sum := grp.Point().Null()
+		acc := grp.Point().Null()
+		for b := len(buckets) - 1; b >= 0; b-- {
+			if buckets[b] != nil {
+				sum.Add(sum, buckets[b])
+			}
+			acc.Add(acc, sum)
+		}
+		result.Add(result, acc)
+	}
+	return result
+}
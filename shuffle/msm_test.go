@@ -0,0 +1,165 @@
+package shuffle
+
+import (
+	"math/rand"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+const maxWeightWord = 1<<62 - 1
+
+// naiveSum is Σ weights[i]·points[i] with one scalar multiplication per point
+func naiveSum(grp kyber.Group, weights []weight, points []kyber.Point) kyber.Point {
+	sum := grp.Point().Null()
+	for i, P := range points {
+		sum.Add(sum, grp.Point().Mul(weights[i].scalar(grp), P))
+	}
+	return sum
+}
+
+func TestMSM(t *testing.T) {
+	suite := testSuite("msm")
+	edges := []weight{{0, 0}, {0, 1}, {1, 0}, {0, maxWeightWord}, {maxWeightWord, 0}, {maxWeightWord, maxWeightWord}}
+	rand := suite.RandomStream()
+	for _, k := range []int{0, 1, 2, 3, 5, 17, 100, 300} {
+		points := make([]kyber.Point, k)
+		for i := range points {
+			points[i] = suite.Point().Pick(rand)
+		}
+		cases := map[string][]weight{"random": randomWeights(k, rand)}
+		for _, e := range edges {
+			same := make([]weight, k)
+			for i := range same {
+				same[i] = e
+			}
+			if got, want := msm(suite, same, points), naiveSum(suite, same, points); !got.Equal(want) {
+				t.Fatalf("k %d: msm with every weight %v differs from the naive sum", k, e)
+			}
+		}
+		mixed := make([]weight, k)
+		for i := range mixed {
+			mixed[i] = edges[i%len(edges)]
+		}
+		cases["mixed"] = mixed
+		for name, weights := range cases {
+			if got, want := msm(suite, weights, points), naiveSum(suite, weights, points); !got.Equal(want) {
+				t.Fatalf("k %d: msm with %s weights differs from the naive sum", k, name)
+			}
+		}
+	}
+}
+
+// the same point many times and the neutral element land in the same buckets
+func TestMSMRepeatedPoints(t *testing.T) {
+	suite := testSuite("msm")
+	P := suite.Point().Pick(suite.RandomStream())
+	points := []kyber.Point{P, P, suite.Point().Null(), P, suite.Point().Neg(P)}
+	weights := randomWeights(len(points), suite.RandomStream())
+	if !msm(suite, weights, points).Equal(naiveSum(suite, weights, points)) {
+		t.Fatal("msm differs from the naive sum")
+	}
+}
+
+func TestWeightDigits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		w := weight{r.Uint64() >> 2, r.Uint64() >> 2}
+		for _, c := range []uint{1, 3, 7, 12} {
+			//the digits put back together are the weight
+			var lo, hi uint64
+			for offset := uint(0); offset < weightBits; offset += c {
+				d := w.digit(offset, c)
+				if offset < 64 {
+					lo |= d << offset
+				}
+				if offset+c > 64 {
+					if offset >= 64 {
+						hi |= d << (offset - 64)
+					} else {
+						hi |= d >> (64 - offset)
+					}
+				}
+			}
+			if lo != w.lo|w.hi<<62 || hi&(1<<(weightBits-64)-1) != w.hi>>2 {
+				t.Fatalf("the %d bit digits of %v do not add up to it", c, w)
+			}
+		}
+	}
+}
+
+// verified returns the state of a PairShuffle that verified a proof of k pairs
+func verified(t *testing.T, k, workers int) *PairShuffle {
+	suite := testSuite("batch")
+	h, X, Y := testPairs(suite, k)
+	Xbar, Ybar, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), workers)
+	prf, err := proof.HashProve(suite, "PairShuffle", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &PairShuffle{}
+	ps.Init(suite, k).SetWorkers(workers).SetColumns(nil, nil, nil)
+	verifier := func(ctx proof.VerifierContext) error { return ps.Verify(nil, h, X, Y, Xbar, Ybar, ctx) }
+	if err := proof.HashVerify(suite, "PairShuffle", verifier, prf); err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+func TestBatch33(t *testing.T) {
+	for _, k := range []int{2, 5, 40} {
+		for _, n := range []int{1, 3} {
+			ps := verified(t, k, n)
+			if i := ps.check33(chunks(k, n)); i >= 0 {
+				t.Fatalf("k %d: (33) does not hold for pair %d of a valid proof", k, i)
+			}
+			if !ps.batch33(chunks(k, n)) {
+				t.Fatalf("k %d: the batched (33) does not hold for a valid proof", k)
+			}
+
+			//a wrong response of one pair is found by both checks
+			for _, i := range []int{0, k / 2, k - 1} {
+				saved := ps.p5.Zsigma[i]
+				ps.p5.Zsigma[i] = ps.grp.Scalar().Add(saved, ps.grp.Scalar().One())
+				if got := ps.check33(chunks(k, n)); got != i {
+					t.Fatalf("k %d: check33 reports pair %d for a wrong pair %d", k, got, i)
+				}
+				if ps.batch33(chunks(k, n)) {
+					t.Fatalf("k %d: the batched (33) holds with a wrong pair %d", k, i)
+				}
+				ps.p5.Zsigma[i] = saved
+			}
+		}
+	}
+}
+
+// a proof with one byte changed is rejected pair by pair and batched
+func TestTamperedProof(t *testing.T) {
+	suite := testSuite("tamper")
+	k := 6
+	h, X, Y := testPairs(suite, k)
+	Xbar, Ybar, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), 0)
+	prf, err := proof.HashProve(suite, "PairShuffle", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range []int{0, len(prf) / 3, len(prf) / 2, len(prf) - 1} {
+		tampered := append([]byte{}, prf...)
+		tampered[at] ^= 1
+		for _, batch := range []bool{false, true} {
+			verifier := Verifier(suite, nil, h, X, Y, Xbar, Ybar, 0, batch)
+			if proof.HashVerify(suite, "PairShuffle", verifier, tampered) == nil {
+				t.Fatalf("batch %v: the proof with byte %d changed verifies", batch, at)
+			}
+		}
+	}
+	//the valid proof does not verify other outputs
+	Ybar[0], Ybar[1] = Ybar[1], Ybar[0]
+	for _, batch := range []bool{false, true} {
+		verifier := Verifier(suite, nil, h, X, Y, Xbar, Ybar, 0, batch)
+		if proof.HashVerify(suite, "PairShuffle", verifier, prf) == nil {
+			t.Fatalf("batch %v: the proof verifies outputs in another order", batch)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package shuffle
+
+import (
+	"runtime"
+	"sync"
+)
+
+// The steps of the proofs that compute one commitment or check one equation per element are
+// split into contiguous chunks, one per worker. Sums over the elements are added up per chunk and
+// then in the order of the chunks, the group operations commute, so the proof does not depend on
+// the number of workers.  //逐元素步骤按块分给工作协程，结果与顺序执行相同
+
+// chunks is the number of chunks k elements are split into, workers <= 0 means one per CPU
+func chunks(k, workers int) int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > k {
+		workers = k
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// parallel runs f on the n chunks of [0,k) and waits for all of them, a single chunk runs
+// on the calling goroutine
+func parallel(k, n int, f func(chunk, lo, hi int)) {
+	if n <= 1 {
+		f(0, 0, k)
+		return
+	}
+	var wg sync.WaitGroup
+	for chunk := 0; chunk < n; chunk++ {
+		wg.Add(1)
+		go func(chunk int) {
+			defer wg.Done()
+			f(chunk, chunk*k/n, (chunk+1)*k/n)
+		}(chunk)
+	}
+	wg.Wait()
+}
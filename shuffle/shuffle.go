@@ -23,17 +23,18 @@ package shuffle
 //这个 .go 文件 属于名字为 shuffle 的包（module 内的包）。在同一包里的其它文件可以互相访问包内未导出的标识符（小写名），外部包只能访问导出的标识符（以大写字母开头的类型、函数、变量等）。
 
 import (    //这里用了一个“分组导入”语法，把多个包一次性列出。
+	"bytes"
+	"crypto/sha256"
 	"crypto/cipher"    //Go 标准库里的包，提供对称加密相关接口和类型。这里最重要的是 cipher.Stream 接口（流密码抽象），在本文件其他地方用来传入“伪随机流
 	"encoding/binary"  //提供二进制数据的编码/解码工具，常用于把 []byte 转成整数或反之。
 	"errors"           //Go 的标准错误构造包，常用 errors.New("...") 来创建一个 error 对象（Go 的错误类型）
+	"fmt"
 
 	//整个文件的核心依赖——kyber 是 DEDIS 团队（瑞士洛桑）开发的一个通用加密抽象库（Go 语言），提供群（group）、点（point）、标量（scalar）、零知识证明抽象等。
 	//你会看到 kyber.Point、kyber.Scalar、kyber.Group 等类型被大量使用。kyber 抽象了底层的椭圆曲线或群运算，使得上层算法与具体曲线实现解耦（可以换不同 Suite）
 	"go.dedis.ch/kyber/v4"   
 	"go.dedis.ch/kyber/v4/proof"                 //零知识证明的库  //这是 kyber 的“证明”抽象包。提供 ProverContext、VerifierContext、Prover / Verifier 类型和 Put、Get、PubRand、PriRand 等方法。
 												 //ps.Prove / ps.Verify 都依赖 proof 包的上下文对象来进行 P/V 交互或非交互式转换
-	sh "go.dedis.ch/kyber/v4/shuffle"            //给 go.dedis.ch/kyber/v4/shuffle 包起了别名 sh。在文件后面使用 sh.SimpleShuffle
-	                                             //这个子包实现了 Neff 的 SimpleShuffle（也就是更简单的 k-shuffle 证明）。PairShuffle 在最后一步会调用 sh.SimpleShuffle 的 Prove / Verify。
 	"go.dedis.ch/kyber/v4/util/random"           //提供一些工具函数来从 cipher.Stream 或其它来源生成随机位/字节
 )
 
@@ -55,9 +56,12 @@ type Suite proof.Suite
 //这里的 ega1..ega6 就是把每一步要发送/接收的“承诺、挑战、响应”等数据封装成结构体，以便通过 ctx.Put / ctx.Get 在 Prove 与 Verify 之间传递或在 transcript 中吸收/输出。
 // P (Prover) step 1: public commitments    //公共承诺  证明者的第1步公开承诺
 type ega1 struct {
+	//hash of the statement, g, h, the pairs and the columns before and after, see statementDigest
+	Digest           []byte
 	Gamma            kyber.Point     //Gamma：单个群元素点，等同于 g^gamma（即把私人标量 gamma 映射成点）。在后续方程里 Gamma 常用作一个“公共基点”来结合其它响应（例如 p5 中的 Zsigma 用来乘 Gamma）。
 	A, C, U, W       []kyber.Point   //A[i]：一组点承诺，通常是 A[i] = a[i] * g（其中 a[i] 是 Prover 随机选的标量）。也就是把隐藏标量 a[i] 的承诺暴露成点 A[i]。其他类似
 	Lambda1, Lambda2 kyber.Point     //两个点（聚合承诺），它们是把若干项加权相加后的公共承诺，用在后面的等式校验（在 Verify 中会用到，见代码对 Phi1/Phi2 的比较）。
+	Lambdas          []kyber.Point   //Lambda of every further column, see SetColumns  //附加列的聚合承诺
 }  //A,C,U,W 都是长度 k 的数组（k 为洗牌的对数）。实现上必须保证长度一致。
 
 // V (Verifier) step 2: random challenge t
@@ -79,11 +83,13 @@ type ega4 struct {
 type ega5 struct {
 	Zsigma []kyber.Scalar
 	Ztau   kyber.Scalar
+	//Ztau of the factors of the further columns
+	Zdelta kyber.Scalar
 }
 
 // P and V, step 5: simple k-shuffle proof
 type ega6 struct {
-	sh.SimpleShuffle
+	SimpleShuffle
 }
 //嵌入了另一个结构体
 
@@ -104,12 +110,19 @@ type ega6 struct {
 type PairShuffle struct {
 	grp kyber.Group
 	k   int
-	p1  ega1
-	v2  ega2
-	p3  ega3
-	v4  ega4
-	p5  ega5
-	pv6 sh.SimpleShuffle
+	//goroutines the steps per element are split over, 0 for one per CPU
+	workers int
+	//Verify checks (33) for all pairs at once
+	batch bool
+	//further columns shuffled with the pairs and their bases, see SetColumns
+	bases         []kyber.Point
+	cols, colsbar [][]kyber.Point
+	p1      ega1
+	v2      ega2
+	p3      ega3
+	v4      ega4
+	p5      ega5
+	pv6     SimpleShuffle
 }
 
 // Init creates a new PairShuffleProof instance for a k-element ElGamal pair shuffle.
@@ -128,6 +141,7 @@ func (ps *PairShuffle) Init(grp kyber.Group, k int) *PairShuffle {
 	ps.grp = grp
 	ps.k = k
 	
+	ps.p1.Digest = make([]byte, sha256.Size)
 	ps.p1.A = make([]kyber.Point, k)
 	ps.p1.C = make([]kyber.Point, k)
 	ps.p1.U = make([]kyber.Point, k)
@@ -140,10 +154,38 @@ func (ps *PairShuffle) Init(grp kyber.Group, k int) *PairShuffle {
 	return ps
 }
 
-// Prove returns an error if the shuffle is not correct.
+// SetWorkers splits the steps per element of Prove and Verify over n goroutines, n <= 0 uses
+// one per CPU. The proof is the same for every n.
+func (ps *PairShuffle) SetWorkers(n int) *PairShuffle {
+	ps.workers = n
+	ps.pv6.workers = n
+	return ps
+}
+
+// SetBatch makes Verify check equation (33) for all pairs with one random linear combination
+// instead of one by one, a combination that does not hold is checked pair by pair to find the
+// pair that fails.
+func (ps *PairShuffle) SetBatch(batch bool) *PairShuffle {
+	ps.batch = batch
+	return ps
+}
+
+// SetColumns adds further columns to the shuffle. Every column is permuted like the pairs and
+// re-randomized with factors delta of its own times its base, colsbar[c][i] = cols[c][pi[i]] + delta[pi[i]]·bases[c],
+// so the proof shows that the columns were carried along with the pairs. The columns share delta, so
+// two columns with the bases B and h are an ElGamal ciphertext, but delta is independent of the beta
+// of the pairs, an output column can not be matched to its input with the output pair.
+func (ps *PairShuffle) SetColumns(bases []kyber.Point, cols, colsbar [][]kyber.Point) *PairShuffle {
+	ps.bases, ps.cols, ps.colsbar = bases, cols, colsbar
+	ps.p1.Lambdas = make([]kyber.Point, len(bases))
+	return ps
+}
+
+// Prove returns an error if the shuffle is not correct. Xbar and Ybar are the shuffled pairs,
+// hashed with the rest of the statement into the proof, delta are the factors of the columns.
 func (ps *PairShuffle) Prove(
-	pi []int, g, h kyber.Point, beta []kyber.Scalar,
-	X, Y []kyber.Point, rand cipher.Stream,
+	pi []int, g, h kyber.Point, beta, delta []kyber.Scalar,
+	X, Y, Xbar, Ybar []kyber.Point, rand cipher.Stream,
 	ctx proof.ProverContext) error {
 	/*pi：一个整数数组，表示洗牌的排列。
 	g, h：ElGamal 密钥生成的基点。
@@ -155,7 +197,7 @@ func (ps *PairShuffle) Prove(
 
 	grp := ps.grp
 	k := ps.k
-	if k != len(pi) || k != len(beta) {
+	if k != len(pi) || k != len(beta) || k != len(delta) {
 		panic("mismatched vector lengths")
 	}
 
@@ -173,28 +215,70 @@ func (ps *PairShuffle) Prove(
 	u := make([]kyber.Scalar, k)
 	w := make([]kyber.Scalar, k)
 	a := make([]kyber.Scalar, k)
-	var tau0, nu, gamma kyber.Scalar
-	ctx.PriRand(u, w, a, &tau0, &nu, &gamma)           // PriRand 方法是用来生成随机数并在证明过程中使用。
+	var tau0, nu, gamma, delta0 kyber.Scalar
+	ctx.PriRand(u, w, a, &tau0, &nu, &gamma, &delta0)           // PriRand 方法是用来生成随机数并在证明过程中使用。
 
-	// compute public commits
+	// compute public commits, every chunk sums its part of wbetasum, wdeltasum, Lambda1 and Lambda2
+	p1.Digest = statementDigest(grp, g, h, X, Y, Xbar, Ybar, ps.bases, ps.cols, ps.colsbar)
 	p1.Gamma = grp.Point().Mul(gamma, g)
-	wbeta := grp.Scalar() // scratch
+	n := chunks(k, ps.workers)
+	m := len(ps.bases)
+	wbetasums := make([]kyber.Scalar, n)
+	wdeltasums := make([]kyber.Scalar, n)
+	lambda1s := make([]kyber.Point, n)
+	lambda2s := make([]kyber.Point, n)
+	lambdass := make([][]kyber.Point, n)
+	parallel(k, n, func(chunk, lo, hi int) {
+		z := grp.Scalar()     // scratch
+		wbeta := grp.Scalar() // scratch
+		XY := grp.Point()     // scratch
+		wu := grp.Scalar()    // scratch
+		wbetasum := grp.Scalar().Zero()
+		wdeltasum := grp.Scalar().Zero()
+		Lambda1 := grp.Point().Null()
+		Lambda2 := grp.Point().Null()
+		Lambdas := make([]kyber.Point, m)
+		for c := range Lambdas {
+			Lambdas[c] = grp.Point().Null()
+		}
+		for i := lo; i < hi; i++ {
+			p1.A[i] = grp.Point().Mul(a[i], g)
+			p1.C[i] = grp.Point().Mul(z.Mul(gamma, a[pi[i]]), g)
+			p1.U[i] = grp.Point().Mul(u[i], g)
+			p1.W[i] = grp.Point().Mul(z.Mul(gamma, w[i]), g)
+			wbetasum.Add(wbetasum, wbeta.Mul(w[i], beta[pi[i]]))
+			wdeltasum.Add(wdeltasum, wbeta.Mul(w[i], delta[pi[i]]))
+			Lambda1.Add(Lambda1, XY.Mul(wu.Sub(w[piinv[i]], u[i]), X[i]))
+			Lambda2.Add(Lambda2, XY.Mul(wu.Sub(w[piinv[i]], u[i]), Y[i]))
+			for c := range Lambdas {
+				Lambdas[c].Add(Lambdas[c], XY.Mul(wu, ps.cols[c][i]))
+			}
+		}
+		wbetasums[chunk], wdeltasums[chunk] = wbetasum, wdeltasum
+		lambda1s[chunk], lambda2s[chunk], lambdass[chunk] = Lambda1, Lambda2, Lambdas
+	})
 	wbetasum := grp.Scalar().Set(tau0)
+	wdeltasum := grp.Scalar().Set(delta0)
 	p1.Lambda1 = grp.Point().Null()
 	p1.Lambda2 = grp.Point().Null()
-	XY := grp.Point()  // scratch
-	wu := grp.Scalar() // scratch
-	for i := 0; i < k; i++ {
-		p1.A[i] = grp.Point().Mul(a[i], g)
-		p1.C[i] = grp.Point().Mul(z.Mul(gamma, a[pi[i]]), g)
-		p1.U[i] = grp.Point().Mul(u[i], g)
-		p1.W[i] = grp.Point().Mul(z.Mul(gamma, w[i]), g)
-		wbetasum.Add(wbetasum, wbeta.Mul(w[i], beta[pi[i]]))
-		p1.Lambda1.Add(p1.Lambda1, XY.Mul(wu.Sub(w[piinv[i]], u[i]), X[i]))
-		p1.Lambda2.Add(p1.Lambda2, XY.Mul(wu.Sub(w[piinv[i]], u[i]), Y[i]))
+	for c := range p1.Lambdas {
+		p1.Lambdas[c] = grp.Point().Null()
+	}
+	for chunk := 0; chunk < n; chunk++ {
+		wbetasum.Add(wbetasum, wbetasums[chunk])
+		wdeltasum.Add(wdeltasum, wdeltasums[chunk])
+		p1.Lambda1.Add(p1.Lambda1, lambda1s[chunk])
+		p1.Lambda2.Add(p1.Lambda2, lambda2s[chunk])
+		for c := range p1.Lambdas {
+			p1.Lambdas[c].Add(p1.Lambdas[c], lambdass[chunk][c])
+		}
 	}
+	XY := grp.Point() // scratch
 	p1.Lambda1.Add(p1.Lambda1, XY.Mul(wbetasum, g))
 	p1.Lambda2.Add(p1.Lambda2, XY.Mul(wbetasum, h))
+	for c := range p1.Lambdas {
+		p1.Lambdas[c].Add(p1.Lambdas[c], XY.Mul(wdeltasum, ps.bases[c]))
+	}
 	if err := ctx.Put(p1); err != nil {
 		//put函数用于将数据存储到一个零知识证明的上下文中.
 		return err
@@ -206,10 +290,12 @@ func (ps *PairShuffle) Prove(
 		return err
 	}
 	B := make([]kyber.Point, k)
-	for i := 0; i < k; i++ {
-		P := grp.Point().Mul(v2.Zrho[i], g)
-		B[i] = P.Sub(P, p1.U[i])
-	}
+	parallel(k, n, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			P := grp.Point().Mul(v2.Zrho[i], g)
+			B[i] = P.Sub(P, p1.U[i])
+		}
+	})
 
 	// P step 3
 	p3 := &ps.p3
@@ -218,10 +304,12 @@ func (ps *PairShuffle) Prove(
 		b[i] = grp.Scalar().Sub(v2.Zrho[i], u[i])
 	}
 	d := make([]kyber.Scalar, k)
-	for i := 0; i < k; i++ {
-		d[i] = grp.Scalar().Mul(gamma, b[pi[i]])
-		p3.D[i] = grp.Point().Mul(d[i], g)
-	}
+	parallel(k, n, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			d[i] = grp.Scalar().Mul(gamma, b[pi[i]])
+			p3.D[i] = grp.Point().Mul(d[i], g)
+		}
+	})
 	if err := ctx.Put(p3); err != nil {
 		return err
 	}
@@ -243,9 +331,11 @@ func (ps *PairShuffle) Prove(
 		s[i] = grp.Scalar().Mul(gamma, r[pi[i]])
 	}
 	p5.Ztau = grp.Scalar().Neg(tau0)
+	p5.Zdelta = grp.Scalar().Neg(delta0)
 	for i := 0; i < k; i++ {
 		p5.Zsigma[i] = grp.Scalar().Add(w[i], b[pi[i]])
 		p5.Ztau.Add(p5.Ztau, z.Mul(b[i], beta[i]))
+		p5.Zdelta.Add(p5.Zdelta, z.Mul(b[i], delta[i]))
 	}
 	if err := ctx.Put(p5); err != nil {
 		return err
@@ -266,23 +356,38 @@ func (ps *PairShuffle) Verify(
 	if len(X) != k || len(Y) != k || len(Xbar) != k || len(Ybar) != k {
 		panic("mismatched vector lengths")
 	}
+	m := len(ps.bases)
+	if len(ps.cols) != m || len(ps.colsbar) != m {
+		return errors.New("invalid PairShuffleProof: a column or its base is missing")
+	}
+	for c := 0; c < m; c++ {
+		if len(ps.cols[c]) != k || len(ps.colsbar[c]) != k {
+			return fmt.Errorf("invalid PairShuffleProof: column %d has not %d elements", c, k)
+		}
+	}
 
 	// P step 1
 	p1 := &ps.p1
 	if err := ctx.Get(p1); err != nil {
 		return err
 	}
+	if !bytes.Equal(p1.Digest, statementDigest(grp, g, h, X, Y, Xbar, Ybar, ps.bases, ps.cols, ps.colsbar)) {
+		return errors.New("invalid PairShuffleProof: it proves another statement")
+	}
 
 	// V step 2
 	v2 := &ps.v2
 	if err := ctx.PubRand(v2); err != nil {
 		return err
 	}
+	n := chunks(k, ps.workers)
 	B := make([]kyber.Point, k)
-	for i := 0; i < k; i++ {
-		P := grp.Point().Mul(v2.Zrho[i], g)
-		B[i] = P.Sub(P, p1.U[i])
-	}
+	parallel(k, n, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			P := grp.Point().Mul(v2.Zrho[i], g)
+			B[i] = P.Sub(P, p1.U[i])
+		}
+	})
 
 	// P step 3
 	p3 := &ps.p3
@@ -308,21 +413,50 @@ func (ps *PairShuffle) Verify(
 	}
 
 	// V step 7
+	if !ps.batch || !ps.batch33(n) {
+		if i := ps.check33(n); i >= 0 {
+			return fmt.Errorf("invalid PairShuffleProof: (33) does not hold for pair %d", i)
+		}
+	}
+	//every chunk sums its part of Phi1, Phi2 and the Phi of every further column
+	phi1s := make([]kyber.Point, n)
+	phi2s := make([]kyber.Point, n)
+	phiss := make([][]kyber.Point, n)
+	parallel(k, n, func(chunk, lo, hi int) {
+		Phi1 := grp.Point().Null()
+		Phi2 := grp.Point().Null()
+		Phis := make([]kyber.Point, m)
+		for c := range Phis {
+			Phis[c] = grp.Point().Null()
+		}
+		P := grp.Point() // scratch
+		for i := lo; i < hi; i++ {
+			Phi1 = Phi1.Add(Phi1, P.Mul(p5.Zsigma[i], Xbar[i])) // (31)
+			Phi1 = Phi1.Sub(Phi1, P.Mul(v2.Zrho[i], X[i]))
+			Phi2 = Phi2.Add(Phi2, P.Mul(p5.Zsigma[i], Ybar[i])) // (32)
+			Phi2 = Phi2.Sub(Phi2, P.Mul(v2.Zrho[i], Y[i]))
+			for c := range Phis { // (32) for column c
+				Phis[c].Add(Phis[c], P.Mul(p5.Zsigma[i], ps.colsbar[c][i]))
+				Phis[c].Sub(Phis[c], P.Mul(v2.Zrho[i], ps.cols[c][i]))
+			}
+		}
+		phi1s[chunk], phi2s[chunk], phiss[chunk] = Phi1, Phi2, Phis
+	})
 	Phi1 := grp.Point().Null()
 	Phi2 := grp.Point().Null()
-	P := grp.Point() // scratch
-	Q := grp.Point() // scratch
-	for i := 0; i < k; i++ {
-		Phi1 = Phi1.Add(Phi1, P.Mul(p5.Zsigma[i], Xbar[i])) // (31)
-		Phi1 = Phi1.Sub(Phi1, P.Mul(v2.Zrho[i], X[i]))
-		Phi2 = Phi2.Add(Phi2, P.Mul(p5.Zsigma[i], Ybar[i])) // (32)
-		Phi2 = Phi2.Sub(Phi2, P.Mul(v2.Zrho[i], Y[i]))
-		//		println("i",i)
-		if !P.Mul(p5.Zsigma[i], p1.Gamma).Equal( // (33)
-			Q.Add(p1.W[i], p3.D[i])) {
-			return errors.New("invalid PairShuffleProof")
+	Phis := make([]kyber.Point, m)
+	for c := range Phis {
+		Phis[c] = grp.Point().Null()
+	}
+	for chunk := 0; chunk < n; chunk++ {
+		Phi1.Add(Phi1, phi1s[chunk])
+		Phi2.Add(Phi2, phi2s[chunk])
+		for c := range Phis {
+			Phis[c].Add(Phis[c], phiss[chunk][c])
 		}
 	}
+	P := grp.Point() // scratch
+	Q := grp.Point() // scratch
 	//	println("last")
 	//	println("Phi1",Phi1.String());
 	//	println("Phi2",Phi2.String());
@@ -332,16 +466,82 @@ func (ps *PairShuffle) Verify(
 		!P.Add(p1.Lambda2, Q.Mul(p5.Ztau, h)).Equal(Phi2) { // (35)
 		return errors.New("invalid PairShuffleProof")
 	}
+	for c := range Phis {
+		if !P.Add(p1.Lambdas[c], Q.Mul(p5.Zdelta, ps.bases[c])).Equal(Phis[c]) { // (35) for column c
+			return fmt.Errorf("invalid PairShuffleProof: column %d is not shuffled with the pairs", c)
+		}
+	}
 
 	return nil              //nil是一个预定义的常量，用来表示指针或接口类型的零值。它表示指针不指向任何有效的内存地址，或者接口不包含任何具体的值。
 }
 
+// check33 checks (33), Zsigma_i·Gamma = W_i + D_i, pair by pair in n chunks and returns the
+// first pair it does not hold for, -1 if it holds for all
+func (ps *PairShuffle) check33(n int) int {
+	grp := ps.grp
+	first := make([]int, n)
+	parallel(ps.k, n, func(chunk, lo, hi int) {
+		P := grp.Point() // scratch
+		Q := grp.Point() // scratch
+		first[chunk] = -1
+		for i := lo; i < hi; i++ {
+			if !P.Mul(ps.p5.Zsigma[i], ps.p1.Gamma).Equal(Q.Add(ps.p1.W[i], ps.p3.D[i])) {
+				first[chunk] = i
+				return
+			}
+		}
+	})
+	for _, i := range first {
+		if i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// batch33 checks (33) for all pairs at once with random weights e_i:
+// (Σ e_i·Zsigma_i)·Gamma = Σ e_i·(W_i + D_i), the right side is a multi-scalar multiplication per chunk
+func (ps *PairShuffle) batch33(n int) bool {
+	grp := ps.grp
+	weights := randomWeights(ps.k, random.New())
+	sums := make([]kyber.Scalar, n)
+	rights := make([]kyber.Point, n)
+	parallel(ps.k, n, func(chunk, lo, hi int) {
+		sum := grp.Scalar().Zero()
+		e := grp.Scalar() // scratch
+		WD := make([]kyber.Point, hi-lo)
+		for i := lo; i < hi; i++ {
+			sum.Add(sum, e.Mul(weights[i].scalar(grp), ps.p5.Zsigma[i]))
+			WD[i-lo] = grp.Point().Add(ps.p1.W[i], ps.p3.D[i])
+		}
+		sums[chunk], rights[chunk] = sum, msm(grp, weights[lo:hi], WD)
+	})
+	sum := grp.Scalar().Zero()
+	right := grp.Point().Null()
+	for chunk := 0; chunk < n; chunk++ {
+		sum.Add(sum, sums[chunk])
+		right.Add(right, rights[chunk])
+	}
+	return grp.Point().Mul(sum, ps.p1.Gamma).Equal(right)
+}
+
 // Shuffle randomly shuffles and re-randomizes a set of ElGamal pairs,
 // producing a correctness proof in the process.
 // Returns (Xbar,Ybar), the shuffled and randomized pairs.
 // If g or h is nil, the standard base point is used.
+// The proof is computed by workers goroutines, see SetWorkers.
+// Lists of 0 and 1 pairs get the proof of shuffleSmall.
 func Shuffle(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point,
-	rand cipher.Stream) (XX, YY, Ybaby []kyber.Point, P proof.Prover) {
+	rand cipher.Stream, workers int) (XX, YY, Ybaby []kyber.Point, P proof.Prover) {
+
+	XX, YY, Ybaby, _, P = ShuffleColumns(group, g, h, X, Y, nil, nil, rand, workers)
+	return
+}
+
+// ShuffleColumns is Shuffle with further columns cols shuffled along with the pairs and
+// re-randomized with bases, see SetColumns. Returns the shuffled columns too.
+func ShuffleColumns(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point, bases []kyber.Point, cols [][]kyber.Point,
+	rand cipher.Stream, workers int) (XX, YY, Ybaby []kyber.Point, colsbar [][]kyber.Point, P proof.Prover) {
 	/*Shuffle 函数接收一个密码学群 group、两个点 g 和 h（用于ElGamal加密），以及两个切片 X 和 Y 分别存储了ElGamal加密对的明文和密文（两列）部分。rand 是用于生成随机数的密码流。
 	返回值包括 XX、YY 和 Ybaby，它们分别是重排后的明文（第一列） Xbar、重排后的密文（第二列） Ybar，以及一个用于生成正确性证明的 proof.Prover 函数。
 	*/
@@ -349,10 +549,21 @@ func Shuffle(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point,
 	if k != len(Y) {
 		panic("X,Y vectors have inconsistent length")
 	}
+	if len(cols) != len(bases) {
+		panic("a column or its base is missing")
+	}
+	for _, col := range cols {
+		if len(col) != k {
+			panic("X,Y vectors and columns have inconsistent length")
+		}
+	}
+	if k <= 1 {
+		return shuffleSmall(group, g, h, X, Y, bases, cols, rand)
+	}
 
 	//初始化一个结构体实例
 	ps := PairShuffle{}
-	ps.Init(group, k)
+	ps.Init(group, k).SetWorkers(workers)
 
 	// Pick a random permutation（排列）
 	pi := make([]int, k)
@@ -374,18 +585,33 @@ func Shuffle(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point,
 	for i := 0; i < k; i++ {
 		beta[i] = ps.grp.Scalar().Pick(rand)
 	}
+	// and one for the columns of each pair, see SetColumns
+	delta := make([]kyber.Scalar, k)
+	for i := 0; i < k; i++ {
+		delta[i] = ps.grp.Scalar().Pick(rand)
+	}
 
 	// Create the output pair vectors
 	Xbar := make([]kyber.Point, k)
 	Ybar := make([]kyber.Point, k)
 	Ytmp := make([]kyber.Point, k)
-	for i := 0; i < k; i++ {           //Xbar跟Ybar都是独立运算的，Ytmp应该就是g^ei  //！！！相当于在原来的基础上，在指数上乘以e_i
-		Xbar[i] = ps.grp.Point().Mul(beta[pi[i]], g)    //Mul就是指数运算   //ps.grp.Point()生成一个空点对象，用Mul的计算结果赋值
-		Xbar[i].Add(Xbar[i], X[pi[i]])                  //Add就是乘积运算
-		Ytmp[i] = ps.grp.Point().Mul(beta[pi[i]], h)    
-		Ybar[i] = ps.grp.Point().Mul(beta[pi[i]], h)
-		Ybar[i].Add(Ybar[i], Y[pi[i]])                  //这里算的是此时的用户假名
-	}
+	colsbar = make([][]kyber.Point, len(cols))
+	for c := range colsbar {
+		colsbar[c] = make([]kyber.Point, k)
+	}
+	parallel(k, chunks(k, workers), func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {           //Xbar跟Ybar都是独立运算的，Ytmp应该就是g^ei  //！！！相当于在原来的基础上，在指数上乘以e_i
+			Xbar[i] = ps.grp.Point().Mul(beta[pi[i]], g)    //Mul就是指数运算   //ps.grp.Point()生成一个空点对象，用Mul的计算结果赋值
+			Xbar[i].Add(Xbar[i], X[pi[i]])                  //Add就是乘积运算
+			Ytmp[i] = ps.grp.Point().Mul(beta[pi[i]], h)
+			Ybar[i] = ps.grp.Point().Mul(beta[pi[i]], h)
+			Ybar[i].Add(Ybar[i], Y[pi[i]])                  //这里算的是此时的用户假名
+			for c := range colsbar {
+				colsbar[c][i] = ps.grp.Point().Mul(delta[pi[i]], bases[c])
+				colsbar[c][i].Add(colsbar[c][i], cols[c][pi[i]])
+			}
+		}
+	})
 	/*创建一个长度为 k 的 beta 数组，每个元素是一个随机的密码学标量。然后对每个加密对 (X[i], Y[i]) 进行ElGamal再随机化：
 	计算 Xbar[i] = beta[pi[i]] * g + X[pi[i]]      (g^(x*ei))   
 	计算 Ybar[i] = beta[pi[i]] * h + Y[pi[i]]
@@ -393,10 +619,29 @@ func Shuffle(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point,
 	*/
 
 	//这里不直接生成证明材料，外部框架调用它时，再把 pi, g, h, beta, X, Y 这些秘密/中间量带入，按协议和挑战生成零知识
+	ps.SetColumns(bases, cols, colsbar)
 	prover := func(ctx proof.ProverContext) error {
-		return ps.Prove(pi, g, h, beta, X, Y, rand, ctx)
+		return ps.Prove(pi, g, h, beta, delta, X, Y, Xbar, Ybar, rand, ctx)
 	}
-	return Xbar, Ybar, Ytmp, prover
+	return Xbar, Ybar, Ytmp, colsbar, prover
+}
+
+// statementDigest is the hash of everything a shuffle proof is about, nil g and h are the base point
+func statementDigest(grp kyber.Group, g, h kyber.Point, X, Y, Xbar, Ybar, bases []kyber.Point, cols, colsbar [][]kyber.Point) []byte {
+	H := sha256.New()
+	for _, P := range []kyber.Point{g, h} {
+		if P == nil {
+			P = grp.Point().Base()
+		}
+		P.MarshalTo(H)
+	}
+	lists := append([][]kyber.Point{X, Y, Xbar, Ybar, bases}, cols...)
+	for _, list := range append(lists, colsbar...) {
+		for _, P := range list {
+			P.MarshalTo(H)
+		}
+	}
+	return H.Sum(nil)
 }
 
 // randUint64 chooses a uniform random uint64
@@ -414,12 +659,26 @@ func randUint64(rand cipher.Stream) uint64 {
 	//大端序（Big Endian）是一种字节序的表示方式，它规定数据的高字节存储在内存的低地址端，低字节存储在内存的高地址端。
 }
 
-// Verifier produces a Sigma-protocol verifier to check the correctness of a shuffle.
+// Verifier produces a Sigma-protocol verifier to check the correctness of a shuffle,
+// run by workers goroutines, see SetWorkers, batch checks (33) at once, see SetBatch.
 func Verifier(group kyber.Group, g, h kyber.Point,
-	X, Y, Xbar, Ybar []kyber.Point) proof.Verifier {
+	X, Y, Xbar, Ybar []kyber.Point, workers int, batch bool) proof.Verifier {
+
+	return ColumnsVerifier(group, g, h, X, Y, Xbar, Ybar, nil, nil, nil, workers, batch)
+}
+
+// ColumnsVerifier is Verifier for a shuffle of the pairs with further columns cols to colsbar,
+// re-randomized with bases, see SetColumns.
+func ColumnsVerifier(group kyber.Group, g, h kyber.Point, X, Y, Xbar, Ybar []kyber.Point,
+	bases []kyber.Point, cols, colsbar [][]kyber.Point, workers int, batch bool) proof.Verifier {
 
+	if len(X) <= 1 {
+		return func(ctx proof.VerifierContext) error {
+			return verifySmall(group, g, h, X, Y, Xbar, Ybar, bases, cols, colsbar, ctx)
+		}
+	}
 	ps := PairShuffle{}
-	ps.Init(group, len(X))
+	ps.Init(group, len(X)).SetWorkers(workers).SetBatch(batch).SetColumns(bases, cols, colsbar)
 	verifier := func(ctx proof.VerifierContext) error {
 		return ps.Verify(g, h, X, Y, Xbar, Ybar, ctx)
 	}
@@ -0,0 +1,183 @@
+package shuffle
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// seededSuite draws its secrets from a fixed seed, so two proofs of the same shuffle can be compared
+type seededSuite struct {
+	Suite
+	seed []byte
+}
+
+func (s seededSuite) RandomStream() cipher.Stream {
+	return s.Suite.XOF(s.seed)
+}
+
+func testSuite(seed string) seededSuite {
+	return seededSuite{edwards25519.NewBlakeSHA256Ed25519(), []byte(seed)}
+}
+
+// testPairs are k pairs the way an OA shuffles them: the key h and a nym each
+func testPairs(suite Suite, k int) (h kyber.Point, X, Y []kyber.Point) {
+	h = suite.Point().Mul(suite.Scalar().Pick(suite.XOF([]byte("key"))), nil)
+	X = make([]kyber.Point, k)
+	Y = make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		X[i] = h
+		Y[i] = suite.Point().Pick(suite.XOF([]byte("nym" + strconv.Itoa(i))))
+	}
+	return h, X, Y
+}
+
+func TestPairShuffle(t *testing.T) {
+	suite := testSuite("pairs")
+	for _, k := range []int{2, 3, 10, 33} {
+		h, X, Y := testPairs(suite, k)
+		Xbar, Ybar, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), 0)
+		prf, err := proof.HashProve(suite, "PairShuffle", prover)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, batch := range []bool{false, true} {
+			verifier := Verifier(suite, nil, h, X, Y, Xbar, Ybar, 0, batch)
+			if err := proof.HashVerify(suite, "PairShuffle", verifier, prf); err != nil {
+				t.Fatalf("k %d batch %v: %v", k, batch, err)
+			}
+		}
+	}
+}
+
+// the chunks only split the work, every number of workers gives the same proof
+func TestProofIndependentOfWorkers(t *testing.T) {
+	for _, k := range []int{2, 7, 64} {
+		var first []byte
+		for _, workers := range []int{1, 2, 3, 8, 0} {
+			suite := testSuite("workers")
+			h, X, Y := testPairs(suite, k)
+			Xbar, Ybar, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), workers)
+			prf, err := proof.HashProve(suite, "PairShuffle", prover)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = prf
+			} else if !bytes.Equal(prf, first) {
+				t.Fatalf("k %d: the proof of %d workers differs from the one of 1 worker", k, workers)
+			}
+			verifier := Verifier(suite, nil, h, X, Y, Xbar, Ybar, workers, true)
+			if err := proof.HashVerify(suite, "PairShuffle", verifier, prf); err != nil {
+				t.Fatalf("k %d workers %d: %v", k, workers, err)
+			}
+		}
+	}
+}
+
+// testColumns are the ciphertexts C1, C2 of k values under valueKey the way an OA shuffles them
+func testColumns(suite Suite, k int) (bases []kyber.Point, cols [][]kyber.Point) {
+	rand := suite.XOF([]byte("values"))
+	valueKey := suite.Point().Pick(rand)
+	C1 := make([]kyber.Point, k)
+	C2 := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		C1[i] = suite.Point().Pick(rand)
+		C2[i] = suite.Point().Pick(rand)
+	}
+	return []kyber.Point{suite.Point().Base(), valueKey}, [][]kyber.Point{C1, C2}
+}
+
+func TestShuffleColumns(t *testing.T) {
+	suite := testSuite("columns")
+	other := suite.Point().Pick(suite.XOF([]byte("other")))
+	for _, k := range []int{1, 2, 5} {
+		h, X, Y := testPairs(suite, k)
+		bases, cols := testColumns(suite, k)
+		Xbar, Ybar, _, colsbar, prover := ShuffleColumns(suite, nil, h, X, Y, bases, cols, suite.RandomStream(), 0)
+		prf, err := proof.HashProve(suite, "PairShuffle", prover)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify := func(bases []kyber.Point, cols, colsbar [][]kyber.Point, batch bool) error {
+			verifier := ColumnsVerifier(suite, nil, h, X, Y, Xbar, Ybar, bases, cols, colsbar, 0, batch)
+			return proof.HashVerify(suite, "PairShuffle", verifier, prf)
+		}
+		for _, batch := range []bool{false, true} {
+			if err := verify(bases, cols, colsbar, batch); err != nil {
+				t.Fatalf("k %d batch %v: %v", k, batch, err)
+			}
+			//a forged C2bar, C1bar or base is not what the proof is about
+			for c := range colsbar {
+				saved := colsbar[c][k-1]
+				colsbar[c][k-1] = other
+				if verify(bases, cols, colsbar, batch) == nil {
+					t.Fatalf("k %d batch %v: the proof verifies a forged element of column %d", k, batch, c)
+				}
+				colsbar[c][k-1] = saved
+			}
+			if verify([]kyber.Point{bases[0], other}, cols, colsbar, batch) == nil {
+				t.Fatalf("k %d batch %v: the proof verifies another base", k, batch)
+			}
+		}
+
+		//the factors of the values are not those of the keys: with g the base of C1, an output
+		//pair and its ciphertext do not point to the input they came from
+		for i := 0; i < k; i++ {
+			out := suite.Point().Sub(Xbar[i], colsbar[0][i])
+			for j := 0; j < k; j++ {
+				if out.Equal(suite.Point().Sub(X[j], cols[0][j])) {
+					t.Fatalf("k %d: Xbar-C1bar of output %d matches input %d", k, i, j)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPairShuffle(b *testing.B) {
+	for _, k := range []int{10, 100, 1000} {
+		for _, workers := range []int{1, 0} {
+			b.Run(fmt.Sprintf("k=%d/workers=%d", k, workers), func(b *testing.B) {
+				suite := testSuite("bench")
+				h, X, Y := testPairs(suite, k)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), workers)
+					if _, err := proof.HashProve(suite, "PairShuffle", prover); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	for _, k := range []int{10, 100, 1000} {
+		suite := testSuite("bench")
+		h, X, Y := testPairs(suite, k)
+		Xbar, Ybar, _, prover := Shuffle(suite, nil, h, X, Y, suite.RandomStream(), 0)
+		prf, err := proof.HashProve(suite, "PairShuffle", prover)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, workers := range []int{1, 0} {
+			for _, batch := range []bool{false, true} {
+				b.Run(fmt.Sprintf("k=%d/workers=%d/batch=%v", k, workers, batch), func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						verifier := Verifier(suite, nil, h, X, Y, Xbar, Ybar, workers, batch)
+						if err := proof.HashVerify(suite, "PairShuffle", verifier, prf); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
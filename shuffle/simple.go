@@ -0,0 +1,268 @@
+package shuffle
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// The simple k-shuffle of go.dedis.ch/kyber/v4/shuffle, with the steps per element split over
+// the workers like in PairShuffle. The proof is the same.  //与 kyber 相同的简单 k-shuffle，逐元素步骤并行
+
+// P (Prover) step 0: public inputs to the simple k-shuffle.
+type ssa0 struct {
+	X []kyber.Point
+	Y []kyber.Point
+}
+
+// V (Verifier) step 1: random challenge t
+type ssa1 struct {
+	Zt kyber.Scalar
+}
+
+// P step 2: Theta vectors
+type ssa2 struct {
+	Theta []kyber.Point
+}
+
+// V step 3: random challenge c
+type ssa3 struct {
+	Zc kyber.Scalar
+}
+
+// P step 4: alpha vector
+type ssa4 struct {
+	Zalpha []kyber.Scalar
+}
+
+// SimpleShuffle is the "Simple k-shuffle" defined in section 3 of
+// Neff, "Verifiable Mixing (Shuffling) of ElGamal Pairs", 2004.
+type SimpleShuffle struct {
+	grp     kyber.Group
+	workers int
+	p0      ssa0
+	v1      ssa1
+	p2      ssa2
+	v3      ssa3
+	p4      ssa4
+}
+
+// Simple helper to compute G^{ab-cd} for Theta vector computation.
+func thenc(grp kyber.Group, G kyber.Point,
+	a, b, c, d kyber.Scalar) kyber.Point {
+
+	var ab, cd kyber.Scalar
+	if a != nil {
+		ab = grp.Scalar().Mul(a, b)
+	} else {
+		ab = grp.Scalar().Zero()
+	}
+	if c != nil {
+		if d != nil {
+			cd = grp.Scalar().Mul(c, d)
+		} else {
+			cd = c
+		}
+	} else {
+		cd = grp.Scalar().Zero()
+	}
+	return grp.Point().Mul(ab.Sub(ab, cd), G)
+}
+
+// Init initializes the simple shuffle with the given group and the k parameter
+// from the paper.
+func (ss *SimpleShuffle) Init(grp kyber.Group, k int) *SimpleShuffle {
+	ss.grp = grp
+	ss.p0.X = make([]kyber.Point, k)
+	ss.p0.Y = make([]kyber.Point, k)
+	ss.p2.Theta = make([]kyber.Point, 2*k)
+	ss.p4.Zalpha = make([]kyber.Scalar, 2*k-1)
+	return ss
+}
+
+// Prove the  "Simple k-shuffle" defined in section 3 of
+// Neff, "Verifiable Mixing (Shuffling) of ElGamal Pairs", 2004.
+// The Scalar vector y must be a permutation of Scalar vector x
+// but with all elements multiplied by common Scalar gamma.
+func (ss *SimpleShuffle) Prove(G kyber.Point, gamma kyber.Scalar,
+	x, y []kyber.Scalar, rand cipher.Stream,
+	ctx proof.ProverContext) error {
+
+	grp := ss.grp
+
+	k := len(x)
+	if k <= 1 {
+		panic("can't shuffle length 1 vector")
+	}
+	if k != len(y) {
+		panic("mismatched vector lengths")
+	}
+
+	// Step 0: inputs
+	parallel(k, chunks(k, ss.workers), func(_, lo, hi int) {
+		for i := lo; i < hi; i++ { // (4)
+			ss.p0.X[i] = grp.Point().Mul(x[i], G)
+			ss.p0.Y[i] = grp.Point().Mul(y[i], G)
+		}
+	})
+	if err := ctx.Put(ss.p0); err != nil {
+		return err
+	}
+
+	// V step 1
+	if err := ctx.PubRand(&ss.v1); err != nil {
+		return err
+	}
+	t := ss.v1.Zt
+
+	// P step 2
+	gammaT := grp.Scalar().Mul(gamma, t)
+	xhat := make([]kyber.Scalar, k)
+	yhat := make([]kyber.Scalar, k)
+	for i := 0; i < k; i++ { // (5) and (6) xhat,yhat vectors
+		xhat[i] = grp.Scalar().Sub(x[i], t)
+		yhat[i] = grp.Scalar().Sub(y[i], gammaT)
+	}
+	thlen := 2*k - 1 // (7) theta and Theta vectors
+	theta := make([]kyber.Scalar, thlen)
+	ctx.PriRand(theta)
+	Theta := make([]kyber.Point, thlen+1)
+	Theta[0] = thenc(grp, G, nil, nil, theta[0], yhat[0])
+	parallel(thlen-1, chunks(thlen-1, ss.workers), func(_, lo, hi int) {
+		for i := lo + 1; i < hi+1; i++ {
+			if i < k {
+				Theta[i] = thenc(grp, G, theta[i-1], xhat[i],
+					theta[i], yhat[i])
+			} else {
+				Theta[i] = thenc(grp, G, theta[i-1], gamma,
+					theta[i], nil)
+			}
+		}
+	})
+	Theta[thlen] = thenc(grp, G, theta[thlen-1], gamma, nil, nil)
+	ss.p2.Theta = Theta
+	if err := ctx.Put(ss.p2); err != nil {
+		return err
+	}
+
+	// V step 3
+	if err := ctx.PubRand(&ss.v3); err != nil {
+		return err
+	}
+	c := ss.v3.Zc
+
+	// P step 4
+	alpha := make([]kyber.Scalar, thlen)
+	runprod := grp.Scalar().Set(c)
+	for i := 0; i < k; i++ { // (8)
+		runprod.Mul(runprod, xhat[i])
+		runprod.Div(runprod, yhat[i])
+		alpha[i] = grp.Scalar().Add(theta[i], runprod)
+	}
+	gammainv := grp.Scalar().Inv(gamma)
+	rungamma := grp.Scalar().Set(c)
+	for i := 1; i < k; i++ {
+		rungamma.Mul(rungamma, gammainv)
+		alpha[thlen-i] = grp.Scalar().Add(theta[thlen-i], rungamma)
+	}
+	ss.p4.Zalpha = alpha
+	return ctx.Put(ss.p4)
+}
+
+// Simple helper to verify Theta elements,
+// by checking whether A^a*B^-b = T.
+// P,Q,s are simply "scratch" kyber.Point/Scalars reused for efficiency.
+func thver(A, B, T, P, Q kyber.Point, a, b, s kyber.Scalar) bool {
+	P.Mul(a, A)
+	Q.Mul(s.Neg(b), B)
+	P.Add(P, Q)
+	return P.Equal(T)
+}
+
+// Verify for Neff simple k-shuffle proofs.
+func (ss *SimpleShuffle) Verify(G, Gamma kyber.Point,
+	ctx proof.VerifierContext) error {
+
+	grp := ss.grp
+
+	// extract proof transcript
+	X := ss.p0.X
+	Y := ss.p0.Y
+	Theta := ss.p2.Theta
+	alpha := ss.p4.Zalpha
+
+	// Validate all vector lengths
+	k := len(Y)
+	thlen := 2*k - 1
+	if k <= 1 || len(Y) != k || len(Theta) != thlen+1 ||
+		len(alpha) != thlen {
+		return errors.New("malformed SimpleShuffleProof")
+	}
+
+	// check verifiable challenges (usually by reproducing a hash)
+	if err := ctx.Get(ss.p0); err != nil {
+		return err
+	}
+	if err := ctx.PubRand(&ss.v1); err != nil { // fills in v1
+		return err
+	}
+	t := ss.v1.Zt
+	if err := ctx.Get(ss.p2); err != nil {
+		return err
+	}
+	if err := ctx.PubRand(&ss.v3); err != nil { // fills in v3
+		return err
+	}
+	c := ss.v3.Zc
+	if err := ctx.Get(ss.p4); err != nil {
+		return err
+	}
+
+	// Verifier step 5
+	negt := grp.Scalar().Neg(t)
+	U := grp.Point().Mul(negt, G)
+	W := grp.Point().Mul(negt, Gamma)
+	Xhat := make([]kyber.Point, k)
+	Yhat := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		Xhat[i] = grp.Point().Add(X[i], U)
+		Yhat[i] = grp.Point().Add(Y[i], W)
+	}
+	//every Theta element is checked on its own, a chunk stops at its first bad one
+	n := chunks(thlen+1, ss.workers)
+	bad := make([]bool, n)
+	parallel(thlen+1, n, func(chunk, lo, hi int) {
+		P := grp.Point() // scratch variables
+		Q := grp.Point()
+		s := grp.Scalar()
+		for i := lo; i < hi; i++ {
+			var good bool
+			switch {
+			case i == 0:
+				good = thver(Xhat[0], Yhat[0], Theta[0], P, Q, c, alpha[0], s)
+			case i < k:
+				good = thver(Xhat[i], Yhat[i], Theta[i], P, Q,
+					alpha[i-1], alpha[i], s)
+			case i < thlen:
+				good = thver(Gamma, G, Theta[i], P, Q,
+					alpha[i-1], alpha[i], s)
+			default:
+				good = thver(Gamma, G, Theta[thlen], P, Q,
+					alpha[thlen-1], c, s)
+			}
+			if !good {
+				bad[chunk] = true
+				return
+			}
+		}
+	})
+	for _, b := range bad {
+		if b {
+			return errors.New("incorrect SimpleShuffleProof")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package shuffle
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// A list of fewer than two pairs has no permutation to hide and the Neff proof needs two pairs.
+// The empty list shuffles to the empty list with an empty proof. A single pair is only
+// re-randomized, Xbar = X + beta·g and Ybar = Y + beta·h, and the proof shows that Xbar-X and
+// Ybar-Y have the same discrete logarithm to g and h (Chaum-Pedersen), so the hop can not
+// substitute the key. Further columns are re-randomized with a factor delta of their own and
+// their bases, colsbar = cols + delta·bases, like SetColumns, and the same proof shows one delta
+// for all of them.  //少于两对时的混洗与证明
+
+// P step 1: the statement, hashed into the challenge, and the commitments
+type dleq1 struct {
+	G, H, X, Y, Xbar, Ybar kyber.Point
+	T1, T2                 kyber.Point
+	//base, element before and after and commitment of every further column
+	Bases, Cols, Colsbar, Ts []kyber.Point
+}
+
+// V step 2: random challenge
+type dleq2 struct {
+	C kyber.Scalar
+}
+
+// P step 3: responses for beta and delta
+type dleq3 struct {
+	R, Rd kyber.Scalar
+}
+
+// shuffleSmall shuffles a list of 0 or 1 pairs like ShuffleColumns
+func shuffleSmall(grp kyber.Group, g, h kyber.Point, X, Y []kyber.Point, bases []kyber.Point, cols [][]kyber.Point,
+	rand cipher.Stream) (Xbar, Ybar, Ytmp []kyber.Point, colsbar [][]kyber.Point, prover proof.Prover) {
+
+	colsbar = make([][]kyber.Point, len(cols))
+	if len(X) == 0 {
+		for c := range colsbar {
+			colsbar[c] = []kyber.Point{}
+		}
+		return []kyber.Point{}, []kyber.Point{}, []kyber.Point{}, colsbar, func(proof.ProverContext) error { return nil }
+	}
+	if g == nil {
+		g = grp.Point().Base()
+	}
+	beta := grp.Scalar().Pick(rand)
+	delta := grp.Scalar().Pick(rand)
+	Ytmp = []kyber.Point{grp.Point().Mul(beta, h)}
+	Xbar = []kyber.Point{grp.Point().Add(grp.Point().Mul(beta, g), X[0])}
+	Ybar = []kyber.Point{grp.Point().Add(Ytmp[0], Y[0])}
+	for c := range colsbar {
+		colsbar[c] = []kyber.Point{grp.Point().Add(grp.Point().Mul(delta, bases[c]), cols[c][0])}
+	}
+
+	prover = func(ctx proof.ProverContext) error {
+		var v, vd kyber.Scalar
+		if err := ctx.PriRand(&v, &vd); err != nil {
+			return err
+		}
+		p1 := dleq1{G: g, H: h, X: X[0], Y: Y[0], Xbar: Xbar[0], Ybar: Ybar[0],
+			T1: grp.Point().Mul(v, g), T2: grp.Point().Mul(v, h), Bases: bases}
+		for c := range cols {
+			p1.Cols = append(p1.Cols, cols[c][0])
+			p1.Colsbar = append(p1.Colsbar, colsbar[c][0])
+			p1.Ts = append(p1.Ts, grp.Point().Mul(vd, bases[c]))
+		}
+		if err := ctx.Put(p1); err != nil {
+			return err
+		}
+		v2 := dleq2{grp.Scalar()}
+		if err := ctx.PubRand(&v2); err != nil {
+			return err
+		}
+		// r = v - c·beta and rd = vd - c·delta
+		p3 := dleq3{grp.Scalar().Sub(v, grp.Scalar().Mul(v2.C, beta)), grp.Scalar().Sub(vd, grp.Scalar().Mul(v2.C, delta))}
+		return ctx.Put(p3)
+	}
+	return Xbar, Ybar, Ytmp, colsbar, prover
+}
+
+// verifySmall checks the proof of a shuffle of 0 or 1 pairs
+func verifySmall(grp kyber.Group, g, h kyber.Point, X, Y, Xbar, Ybar []kyber.Point,
+	bases []kyber.Point, cols, colsbar [][]kyber.Point, ctx proof.VerifierContext) error {
+
+	k := len(X)
+	if len(Y) != k || len(Xbar) != k || len(Ybar) != k {
+		return errors.New("invalid shuffle: lists of different length")
+	}
+	m := len(bases)
+	if len(cols) != m || len(colsbar) != m {
+		return errors.New("invalid shuffle: a column or its base is missing")
+	}
+	for c := 0; c < m; c++ {
+		if len(cols[c]) != k || len(colsbar[c]) != k {
+			return fmt.Errorf("invalid shuffle: column %d has not %d elements", c, k)
+		}
+	}
+	if k == 0 {
+		return nil
+	}
+	if g == nil {
+		g = grp.Point().Base()
+	}
+
+	p1 := dleq1{grp.Point(), grp.Point(), grp.Point(), grp.Point(), grp.Point(), grp.Point(), grp.Point(), grp.Point(),
+		points(grp, m), points(grp, m), points(grp, m), points(grp, m)}
+	if err := ctx.Get(&p1); err != nil {
+		return err
+	}
+	if !p1.G.Equal(g) || !p1.H.Equal(h) || !p1.X.Equal(X[0]) || !p1.Y.Equal(Y[0]) ||
+		!p1.Xbar.Equal(Xbar[0]) || !p1.Ybar.Equal(Ybar[0]) {
+		return errors.New("invalid shuffle proof: it proves another statement")
+	}
+	for c := 0; c < m; c++ {
+		if !p1.Bases[c].Equal(bases[c]) || !p1.Cols[c].Equal(cols[c][0]) || !p1.Colsbar[c].Equal(colsbar[c][0]) {
+			return errors.New("invalid shuffle proof: it proves another statement")
+		}
+	}
+	v2 := dleq2{grp.Scalar()}
+	if err := ctx.PubRand(&v2); err != nil {
+		return err
+	}
+	p3 := dleq3{grp.Scalar(), grp.Scalar()}
+	if err := ctx.Get(&p3); err != nil {
+		return err
+	}
+
+	// r·g + c·(Xbar-X) = T1, r·h + c·(Ybar-Y) = T2 and rd·base + c·(colbar-col) = T of every column
+	if !dleqHolds(grp, p3.R, v2.C, g, X[0], Xbar[0], p1.T1) || !dleqHolds(grp, p3.R, v2.C, h, Y[0], Ybar[0], p1.T2) {
+		return errors.New("invalid shuffle proof: the pair is not re-randomized with one factor")
+	}
+	for c := 0; c < m; c++ {
+		if !dleqHolds(grp, p3.Rd, v2.C, bases[c], cols[c][0], colsbar[c][0], p1.Ts[c]) {
+			return fmt.Errorf("invalid shuffle proof: column %d is not re-randomized with the factor of the columns", c)
+		}
+	}
+	return nil
+}
+
+// dleqHolds checks the response r to the challenge c for the commitment T of base:
+// r·base + c·(after-before) = T
+func dleqHolds(grp kyber.Group, r, c kyber.Scalar, base, before, after, T kyber.Point) bool {
+	P := grp.Point().Mul(c, grp.Point().Sub(after, before))
+	P.Add(P, grp.Point().Mul(r, base))
+	return P.Equal(T)
+}
+
+// points are m points to read a proof into
+func points(grp kyber.Group, m int) []kyber.Point {
+	list := make([]kyber.Point, m)
+	for i := range list {
+		list[i] = grp.Point()
+	}
+	return list
+}
@@ -0,0 +1,98 @@
+package shuffle
+
+import (
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// smallCase shuffles k pairs with m further columns and returns what the verifier gets
+type smallCase struct {
+	h                kyber.Point
+	X, Y, Xbar, Ybar []kyber.Point
+	bases            []kyber.Point
+	cols, colsbar    [][]kyber.Point
+	prf              []byte
+}
+
+func newSmallCase(t *testing.T, suite Suite, k, m int) *smallCase {
+	sc := &smallCase{}
+	sc.h, sc.X, sc.Y = testPairs(suite, k)
+	rand := suite.RandomStream()
+	for c := 0; c < m; c++ {
+		sc.bases = append(sc.bases, suite.Point().Pick(rand))
+		col := make([]kyber.Point, k)
+		for i := range col {
+			col[i] = suite.Point().Pick(rand)
+		}
+		sc.cols = append(sc.cols, col)
+	}
+	var prover proof.Prover
+	sc.Xbar, sc.Ybar, _, sc.colsbar, prover = ShuffleColumns(suite, nil, sc.h, sc.X, sc.Y, sc.bases, sc.cols, rand, 0)
+	var err error
+	if sc.prf, err = proof.HashProve(suite, "PairShuffle", prover); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func (sc *smallCase) verify(suite Suite) error {
+	verifier := ColumnsVerifier(suite, nil, sc.h, sc.X, sc.Y, sc.Xbar, sc.Ybar, sc.bases, sc.cols, sc.colsbar, 0, false)
+	return proof.HashVerify(suite, "PairShuffle", verifier, sc.prf)
+}
+
+func TestShuffleSmall(t *testing.T) {
+	suite := testSuite("small")
+	for _, k := range []int{0, 1} {
+		for _, m := range []int{0, 1, 2} {
+			sc := newSmallCase(t, suite, k, m)
+			if len(sc.Xbar) != k || len(sc.Ybar) != k || len(sc.colsbar) != m {
+				t.Fatalf("k %d m %d: the outputs have other lengths", k, m)
+			}
+			if err := sc.verify(suite); err != nil {
+				t.Fatalf("k %d m %d: %v", k, m, err)
+			}
+			if k == 1 && (sc.Xbar[0].Equal(sc.X[0]) || sc.Ybar[0].Equal(sc.Y[0])) {
+				t.Fatalf("m %d: the pair is not re-randomized", m)
+			}
+		}
+	}
+}
+
+func TestShuffleSmallTampered(t *testing.T) {
+	suite := testSuite("small")
+	other := suite.Point().Pick(suite.XOF([]byte("other")))
+	for name, tamper := range map[string]func(sc *smallCase){
+		"Xbar":    func(sc *smallCase) { sc.Xbar[0] = other },
+		"Ybar":    func(sc *smallCase) { sc.Ybar[0] = other },
+		"X":       func(sc *smallCase) { sc.X[0] = other },
+		"h":       func(sc *smallCase) { sc.h = other },
+		"column":  func(sc *smallCase) { sc.colsbar[1][0] = other },
+		"base":    func(sc *smallCase) { sc.bases[0] = other },
+		"proof":   func(sc *smallCase) { sc.prf[len(sc.prf)-1] ^= 1 },
+		"no pair": func(sc *smallCase) { sc.Xbar, sc.Ybar = nil, nil },
+		//Ybar re-randomized with another factor than Xbar
+		"factor": func(sc *smallCase) { sc.Ybar[0] = suite.Point().Add(sc.Ybar[0], sc.h) },
+		//the second column re-randomized with another factor than the first
+		"column factor": func(sc *smallCase) { sc.colsbar[1][0] = suite.Point().Add(sc.colsbar[1][0], sc.bases[1]) },
+	} {
+		sc := newSmallCase(t, suite, 1, 2)
+		tamper(sc)
+		if sc.verify(suite) == nil {
+			t.Fatalf("the proof verifies with a changed %s", name)
+		}
+	}
+
+	//an empty list has no outputs and every column stays empty
+	sc := newSmallCase(t, suite, 0, 1)
+	sc.Xbar = []kyber.Point{other}
+	if sc.verify(suite) == nil {
+		t.Fatal("the empty shuffle verifies an output pair")
+	}
+	sc = newSmallCase(t, suite, 0, 1)
+	sc.colsbar[0] = []kyber.Point{other}
+	if sc.verify(suite) == nil {
+		t.Fatal("the empty shuffle verifies an output column element")
+	}
+}
@@ -0,0 +1,376 @@
+package shuffle
+
+import (
+	"NPTM/util"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// Transcript is what one hop of a list shuffle published: the keys it got, the pairs it shuffled,
+// the shuffled pairs and the proofs, so the hop can be verified after the round.  //每一跳混洗的记录，可事后验证
+type Transcript struct {
+	K0      int64 //K0 of the block the list is shuffled after, -1 before the genesis block
+	Forward bool  //direction of the shuffle
+	Hop     int   //position of the OA in the topology
+
+	PublicKey []byte //h of the shuffle, the key of the OA
+	//the keys the hop got, the Keys of the hop before it. The forward shuffle raises them to the
+	//round key of the OA to Y, the reverse shuffle removes the round key from them to Y
+	RecvKeys []byte
+	//point lists of the pairs before and after the shuffle
+	X, Y       []byte
+	Xbar, Ybar []byte
+	//the shuffled keys passed to the next hop, a permutation of Y
+	Keys []byte
+	//g of the round before and after this hop, G is RecvG raised to the round key. The reverse
+	//shuffle names the g of the forward shuffle the round key was used on
+	RecvG, G []byte
+	//the proofs of the round key and of the shuffle
+	ExpProof []byte
+	Proof    []byte
+
+	//point lists of the ElGamal ciphertexts of the values before and after the shuffle, shuffled
+	//with the keys in the same proof and re-randomized with factors of their own times B and ValueKey
+	C1, C2       []byte
+	C1bar, C2bar []byte
+	ValueKey     []byte
+	//forward shuffle only: the C2 the hop got, before it removed the share of its key to C2, and
+	//the proof of that
+	RecvC2   []byte
+	DecProof []byte
+}
+
+// Verify checks the proofs of the hop with HashVerify, run by workers goroutines and batched like
+// SetBatch if batch is set, and that the keys it passed on are the keys it shuffled, each once
+func (t *Transcript) Verify(suite Suite, workers int, batch bool) error {
+	if len(t.ValueKey) == 0 {
+		return errors.New("no values shuffled with the keys, the transcript is older than the value proofs")
+	}
+	if len(t.ExpProof) == 0 {
+		return errors.New("no proof of the round key, the transcript is older than the exponent proofs")
+	}
+	var lists [10][]kyber.Point
+	for i, b := range [][]byte{t.X, t.Y, t.Xbar, t.Ybar, t.Keys, t.C1, t.C2, t.C1bar, t.C2bar, t.RecvKeys} {
+		list, err := util.DecodePointList(b)
+		if err != nil {
+			return err
+		}
+		lists[i] = list
+	}
+	X, Y, Xbar, Ybar, keys := lists[0], lists[1], lists[2], lists[3], lists[4]
+	C1, C2, C1bar, C2bar, recvKeys := lists[5], lists[6], lists[7], lists[8], lists[9]
+	k := len(Y)
+	for _, list := range lists {
+		if len(list) != k {
+			return errors.New("shuffle lists of different length")
+		}
+	}
+	h := suite.Point()
+	if err := h.UnmarshalBinary(t.PublicKey); err != nil {
+		return err
+	}
+	valueKey := suite.Point()
+	if err := valueKey.UnmarshalBinary(t.ValueKey); err != nil {
+		return err
+	}
+	recvG, g := suite.Point(), suite.Point()
+	if err := recvG.UnmarshalBinary(t.RecvG); err != nil {
+		return err
+	}
+	if err := g.UnmarshalBinary(t.G); err != nil {
+		return err
+	}
+
+	//the keys it got are raised to the round key, or the round key is removed from them, in place
+	verifier := ExponentVerifier(suite, recvG, g, recvKeys, Y, workers)
+	if !t.Forward {
+		verifier = ExponentVerifier(suite, recvG, g, Y, recvKeys, workers)
+	}
+	if err := proof.HashVerify(suite, "Exponent", verifier, t.ExpProof); err != nil {
+		return errors.New("Exponent verify failed: " + err.Error())
+	}
+
+	//every key of Y is passed on once  //每个输入假名恰好输出一次
+	count := make(map[string]int)
+	for _, y := range Y {
+		count[y.String()]++
+	}
+	for _, key := range keys {
+		if count[key.String()] == 0 {
+			return fmt.Errorf("the key %v is not one of the shuffled keys or passed on twice", key)
+		}
+		count[key.String()]--
+	}
+
+	if t.Forward {
+		recvC2, err := util.DecodePointList(t.RecvC2)
+		if err != nil {
+			return err
+		}
+		verifier := DecryptVerifier(suite, h, C1, recvC2, C2, workers)
+		if err := proof.HashVerify(suite, "Decrypt", verifier, t.DecProof); err != nil {
+			return errors.New("Decryption verify failed: " + err.Error())
+		}
+	}
+
+	verifier = ColumnsVerifier(suite, nil, h, X, Y, Xbar, Ybar, []kyber.Point{suite.Point().Base(), valueKey},
+		[][]kyber.Point{C1, C2}, [][]kyber.Point{C1bar, C2bar}, workers, batch)
+	if err := proof.HashVerify(suite, "PairShuffle", verifier, t.Proof); err != nil {
+		return errors.New("Shuffle verify failed: " + err.Error())
+	}
+	return nil
+}
+
+// CheckRound checks that the transcripts are one direction of the shuffle of a list through the n OAs
+// of the topology: every OA shuffled it once, got the keys, g and values the hop before it passed on
+// and kept their number. prev are the transcripts of the round before in the other direction, nil if
+// they are not archived: a forward shuffle starts with the list of the reverse shuffle before it, a
+// reverse shuffle removes the round keys of the forward shuffle before it. The transcripts must have
+// passed Verify.  //检查一轮混洗中相邻跳之间的键、g与值相连
+func CheckRound(suite Suite, transcripts []*Transcript, n int, prev []*Transcript) error {
+	if len(transcripts) == 0 {
+		return errors.New("no transcripts")
+	}
+	forward := transcripts[0].Forward
+	hops, err := byHop(transcripts, n)
+	if err != nil {
+		return err
+	}
+	for hop := 0; hop < n; hop++ {
+		if hops[hop].Forward != forward {
+			return fmt.Errorf("hop %d shuffled in the other direction", hop)
+		}
+		if got, want := size(hops[hop].Y), size(transcripts[0].Y); got != want {
+			return fmt.Errorf("hop %d shuffled %d keys, hop %d %d", hop, got, transcripts[0].Hop, want)
+		}
+	}
+	var before map[int]*Transcript
+	if prev != nil {
+		if before, err = byHop(prev, n); err != nil {
+			return fmt.Errorf("the round before: %v", err)
+		}
+	}
+
+	base, _ := suite.Point().Base().MarshalBinary()
+	for hop := 0; hop < n; hop++ {
+		t := hops[hop]
+		switch {
+		case forward && hop > 0:
+			if !bytes.Equal(t.RecvKeys, hops[hop-1].Keys) || !bytes.Equal(t.RecvG, hops[hop-1].G) {
+				return fmt.Errorf("hop %d did not raise the keys and g hop %d passed on", hop, hop-1)
+			}
+		case forward:
+			if !bytes.Equal(t.RecvG, base) {
+				return fmt.Errorf("hop %d started the round with another g than the base point", hop)
+			}
+			if before != nil && !bytes.Equal(t.RecvKeys, before[hop].Keys) {
+				return fmt.Errorf("hop %d did not start with the keys of the reverse shuffle", hop)
+			}
+		case hop < n-1:
+			if !bytes.Equal(t.RecvKeys, hops[hop+1].Keys) {
+				return fmt.Errorf("hop %d did not shuffle the keys hop %d passed on", hop, hop+1)
+			}
+		}
+		if !forward && before != nil && (!bytes.Equal(t.RecvG, before[hop].RecvG) || !bytes.Equal(t.G, before[hop].G)) {
+			return fmt.Errorf("hop %d removed another round key than the one of its forward shuffle", hop)
+		}
+	}
+	if forward && before != nil {
+		if !bytes.Equal(hops[0].C1, before[0].C1bar) || !bytes.Equal(hops[0].RecvC2, before[0].C2bar) {
+			return errors.New("hop 0 did not start with the values of the reverse shuffle")
+		}
+	}
+	return checkValues(suite, hops, n, forward)
+}
+
+//byHop indexes the transcripts of a round by their hop, each of the n hops once
+func byHop(transcripts []*Transcript, n int) (map[int]*Transcript, error) {
+	hops := make(map[int]*Transcript)
+	for _, t := range transcripts {
+		if _, ok := hops[t.Hop]; ok {
+			return nil, fmt.Errorf("hop %d shuffled the list twice", t.Hop)
+		}
+		hops[t.Hop] = t
+	}
+	for hop := 0; hop < n; hop++ {
+		if _, ok := hops[hop]; !ok {
+			return nil, fmt.Errorf("no transcript of hop %d", hop)
+		}
+	}
+	if len(hops) != n {
+		return nil, fmt.Errorf("%d hops, but the topology has %d OAs", len(hops), n)
+	}
+	return hops, nil
+}
+
+//checkValues checks that every hop shuffled the values under the keys of the OAs that had not
+//removed their shares and that it shuffled the values the hop before it passed on
+//检查每一跳的值密文与上一跳的输出相连
+func checkValues(suite Suite, hops map[int]*Transcript, n int, forward bool) error {
+	//the values are shuffled back under the keys of all OAs, forward hop h removes its share
+	keys := make([]kyber.Point, n)
+	for hop := 0; hop < n; hop++ {
+		keys[hop] = suite.Point()
+		if err := keys[hop].UnmarshalBinary(hops[hop].PublicKey); err != nil {
+			return fmt.Errorf("hop %d: %v", hop, err)
+		}
+	}
+	for hop := 0; hop < n; hop++ {
+		from := 0
+		if forward {
+			from = hop + 1
+		}
+		want := suite.Point().Null()
+		for _, key := range keys[from:] {
+			want.Add(want, key)
+		}
+		got := suite.Point()
+		if err := got.UnmarshalBinary(hops[hop].ValueKey); err != nil || !got.Equal(want) {
+			return fmt.Errorf("hop %d shuffled the values under another key than the keys of the OAs", hop)
+		}
+	}
+
+	if forward {
+		for hop := 1; hop < n; hop++ {
+			if !bytes.Equal(hops[hop].C1, hops[hop-1].C1bar) || !bytes.Equal(hops[hop].RecvC2, hops[hop-1].C2bar) {
+				return fmt.Errorf("hop %d did not decrypt the values hop %d passed on", hop, hop-1)
+			}
+		}
+		return nil
+	}
+	//the reverse shuffle starts at the last OA with the public values of the list, encrypted with randomness 0
+	C1, err := util.DecodePointList(hops[n-1].C1)
+	if err != nil {
+		return fmt.Errorf("hop %d: %v", n-1, err)
+	}
+	for _, c := range C1 {
+		if !c.Equal(suite.Point().Null()) {
+			return fmt.Errorf("hop %d started the round with values it encrypted itself", n-1)
+		}
+	}
+	for hop := n - 2; hop >= 0; hop-- {
+		if !bytes.Equal(hops[hop].C1, hops[hop+1].C1bar) || !bytes.Equal(hops[hop].C2, hops[hop+1].C2bar) {
+			return fmt.Errorf("hop %d did not shuffle the values hop %d passed on", hop, hop+1)
+		}
+	}
+	return nil
+}
+
+//size is the number of points of an encoded list, -1 if it does not decode
+func size(list []byte) int {
+	points, err := util.DecodePointList(list)
+	if err != nil {
+		return -1
+	}
+	return len(points)
+}
+
+// The archive is an append-only file of records (see util.AppendRecord), one gob encoded transcript
+// each. A record cut off by a crash is dropped when the archive is opened again, a damaged record
+// before the last one is reported.
+
+// ErrCorruptRecord marks a record whose length or checksum does not match its content
+var ErrCorruptRecord = util.ErrCorruptRecord
+
+// Archive appends the transcripts of an OA to its file
+type Archive struct {
+	file *os.File
+}
+
+// OpenArchive opens the archive at path for appending, the file and its directory are created if missing
+func OpenArchive(path string) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	_, valid, err := readTranscripts(file)
+	if err != nil && !errors.Is(err, ErrCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		file.Close()
+		return nil, err
+	}
+	if err != nil {
+		if torn, terr := util.TornTail(file, valid, err); terr != nil || !torn {
+			file.Close()
+			if terr != nil {
+				return nil, terr
+			}
+			return nil, fmt.Errorf("%s at offset %d: %w", path, valid, err)
+		}
+		//drop the partially written tail  //截断崩溃时未写完的记录
+		fmt.Println("[Shuffle] Truncate", path, "at offset", valid, ":", err)
+		if err := file.Truncate(valid); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Archive{file}, nil
+}
+
+// Append writes one transcript and syncs it
+func (a *Archive) Append(t *Transcript) error {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(t); err != nil {
+		return err
+	}
+	return util.AppendRecord(a.file, buf.Bytes())
+}
+
+// Close closes the file of the archive
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
+
+// LoadTranscripts reads the archive at path without changing it, a damaged tail is reported
+// with the transcripts before it
+func LoadTranscripts(path string) ([]*Transcript, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	transcripts, _, err := readTranscripts(file)
+	if err != nil {
+		return transcripts, fmt.Errorf("%s after transcript %d: %w", path, len(transcripts), err)
+	}
+	return transcripts, nil
+}
+
+//readTranscripts reads the records of r and returns the offset after the last complete one
+func readTranscripts(r io.Reader) ([]*Transcript, int64, error) {
+	var transcripts []*Transcript
+	var offset int64
+	for {
+		data, err := util.ReadRecord(r)
+		if err == io.EOF {
+			return transcripts, offset, nil
+		}
+		if err != nil {
+			return transcripts, offset, err
+		}
+		t := &Transcript{}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(t); err != nil {
+			return transcripts, offset, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+		}
+		transcripts = append(transcripts, t)
+		offset += util.RecordHeaderSize + int64(len(data))
+	}
+}
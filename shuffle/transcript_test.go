@@ -0,0 +1,414 @@
+package shuffle
+
+import (
+	"NPTM/util"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/proof"
+)
+
+// testOA is an OA of a test topology: its key, its round key with the g it raised and the
+// keys it raised
+type testOA struct {
+	x, r   kyber.Scalar
+	g      kyber.Point
+	keyMap map[string]kyber.Point
+}
+
+func testOAs(suite Suite, n int) []*testOA {
+	rand := suite.XOF([]byte("oas"))
+	oas := make([]*testOA, n)
+	for i := range oas {
+		oas[i] = &testOA{suite.Scalar().Pick(rand), suite.Scalar().Pick(rand), suite.Point().Base(), make(map[string]kyber.Point)}
+	}
+	return oas
+}
+
+// testList is a list the way it passes between hops: the keys and the ciphertexts of their values
+type testList struct {
+	keys, C1, C2 []kyber.Point
+}
+
+// valueKey is the sum of the keys of oas
+func valueKey(suite Suite, oas []*testOA) kyber.Point {
+	key := suite.Point().Null()
+	for _, oa := range oas {
+		key.Add(key, suite.Point().Mul(oa.x, nil))
+	}
+	return key
+}
+
+// testRound shuffles list through oas the way the OAs do, forward from the first OA or reverse from
+// the last, and returns the transcripts of the hops and the list after the last hop. substitute
+// changes the keys a hop gets before it works on them.
+func testRound(t *testing.T, suite Suite, oas []*testOA, K0 int64, forward bool, list testList,
+	substitute func(hop int, keys []kyber.Point)) ([]*Transcript, testList) {
+	n := len(oas)
+	var transcripts []*Transcript
+	g := suite.Point().Base()
+	for step := 0; step < n; step++ {
+		hop := n - 1 - step
+		if forward {
+			hop = step
+		}
+		oa := oas[hop]
+		h := suite.Point().Mul(oa.x, nil)
+		recvKeys := append([]kyber.Point(nil), list.keys...)
+		if substitute != nil {
+			substitute(hop, recvKeys)
+		}
+		k := len(recvKeys)
+		tr := &Transcript{K0: K0, Forward: forward, Hop: hop, RecvKeys: util.ProtobufEncodePointList(recvKeys),
+			C1: util.ProtobufEncodePointList(list.C1)}
+		tr.PublicKey, _ = h.MarshalBinary()
+
+		Y := make([]kyber.Point, k)
+		C2 := list.C2
+		var value kyber.Point
+		var exp proof.Prover
+		if forward {
+			oa.g = g
+			for i, key := range recvKeys {
+				Y[i] = suite.Point().Mul(oa.r, key)
+				oa.keyMap[Y[i].String()] = key
+			}
+			exp = ExponentProver(suite, oa.r, oa.g, recvKeys, Y, 0)
+			var dec proof.Prover
+			C2, dec = Decrypt(suite, oa.x, list.C1, list.C2, 0)
+			tr.RecvC2 = util.ProtobufEncodePointList(list.C2)
+			var err error
+			if tr.DecProof, err = proof.HashProve(suite, "Decrypt", dec); err != nil {
+				t.Fatal(err)
+			}
+			value = valueKey(suite, oas[hop+1:])
+		} else {
+			for i, key := range recvKeys {
+				if Y[i] = oa.keyMap[key.String()]; Y[i] == nil {
+					t.Fatalf("hop %d got an unknown key", hop)
+				}
+			}
+			exp = ExponentProver(suite, oa.r, oa.g, Y, recvKeys, 0)
+			value = valueKey(suite, oas)
+		}
+		var err error
+		if tr.ExpProof, err = proof.HashProve(suite, "Exponent", exp); err != nil {
+			t.Fatal(err)
+		}
+		tr.RecvG, _ = oa.g.MarshalBinary()
+		g = suite.Point().Mul(oa.r, oa.g)
+		tr.G, _ = g.MarshalBinary()
+		tr.ValueKey, _ = value.MarshalBinary()
+		tr.C2 = util.ProtobufEncodePointList(C2)
+
+		X := make([]kyber.Point, k)
+		for i := range X {
+			X[i] = h
+		}
+		Xbar, Ybar, Ytmp, colsbar, prover := ShuffleColumns(suite, nil, h, X, Y,
+			[]kyber.Point{suite.Point().Base(), value}, [][]kyber.Point{list.C1, C2}, suite.RandomStream(), 0)
+		if tr.Proof, err = proof.HashProve(suite, "PairShuffle", prover); err != nil {
+			t.Fatal(err)
+		}
+		keys := make([]kyber.Point, k)
+		for i := range keys {
+			keys[i] = suite.Point().Sub(Ybar[i], Ytmp[i])
+		}
+		tr.X, tr.Y = util.ProtobufEncodePointList(X), util.ProtobufEncodePointList(Y)
+		tr.Xbar, tr.Ybar = util.ProtobufEncodePointList(Xbar), util.ProtobufEncodePointList(Ybar)
+		tr.Keys = util.ProtobufEncodePointList(keys)
+		tr.C1bar, tr.C2bar = util.ProtobufEncodePointList(colsbar[0]), util.ProtobufEncodePointList(colsbar[1])
+		transcripts = append(transcripts, tr)
+		list = testList{keys, colsbar[0], colsbar[1]}
+
+		if !forward {
+			//the round key is used once
+			oa.r = suite.Scalar().Pick(suite.XOF(append([]byte("round"), tr.Proof...)))
+			oa.g = suite.Point().Base()
+			oa.keyMap = make(map[string]kyber.Point)
+		}
+	}
+	return transcripts, list
+}
+
+// testRounds are the transcripts of a forward shuffle of k keys through n OAs, of the reverse
+// shuffle of its list and of the forward shuffle after it
+func testRounds(t *testing.T, suite Suite, n, k int) (forward1, reverse2, forward2 []*Transcript) {
+	oas := testOAs(suite, n)
+	rand := suite.XOF([]byte("list"))
+	key := valueKey(suite, oas)
+	start := testList{make([]kyber.Point, k), make([]kyber.Point, k), make([]kyber.Point, k)}
+	for i := 0; i < k; i++ {
+		r := suite.Scalar().Pick(rand)
+		start.keys[i] = suite.Point().Pick(rand)
+		start.C1[i] = suite.Point().Mul(r, nil)
+		start.C2[i] = suite.Point().Add(suite.Point().Pick(rand), suite.Point().Mul(r, key))
+	}
+	forward1, list := testRound(t, suite, oas, 1, true, start, nil)
+
+	//the reverse shuffle starts from the public values of the list
+	for i := range list.C1 {
+		list.C1[i] = suite.Point().Null()
+	}
+	reverse2, list = testRound(t, suite, oas, 2, false, list, nil)
+	forward2, _ = testRound(t, suite, oas, 2, true, list, nil)
+	return forward1, reverse2, forward2
+}
+
+func TestTranscriptRounds(t *testing.T) {
+	suite := testSuite("rounds")
+	for _, k := range []int{1, 4} {
+		forward1, reverse2, forward2 := testRounds(t, suite, 3, k)
+		for _, round := range [][]*Transcript{forward1, reverse2, forward2} {
+			for _, tr := range round {
+				for _, batch := range []bool{false, true} {
+					if err := tr.Verify(suite, 0, batch); err != nil {
+						t.Fatalf("k %d hop %d forward %v: %v", k, tr.Hop, tr.Forward, err)
+					}
+				}
+			}
+		}
+		if err := CheckRound(suite, forward1, 3, nil); err != nil {
+			t.Fatalf("k %d: %v", k, err)
+		}
+		if err := CheckRound(suite, reverse2, 3, forward1); err != nil {
+			t.Fatalf("k %d: %v", k, err)
+		}
+		if err := CheckRound(suite, forward2, 3, reverse2); err != nil {
+			t.Fatalf("k %d: %v", k, err)
+		}
+	}
+}
+
+// a changed transcript does not verify
+func TestTranscriptTampered(t *testing.T) {
+	suite := testSuite("tampered")
+	forward1, reverse2, _ := testRounds(t, suite, 2, 3)
+	other := util.ProtobufEncodePointList([]kyber.Point{suite.Point().Pick(suite.XOF([]byte("other")))})
+	replaceFirst := func(list []byte) []byte {
+		points, _ := util.DecodePointList(list)
+		points[0] = suite.Point().Pick(suite.XOF([]byte("other")))
+		return util.ProtobufEncodePointList(points)
+	}
+	for name, tamper := range map[string]func(tr *Transcript){
+		"substituted received key": func(tr *Transcript) { tr.RecvKeys = replaceFirst(tr.RecvKeys) },
+		"substituted shuffled key": func(tr *Transcript) { tr.Y = replaceFirst(tr.Y) },
+		"key passed on twice": func(tr *Transcript) {
+			keys, _ := util.DecodePointList(tr.Keys)
+			keys[1] = keys[0]
+			tr.Keys = util.ProtobufEncodePointList(keys)
+		},
+		"other g":            func(tr *Transcript) { tr.G = tr.RecvG },
+		"other value":        func(tr *Transcript) { tr.C2bar = replaceFirst(tr.C2bar) },
+		"other decryption":   func(tr *Transcript) { tr.RecvC2 = replaceFirst(tr.RecvC2) },
+		"short list":         func(tr *Transcript) { tr.RecvKeys = other },
+		"no round key proof": func(tr *Transcript) { tr.ExpProof = nil },
+	} {
+		for _, round := range [][]*Transcript{forward1, reverse2} {
+			tr := *round[1]
+			if name == "other decryption" && !tr.Forward {
+				continue
+			}
+			tamper(&tr)
+			if err := tr.Verify(suite, 0, true); err == nil {
+				t.Fatalf("%s: the transcript of forward %v verifies", name, tr.Forward)
+			}
+		}
+	}
+}
+
+// a hop that substitutes a key and proves its hop honestly is caught by the link to the hop before it
+func TestCheckRoundBrokenChain(t *testing.T) {
+	suite := testSuite("chain")
+	forward1, reverse2, forward2 := testRounds(t, suite, 3, 3)
+
+	oas := testOAs(suite, 3)
+	start := testList{make([]kyber.Point, 3), make([]kyber.Point, 3), make([]kyber.Point, 3)}
+	for i := range start.keys {
+		start.keys[i] = suite.Point().Pick(suite.XOF([]byte{byte(i)}))
+		start.C1[i], start.C2[i] = suite.Point().Null(), suite.Point().Pick(suite.XOF([]byte{byte(i + 3)}))
+	}
+	substituted, _ := testRound(t, suite, oas, 1, true, start, func(hop int, keys []kyber.Point) {
+		if hop == 1 {
+			keys[0] = suite.Point().Pick(suite.XOF([]byte("sybil")))
+		}
+	})
+	for _, tr := range substituted {
+		if err := tr.Verify(suite, 0, true); err != nil {
+			t.Fatalf("hop %d: %v", tr.Hop, err)
+		}
+	}
+
+	for _, c := range []struct {
+		name        string
+		round, prev []*Transcript
+		want        string
+	}{
+		{"substituted key", substituted, nil, "hop 1 did not raise the keys"},
+		{"missing hop", forward1[:2], nil, "no transcript of hop 2"},
+		{"hop twice", append(forward1[:2:2], forward1[1]), nil, "shuffled the list twice"},
+		{"mixed directions", append(forward1[:2:2], reverse2[0]), nil, "other direction"},
+		{"other list", forward2, forward1, "hop 0 did not start with the keys of the reverse shuffle"},
+		{"other round key", reverse2, forward2, "removed another round key"},
+		{"other g", forward2, nil, ""},
+	} {
+		round := c.round
+		if c.name == "other g" {
+			tr := *round[1]
+			tr.RecvG = round[2].G
+			round = []*Transcript{round[0], &tr, round[2]}
+			c.want = "hop 1 did not raise the keys and g"
+		}
+		err := CheckRound(suite, round, 3, c.prev)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: got %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+// archiveTranscripts writes transcripts to a new archive and returns its path and the offset of every record
+func archiveTranscripts(t *testing.T, transcripts []*Transcript) (string, []int64) {
+	path := filepath.Join(t.TempDir(), "shuffles")
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var offsets []int64
+	for _, tr := range transcripts {
+		info, _ := os.Stat(path)
+		offsets = append(offsets, info.Size())
+		if err := archive.Append(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path, offsets
+}
+
+func damage(t *testing.T, path string, offset int64, b []byte) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//flip inverts the byte at offset
+func flip(t *testing.T, path string, offset int64) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damage(t, path, offset, []byte{^data[offset]})
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	suite := testSuite("archive")
+	forward1, _, _ := testRounds(t, suite, 3, 2)
+	path, _ := archiveTranscripts(t, forward1)
+
+	//appending after a reopen keeps the records before
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Append(forward1[0]); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	loaded, err := LoadTranscripts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 4 {
+		t.Fatalf("%d transcripts loaded, want 4", len(loaded))
+	}
+	for i, tr := range loaded {
+		want := forward1[i%3]
+		if tr.Hop != want.Hop || !bytes.Equal(tr.Proof, want.Proof) || !bytes.Equal(tr.ExpProof, want.ExpProof) {
+			t.Fatalf("transcript %d was not loaded as it was archived", i)
+		}
+		if err := tr.Verify(suite, 0, true); err != nil {
+			t.Fatalf("transcript %d: %v", i, err)
+		}
+	}
+	if err := CheckRound(suite, loaded[:3], 3, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a crash while appending leaves a short or garbled last record, OpenArchive cuts it off
+func TestArchiveTornTail(t *testing.T) {
+	suite := testSuite("torn")
+	forward1, _, _ := testRounds(t, suite, 3, 1)
+	for _, name := range []string{"short", "checksum"} {
+		path, offsets := archiveTranscripts(t, forward1)
+		switch name {
+		case "short":
+			info, _ := os.Stat(path)
+			os.Truncate(path, info.Size()-3)
+		case "checksum":
+			flip(t, path, offsets[2]+util.RecordHeaderSize+5)
+		}
+		archive, err := OpenArchive(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		archive.Close()
+		if info, _ := os.Stat(path); info.Size() != offsets[2] {
+			t.Fatalf("%s: the file was cut at %d, want %d", name, info.Size(), offsets[2])
+		}
+		if loaded, err := LoadTranscripts(path); err != nil || len(loaded) != 2 {
+			t.Fatalf("%s: %d transcripts reloaded (%v), want 2", name, len(loaded), err)
+		}
+	}
+}
+
+// a damaged record that is not the last one, or a complete record that does not decode, is reported
+// and the file is left as it is
+func TestArchiveCorruptRecord(t *testing.T) {
+	suite := testSuite("corrupt")
+	forward1, _, _ := testRounds(t, suite, 3, 1)
+	for _, name := range []string{"checksum", "length", "huge length", "no transcript"} {
+		path, offsets := archiveTranscripts(t, forward1)
+		switch name {
+		case "checksum":
+			flip(t, path, offsets[1]+util.RecordHeaderSize+5)
+		case "length":
+			length := make([]byte, 4)
+			binary.BigEndian.PutUint32(length, uint32(offsets[2]-offsets[1]-util.RecordHeaderSize-1))
+			damage(t, path, offsets[1], length)
+		case "huge length":
+			damage(t, path, offsets[1], []byte{0xff, 0xff, 0xff, 0xff})
+		case "no transcript":
+			//a record with a valid checksum whose content is not a transcript, at the end of the file
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := util.AppendRecord(file, []byte("no gob")); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+		}
+		before, _ := os.Stat(path)
+		if _, err := OpenArchive(path); !errors.Is(err, ErrCorruptRecord) {
+			t.Fatalf("%s: got %v, want ErrCorruptRecord", name, err)
+		}
+		if after, _ := os.Stat(path); after.Size() != before.Size() {
+			t.Fatalf("%s: the file was cut", name)
+		}
+		if _, err := LoadTranscripts(path); !errors.Is(err, ErrCorruptRecord) {
+			t.Fatalf("%s: LoadTranscripts got %v, want ErrCorruptRecord", name, err)
+		}
+	}
+}
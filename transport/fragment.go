@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Every encoded event is split into fragments before it is written to the socket:
+//
+//	magic(2) | message id(8) | fragment index(2) | fragment total(2) | payload
+//
+// so a FORWARD_SHUFFLE/REVERSE_SHUFFLE list or a whole block is no longer cut off by the read buffer.
+const (
+	MaxPacketSize      = 4096                              //size of the listener's read buffer
+	MaxFragmentPayload = 1400                              //keep every datagram below a typical MTU
+	fragmentHeaderSize = 14                                //magic + message id + index + total
+	maxFragments       = 1<<16 - 1                         //index and total are uint16
+	ReassemblyTimeout  = 10 * time.Second                  //drop incomplete messages after this time
+	maxMessageSize     = maxFragments * MaxFragmentPayload //largest event that can be framed
+	maxPendingMessages = 4096                              //bound the memory used by incomplete messages
+)
+
+var fragmentMagic = [2]byte{0xD7, 0x46}
+
+var errMalformedFragment = errors.New("malformed fragment")
+
+var ErrMessageTooLarge = errors.New("event is too large to be fragmented")
+
+// fragmentWithID splits an encoded event into numbered datagrams sharing one message id
+func fragmentWithID(id uint64, content []byte) ([][]byte, error) {
+	if len(content) > maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	total := (len(content) + MaxFragmentPayload - 1) / MaxFragmentPayload
+	if total == 0 {
+		total = 1
+	}
+
+	packets := make([][]byte, total)
+	for i := 0; i < total; i++ {
+		start := i * MaxFragmentPayload
+		end := start + MaxFragmentPayload
+		if end > len(content) {
+			end = len(content)
+		}
+		packet := make([]byte, fragmentHeaderSize+end-start)
+		copy(packet[0:2], fragmentMagic[:])
+		binary.BigEndian.PutUint64(packet[2:10], id)
+		binary.BigEndian.PutUint16(packet[10:12], uint16(i))
+		binary.BigEndian.PutUint16(packet[12:14], uint16(total))
+		copy(packet[fragmentHeaderSize:], content[start:end])
+		packets[i] = packet
+	}
+	return packets, nil
+}
+
+// fragment header after parsing
+type fragment struct {
+	ID      uint64
+	Index   int
+	Total   int
+	Payload []byte
+}
+
+func parseFragment(packet []byte) (*fragment, error) {
+	if len(packet) < fragmentHeaderSize || packet[0] != fragmentMagic[0] || packet[1] != fragmentMagic[1] {
+		return nil, errMalformedFragment
+	}
+	f := &fragment{
+		ID:      binary.BigEndian.Uint64(packet[2:10]),
+		Index:   int(binary.BigEndian.Uint16(packet[10:12])),
+		Total:   int(binary.BigEndian.Uint16(packet[12:14])),
+		Payload: packet[fragmentHeaderSize:],
+	}
+	if f.Total == 0 || f.Index >= f.Total || len(f.Payload) > MaxFragmentPayload {
+		return nil, errMalformedFragment
+	}
+	return f, nil
+}
+
+// an incomplete message waiting for the rest of its fragments
+type partialMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	deadline time.Time
+}
+
+// Reassembler collects fragments per (sender, message id) and returns the event once every fragment arrived
+type Reassembler struct {
+	mu         sync.Mutex
+	timeout    time.Duration
+	pending    map[string]*partialMessage
+	lastExpire time.Time
+}
+
+func NewReassembler(timeout time.Duration) *Reassembler {
+	return &Reassembler{timeout: timeout, pending: make(map[string]*partialMessage)}
+}
+
+// Add stores one datagram. It returns the complete event when the last fragment arrives, nil otherwise.
+func (r *Reassembler) Add(packet []byte, addr *net.UDPAddr) ([]byte, error) {
+	f, err := parseFragment(packet)
+	if err != nil {
+		return nil, err
+	}
+	//a single fragment message does not need to be buffered
+	if f.Total == 1 {
+		content := make([]byte, len(f.Payload))
+		copy(content, f.Payload)
+		return content, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.expire(now)
+
+	key := messageKey(addr, f.ID)
+	msg, ok := r.pending[key]
+	if !ok {
+		if len(r.pending) >= maxPendingMessages {
+			return nil, errors.New("too many incomplete messages")
+		}
+		msg = &partialMessage{parts: make([][]byte, f.Total), deadline: now.Add(r.timeout)}
+		r.pending[key] = msg
+	}
+	if len(msg.parts) != f.Total {
+		delete(r.pending, key)
+		return nil, errMalformedFragment
+	}
+	if msg.parts[f.Index] != nil {
+		//duplicated datagram
+		return nil, nil
+	}
+	part := make([]byte, len(f.Payload))
+	copy(part, f.Payload)
+	msg.parts[f.Index] = part
+	msg.received++
+	msg.size += len(part)
+	if msg.received < f.Total {
+		return nil, nil
+	}
+
+	delete(r.pending, key)
+	content := make([]byte, 0, msg.size)
+	for _, p := range msg.parts {
+		content = append(content, p...)
+	}
+	return content, nil
+}
+
+// drop every message whose fragments did not arrive in time
+func (r *Reassembler) expire(now time.Time) {
+	if now.Sub(r.lastExpire) < r.timeout/2 {
+		return
+	}
+	r.lastExpire = now
+	for key, msg := range r.pending {
+		if now.After(msg.deadline) {
+			delete(r.pending, key)
+		}
+	}
+}
+
+func messageKey(addr *net.UDPAddr, id uint64) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, id)
+	return addr.String() + "/" + string(buf)
+}
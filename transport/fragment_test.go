@@ -0,0 +1,183 @@
+package transport
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+var testAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+	return content
+}
+
+func TestFragmentSizes(t *testing.T) {
+	for _, size := range []int{0, 1, MaxFragmentPayload - 1, MaxFragmentPayload, MaxFragmentPayload + 1, 10*MaxFragmentPayload + 7} {
+		content := testContent(size)
+		packets, err := fragmentWithID(42, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := (size + MaxFragmentPayload - 1) / MaxFragmentPayload
+		if want == 0 {
+			want = 1
+		}
+		if len(packets) != want {
+			t.Fatalf("size %d: %d fragments, want %d", size, len(packets), want)
+		}
+		for i, packet := range packets {
+			if len(packet) > fragmentHeaderSize+MaxFragmentPayload {
+				t.Fatalf("size %d: fragment %d has %d bytes", size, i, len(packet))
+			}
+			f, err := parseFragment(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.ID != 42 || f.Index != i || f.Total != want {
+				t.Fatalf("size %d: fragment %d has header %d %d/%d", size, i, f.ID, f.Index, f.Total)
+			}
+		}
+	}
+}
+
+func TestFragmentTooLarge(t *testing.T) {
+	if _, err := fragmentWithID(1, make([]byte, maxMessageSize+1)); err != ErrMessageTooLarge {
+		t.Fatalf("got %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestReassembleInOrder(t *testing.T) {
+	content := testContent(5*MaxFragmentPayload + 100)
+	packets, _ := fragmentWithID(7, content)
+	r := NewReassembler(time.Minute)
+	for i, packet := range packets {
+		msg, err := r.Add(packet, testAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < len(packets)-1 && msg != nil {
+			t.Fatalf("message complete after %d of %d fragments", i+1, len(packets))
+		}
+		if i == len(packets)-1 && !bytes.Equal(msg, content) {
+			t.Fatal("reassembled message differs from the sent one")
+		}
+	}
+	if len(r.pending) != 0 {
+		t.Fatal("the complete message is still pending")
+	}
+}
+
+func TestReassembleReorderedAndDuplicated(t *testing.T) {
+	content := testContent(8 * MaxFragmentPayload)
+	packets, _ := fragmentWithID(7, content)
+	order := rand.New(rand.NewSource(1)).Perm(len(packets))
+	r := NewReassembler(time.Minute)
+	var got []byte
+	for n, i := range order {
+		//every fragment but the last one arrives twice
+		copies := 2
+		if n == len(order)-1 {
+			copies = 1
+		}
+		for c := 0; c < copies; c++ {
+			msg, err := r.Add(packets[i], testAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg != nil {
+				if got != nil {
+					t.Fatal("message delivered twice")
+				}
+				got = msg
+			}
+		}
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("reassembled message differs from the sent one")
+	}
+}
+
+func TestReassembleSendersApart(t *testing.T) {
+	a, b := testContent(3*MaxFragmentPayload), testContent(3*MaxFragmentPayload+1)
+	pa, _ := fragmentWithID(5, a)
+	pb, _ := fragmentWithID(5, b)
+	other := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9001}
+	r := NewReassembler(time.Minute)
+	var gotA, gotB []byte
+	for i := range pa {
+		if msg, _ := r.Add(pa[i], testAddr); msg != nil {
+			gotA = msg
+		}
+		if msg, _ := r.Add(pb[i], other); msg != nil {
+			gotB = msg
+		}
+	}
+	if msg, _ := r.Add(pb[len(pb)-1], other); msg != nil {
+		gotB = msg
+	}
+	if !bytes.Equal(gotA, a) || !bytes.Equal(gotB, b) {
+		t.Fatal("fragments of two senders with the same message id were mixed")
+	}
+}
+
+func TestReassembleMalformed(t *testing.T) {
+	packets, _ := fragmentWithID(9, testContent(2*MaxFragmentPayload))
+	r := NewReassembler(time.Minute)
+	if _, err := r.Add(packets[0][:fragmentHeaderSize-1], testAddr); err == nil {
+		t.Fatal("accepted a truncated header")
+	}
+	bad := append([]byte{}, packets[0]...)
+	bad[0] ^= 0xff
+	if _, err := r.Add(bad, testAddr); err == nil {
+		t.Fatal("accepted a fragment without magic")
+	}
+	//a fragment claiming another total than the pending message drops the message
+	if _, err := r.Add(packets[0], testAddr); err != nil {
+		t.Fatal(err)
+	}
+	wrong := append([]byte{}, packets[1]...)
+	wrong[13] = 3
+	if _, err := r.Add(wrong, testAddr); err == nil {
+		t.Fatal("accepted a fragment with another total")
+	}
+}
+
+func TestUDPSendTooLarge(t *testing.T) {
+	tr, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP socket:", err)
+	}
+	defer tr.Close()
+	if err := tr.Send(tr.LocalAddr(), make([]byte, maxMessageSize+1)); err != ErrMessageTooLarge {
+		t.Fatalf("got %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestUDPSendLarge(t *testing.T) {
+	a, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP socket:", err)
+	}
+	defer a.Close()
+	b, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	content := testContent(100 * MaxFragmentPayload)
+	if err := a.Send(b.LocalAddr(), content); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := b.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("received event differs from the sent one")
+	}
+}
@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// MemoryAddr names an in-memory endpoint, usually written like "host:port"
+type MemoryAddr string
+
+func (a MemoryAddr) Network() string { return "mem" }
+func (a MemoryAddr) String() string  { return string(a) }
+
+// MemoryNetwork hands events between the in-memory transports of one process by appending them
+// to the receiver's queue, so CSP, OAs, APs and UEs can run side by side in a test without sockets.
+type MemoryNetwork struct {
+	mu        sync.Mutex
+	endpoints map[string]*memoryTransport
+	nextPort  int
+}
+
+var DefaultMemoryNetwork = NewMemoryNetwork()
+
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{endpoints: make(map[string]*memoryTransport), nextPort: 50000}
+}
+
+// Listen registers an endpoint, port 0 picks a free one
+func (m *MemoryNetwork) Listen(address string) (Transport, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if port == "0" {
+		for {
+			m.nextPort++
+			candidate := net.JoinHostPort(host, strconv.Itoa(m.nextPort))
+			if _, used := m.endpoints[candidate]; !used {
+				address = candidate
+				break
+			}
+		}
+	}
+	if _, used := m.endpoints[address]; used {
+		return nil, errors.New("address already in use: " + address)
+	}
+	t := &memoryTransport{network: m, addr: MemoryAddr(address), in: newInbox()}
+	m.endpoints[address] = t
+	return t, nil
+}
+
+func (m *MemoryNetwork) lookup(address string) (*memoryTransport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.endpoints[address]
+	return t, ok
+}
+
+func (m *MemoryNetwork) remove(t *memoryTransport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.endpoints[t.addr.String()] == t {
+		delete(m.endpoints, t.addr.String())
+	}
+}
+
+type memoryTransport struct {
+	network *MemoryNetwork
+	addr    MemoryAddr
+	in      *inbox
+}
+
+func (t *memoryTransport) Send(addr net.Addr, content []byte) error {
+	peer, ok := t.network.lookup(addr.String())
+	if !ok {
+		return errors.New("no in-memory endpoint at " + addr.String())
+	}
+	//the receiver gets its own copy, like it would from a socket
+	msg := make([]byte, len(content))
+	copy(msg, content)
+	peer.in.push(Packet{msg, t.addr})
+	return nil
+}
+
+func (t *memoryTransport) Receive() ([]byte, net.Addr, error) {
+	return t.in.pop()
+}
+
+func (t *memoryTransport) LocalAddr() net.Addr {
+	return t.addr
+}
+
+func (t *memoryTransport) ResolveAddr(address string) (net.Addr, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, err
+	}
+	return MemoryAddr(address), nil
+}
+
+func (t *memoryTransport) Close() error {
+	t.network.remove(t)
+	t.in.close(ErrClosed)
+	return nil
+}
@@ -0,0 +1,685 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/sign/schnorr"
+)
+
+// Authenticated and encrypted channels on top of any Transport.
+//
+// Every direction between two entities has its own session. The sender opens it with
+//
+//	HELLO: type(1) | ephemeral point | long-term public key | Schnorr signature
+//	REPLY: type(1) | initiator's ephemeral point | ephemeral point | long-term public key | Schnorr signature
+//
+// signed with the long-term kyber keys of the entities. The HELLO names the address the receiver
+// advertises. A peer whose key is pinned, see Pin, must prove that key in both frames. Both sides derive an AES-GCM key from the ephemeral Diffie-Hellman secret and the
+// transcript, then every event travels as
+//
+//	DATA:  type(1) | session id(8) | counter(8) | sealed event
+//
+// The counter is the GCM nonce, receivers reject counters they have seen before. The responder's
+// fresh ephemeral point is the challenge of the handshake: its session only counts once the first
+// DATA frame proved that the initiator derived the key, so a replayed HELLO can not push out the
+// sessions in use. A receiver without the session of a DATA frame, e.g. after a restart, answers
+//
+//	REJECT: type(1) | session id(8) | counter(8) | long-term public key | Schnorr signature
+//
+// and the sender drops the session, so the next event opens a new one, and sends the rejected events
+// again over it. A REJECT that is not signed by the key of the session is ignored.
+const (
+	helloFrame  = 1
+	replyFrame  = 2
+	dataFrame   = 3
+	rejectFrame = 4
+
+	HandshakeTimeout  = 10 * time.Second
+	sessionIDSize     = 8
+	replayWindow      = 1024
+	maxInboundPerPeer = 4
+	resendWindow      = 64          //events an outbound session keeps to send again after a REJECT
+	rejectInterval    = time.Second //at most one REJECT per peer in this time
+)
+
+var (
+	errHandshake    = errors.New("handshake failed")
+	errNoSession    = errors.New("no session for this peer")
+	errReplayed     = errors.New("replayed or stale frame")
+	errMalformedMsg = errors.New("malformed secure frame")
+	errNotPinned    = errors.New("no key pinned for this peer")
+)
+
+// Suite is the part of the entities' kyber suite needed for the handshake
+type Suite interface {
+	kyber.Group
+	kyber.Random
+}
+
+// one direction of a channel
+type session struct {
+	id       []byte
+	aead     cipher.AEAD
+	peerKey  kyber.Point
+	peerAddr net.Addr
+	//the initiator's ephemeral point, a HELLO with it again is a replay
+	initiatorEph []byte
+
+	mu        sync.Mutex
+	counter   uint64          //next counter to send (outbound sessions)
+	sent      []sentEvent     //the last events sent (outbound sessions)
+	highest   uint64          //highest counter received (inbound sessions)
+	seen      map[uint64]bool //counters received inside the replay window
+	confirmed bool            //a DATA frame was opened (inbound sessions), guarded by SecureTransport.mu
+}
+
+// an event sent in an outbound session
+type sentEvent struct {
+	counter uint64
+	content []byte
+}
+
+// outbound session waiting for the REPLY
+type pendingSession struct {
+	ephemeral kyber.Scalar
+	hello     []byte
+	done      chan struct{}
+	session   *session
+	err       error
+}
+
+// SecureTransport encrypts and authenticates every event sent through the inner transport
+type SecureTransport struct {
+	inner      Transport
+	suite      Suite
+	privateKey kyber.Scalar
+	publicKey  kyber.Point
+	advertised string
+
+	mu        sync.Mutex
+	outbound  map[string]*session
+	recent    map[string][]*session //outbound sessions of a peer, newest last, a REJECT may still name them
+	pending   map[string]*pendingSession
+	inbound   map[string]*session   //by session id
+	byPeer    map[string][]*session //confirmed inbound sessions of a peer, newest last
+	tentative map[string][]*session //inbound sessions of a peer without a DATA frame yet
+	rejected  map[string]time.Time  //when the last REJECT was sent to a peer
+	//the key a peer must prove by address, nil refuses sessions until it is pinned
+	pins map[string]kyber.Point
+
+	in *inbox
+}
+
+// Secure wraps t, the long-term key pair identifies this entity to its peers. advertised is the
+// address the peers send to, the HELLOs must name it, "" is the address t listens at.
+func Secure(t Transport, suite Suite, privateKey kyber.Scalar, publicKey kyber.Point, advertised string) *SecureTransport {
+	if advertised == "" {
+		advertised = t.LocalAddr().String()
+	}
+	s := &SecureTransport{
+		inner:      t,
+		suite:      suite,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		advertised: advertised,
+		outbound:   make(map[string]*session),
+		recent:     make(map[string][]*session),
+		pending:    make(map[string]*pendingSession),
+		inbound:    make(map[string]*session),
+		byPeer:     make(map[string][]*session),
+		tentative:  make(map[string][]*session),
+		rejected:   make(map[string]time.Time),
+		pins:       make(map[string]kyber.Point),
+		in:         newInbox(),
+	}
+	go s.readLoop()
+	return s
+}
+
+func (s *SecureTransport) LocalAddr() net.Addr {
+	return s.inner.LocalAddr()
+}
+
+func (s *SecureTransport) ResolveAddr(address string) (net.Addr, error) {
+	return s.inner.ResolveAddr(address)
+}
+
+func (s *SecureTransport) Close() error {
+	return s.inner.Close()
+}
+
+func (s *SecureTransport) Receive() ([]byte, net.Addr, error) {
+	return s.in.pop()
+}
+
+// PeerKey returns the long-term public key the peer at addr proved in its latest handshake
+func (s *SecureTransport) PeerKey(addr net.Addr) (kyber.Point, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := s.byPeer[addr.String()]
+	if len(sessions) == 0 {
+		return nil, false
+	}
+	return sessions[len(sessions)-1].peerKey, true
+}
+
+// Authenticated reports whether the peer at addr proved possession of key
+func (s *SecureTransport) Authenticated(addr net.Addr, key kyber.Point) bool {
+	if key == nil {
+		return false
+	}
+	peerKey, ok := s.PeerKey(addr)
+	return ok && peerKey.Equal(key)
+}
+
+// Pin makes the handshakes with the peer at addr fail unless it proves key. A nil key refuses to
+// open sessions to addr until the key is pinned, for peers that must not be trusted on first use.
+// An outbound session with another key is dropped.
+func (s *SecureTransport) Pin(addr string, key kyber.Point) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pins[addr] = key
+	if sess, ok := s.outbound[addr]; ok && (key == nil || !sess.peerKey.Equal(key)) {
+		delete(s.outbound, addr)
+	}
+}
+
+// Pinned returns the key pinned for addr, nil if there is none
+func (s *SecureTransport) Pinned(addr string) kyber.Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pins[addr]
+}
+
+// checkPin reports an error if the peer at addr must prove another key than key, a session this
+// side opens also needs the pinned key to be known
+func (s *SecureTransport) checkPin(addr string, key kyber.Point, outbound bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pin, ok := s.pins[addr]
+	if !ok {
+		return nil
+	}
+	if pin == nil {
+		if outbound {
+			return fmt.Errorf("%w: %v %s", errHandshake, errNotPinned, addr)
+		}
+		return nil
+	}
+	if !pin.Equal(key) {
+		return fmt.Errorf("%w: %s proved another key than the pinned one", errHandshake, addr)
+	}
+	return nil
+}
+
+// Send seals content in the session to addr, the session is opened first if needed
+func (s *SecureTransport) Send(addr net.Addr, content []byte) error {
+	sess, err := s.session(addr)
+	if err != nil {
+		return err
+	}
+	sess.mu.Lock()
+	counter := sess.counter
+	sess.counter++
+	sess.sent = append(sess.sent, sentEvent{counter, content})
+	if len(sess.sent) > resendWindow {
+		sess.sent = sess.sent[1:]
+	}
+	sess.mu.Unlock()
+
+	header := make([]byte, 1+sessionIDSize+8)
+	header[0] = dataFrame
+	copy(header[1:], sess.id)
+	binary.BigEndian.PutUint64(header[1+sessionIDSize:], counter)
+	frame := sess.aead.Seal(header, gcmNonce(counter), content, header)
+	return s.inner.Send(addr, frame)
+}
+
+// session returns the established outbound session to addr or runs the handshake
+func (s *SecureTransport) session(addr net.Addr) (*session, error) {
+	peer := addr.String()
+	s.mu.Lock()
+	if sess, ok := s.outbound[peer]; ok {
+		s.mu.Unlock()
+		return sess, nil
+	}
+	if pin, ok := s.pins[peer]; ok && pin == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", errNotPinned, peer)
+	}
+	p, waiting := s.pending[peer]
+	if !waiting {
+		var err error
+		p, err = s.newHello(addr)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.pending[peer] = p
+	}
+	s.mu.Unlock()
+
+	if !waiting {
+		if err := s.inner.Send(addr, p.hello); err != nil {
+			s.finishPending(peer, p, nil, err)
+		}
+	}
+	select {
+	case <-p.done:
+	case <-time.After(HandshakeTimeout):
+		s.finishPending(peer, p, nil, fmt.Errorf("%w: no reply from %s", errHandshake, peer))
+		<-p.done
+	}
+	return p.session, p.err
+}
+
+func (s *SecureTransport) finishPending(peer string, p *pendingSession, sess *session, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[peer] != p {
+		return
+	}
+	delete(s.pending, peer)
+	p.session, p.err = sess, err
+	if sess != nil {
+		s.outbound[peer] = sess
+		sessions := append(s.recent[peer], sess)
+		if len(sessions) > maxInboundPerPeer {
+			sessions = sessions[1:]
+		}
+		s.recent[peer] = sessions
+	}
+	close(p.done)
+}
+
+func (s *SecureTransport) newHello(addr net.Addr) (*pendingSession, error) {
+	ephemeral := s.suite.Scalar().Pick(s.suite.RandomStream())
+	ephPoint, err := s.suite.Point().Mul(ephemeral, nil).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	static, err := s.publicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	//the HELLO is bound to the receiver, so it can not be redirected to another entity
+	sig, err := schnorr.Sign(s.suite, s.privateKey, helloTranscript(ephPoint, static, addr.String()))
+	if err != nil {
+		return nil, err
+	}
+	hello := bytes.Join([][]byte{{helloFrame}, ephPoint, static, sig}, nil)
+	return &pendingSession{ephemeral: ephemeral, hello: hello, done: make(chan struct{})}, nil
+}
+
+func (s *SecureTransport) readLoop() {
+	for {
+		frame, addr, err := s.inner.Receive()
+		if err != nil {
+			s.in.close(err)
+			return
+		}
+		if len(frame) == 0 {
+			continue
+		}
+		switch frame[0] {
+		case helloFrame:
+			err = s.handleHello(frame[1:], addr)
+		case replyFrame:
+			err = s.handleReply(frame[1:], addr)
+		case dataFrame:
+			var content []byte
+			content, err = s.open(frame, addr)
+			if err == nil {
+				s.in.push(Packet{content, addr})
+			} else if err == errNoSession {
+				s.reject(frame, addr)
+			}
+		case rejectFrame:
+			err = s.handleReject(frame[1:], addr)
+		default:
+			err = errMalformedMsg
+		}
+		if err != nil {
+			fmt.Println("[NET] Reject the frame from", addr, ":", err)
+		}
+	}
+}
+
+func (s *SecureTransport) handleHello(body []byte, addr net.Addr) error {
+	pointLen := s.suite.PointLen()
+	if len(body) < 2*pointLen {
+		return errMalformedMsg
+	}
+	peerEph, peerStatic, sig := body[:pointLen], body[pointLen:2*pointLen], body[2*pointLen:]
+	peerKey := s.suite.Point()
+	if err := peerKey.UnmarshalBinary(peerStatic); err != nil {
+		return err
+	}
+	if err := s.checkPin(addr.String(), peerKey, false); err != nil {
+		return err
+	}
+	if err := schnorr.Verify(s.suite, peerKey, helloTranscript(peerEph, peerStatic, s.advertised), sig); err != nil {
+		return fmt.Errorf("%w: %v", errHandshake, err)
+	}
+	if s.answered(addr, peerEph) {
+		return errReplayed
+	}
+	peerEphPoint := s.suite.Point()
+	if err := peerEphPoint.UnmarshalBinary(peerEph); err != nil {
+		return err
+	}
+
+	ephemeral := s.suite.Scalar().Pick(s.suite.RandomStream())
+	ephPoint, _ := s.suite.Point().Mul(ephemeral, nil).MarshalBinary()
+	static, _ := s.publicKey.MarshalBinary()
+	replySig, err := schnorr.Sign(s.suite, s.privateKey, replyTranscript(peerEph, ephPoint, static))
+	if err != nil {
+		return err
+	}
+	shared, _ := s.suite.Point().Mul(ephemeral, peerEphPoint).MarshalBinary()
+	sess, err := newSession(shared, peerEph, ephPoint, peerStatic, static, peerKey, addr)
+	if err != nil {
+		return err
+	}
+	sess.initiatorEph = peerEph
+	s.addInbound(sess)
+
+	reply := bytes.Join([][]byte{{replyFrame}, peerEph, ephPoint, static, replySig}, nil)
+	//do not hold up the other frames while the reply is delivered
+	go func() {
+		if err := s.inner.Send(addr, reply); err != nil {
+			fmt.Println("[NET] Handshake reply to", addr, "failed:", err)
+		}
+	}()
+	return nil
+}
+
+func (s *SecureTransport) handleReply(body []byte, addr net.Addr) error {
+	pointLen := s.suite.PointLen()
+	if len(body) < 3*pointLen {
+		return errMalformedMsg
+	}
+	ownEph, peerEph, peerStatic, sig := body[:pointLen], body[pointLen:2*pointLen], body[2*pointLen:3*pointLen], body[3*pointLen:]
+
+	peer := addr.String()
+	s.mu.Lock()
+	p, ok := s.pending[peer]
+	s.mu.Unlock()
+	if !ok {
+		return errNoSession
+	}
+	myEph, _ := s.suite.Point().Mul(p.ephemeral, nil).MarshalBinary()
+	if !bytes.Equal(myEph, ownEph) {
+		//reply to an older HELLO
+		return errReplayed
+	}
+	peerKey := s.suite.Point()
+	if err := peerKey.UnmarshalBinary(peerStatic); err != nil {
+		return err
+	}
+	if err := schnorr.Verify(s.suite, peerKey, replyTranscript(ownEph, peerEph, peerStatic), sig); err != nil {
+		err = fmt.Errorf("%w: %v", errHandshake, err)
+		s.finishPending(peer, p, nil, err)
+		return err
+	}
+	//a signed reply is no proof of the peer's identity unless the key is the pinned one
+	if err := s.checkPin(peer, peerKey, true); err != nil {
+		s.finishPending(peer, p, nil, err)
+		return err
+	}
+	peerEphPoint := s.suite.Point()
+	if err := peerEphPoint.UnmarshalBinary(peerEph); err != nil {
+		return err
+	}
+	shared, _ := s.suite.Point().Mul(p.ephemeral, peerEphPoint).MarshalBinary()
+	static, _ := s.publicKey.MarshalBinary()
+	sess, err := newSession(shared, ownEph, peerEph, static, peerStatic, peerKey, addr)
+	if err != nil {
+		return err
+	}
+	s.finishPending(peer, p, sess, nil)
+	return nil
+}
+
+// open checks and decrypts a DATA frame
+func (s *SecureTransport) open(frame []byte, addr net.Addr) ([]byte, error) {
+	headerSize := 1 + sessionIDSize + 8
+	if len(frame) < headerSize {
+		return nil, errMalformedMsg
+	}
+	header := frame[:headerSize]
+	id := header[1 : 1+sessionIDSize]
+	counter := binary.BigEndian.Uint64(header[1+sessionIDSize:])
+
+	s.mu.Lock()
+	sess, ok := s.inbound[hex.EncodeToString(id)]
+	s.mu.Unlock()
+	if !ok || sess.peerAddr.String() != addr.String() {
+		return nil, errNoSession
+	}
+	content, err := sess.aead.Open(nil, gcmNonce(counter), frame[headerSize:], header)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.accept(counter) {
+		return nil, errReplayed
+	}
+	s.confirm(sess)
+	return content, nil
+}
+
+// addInbound keeps the session of a HELLO until its first DATA frame confirms it
+func (s *SecureTransport) addInbound(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peer := sess.peerAddr.String()
+	s.inbound[hex.EncodeToString(sess.id)] = sess
+	sessions := append(s.tentative[peer], sess)
+	if len(sessions) > maxInboundPerPeer {
+		delete(s.inbound, hex.EncodeToString(sessions[0].id))
+		sessions = sessions[1:]
+	}
+	s.tentative[peer] = sessions
+}
+
+// confirm moves a session whose initiator proved the key among the sessions of its peer
+func (s *SecureTransport) confirm(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess.confirmed {
+		return
+	}
+	sess.confirmed = true
+	peer := sess.peerAddr.String()
+	tentative := s.tentative[peer]
+	for i, t := range tentative {
+		if t == sess {
+			s.tentative[peer] = append(tentative[:i:i], tentative[i+1:]...)
+			break
+		}
+	}
+	sessions := append(s.byPeer[peer], sess)
+	//frames of a replaced session may still be in flight, keep a few
+	if len(sessions) > maxInboundPerPeer {
+		delete(s.inbound, hex.EncodeToString(sessions[0].id))
+		sessions = sessions[1:]
+	}
+	s.byPeer[peer] = sessions
+}
+
+// answered reports whether a session of the peer was opened with this ephemeral point before
+func (s *SecureTransport) answered(addr net.Addr, eph []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peer := addr.String()
+	for _, sessions := range [][]*session{s.byPeer[peer], s.tentative[peer]} {
+		for _, sess := range sessions {
+			if bytes.Equal(sess.initiatorEph, eph) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reject tells the sender of a DATA frame without session to open a new one
+func (s *SecureTransport) reject(frame []byte, addr net.Addr) {
+	peer := addr.String()
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.rejected[peer]) < rejectInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.rejected[peer] = now
+	s.mu.Unlock()
+
+	header := frame[1 : 1+sessionIDSize+8]
+	static, _ := s.publicKey.MarshalBinary()
+	sig, err := schnorr.Sign(s.suite, s.privateKey, rejectTranscript(header, static))
+	if err != nil {
+		return
+	}
+	msg := bytes.Join([][]byte{{rejectFrame}, header, static, sig}, nil)
+	go func() {
+		if err := s.inner.Send(addr, msg); err != nil {
+			fmt.Println("[NET] Reject to", addr, "failed:", err)
+		}
+	}()
+}
+
+// handleReject drops the outbound session the peer lost and sends the events from the rejected one
+// on again over a new session. Only the key of the session can reject it, so a forged REJECT can not
+// tear down a session or make the sender open a new one.
+func (s *SecureTransport) handleReject(body []byte, addr net.Addr) error {
+	pointLen := s.suite.PointLen()
+	if len(body) < sessionIDSize+8+pointLen {
+		return errMalformedMsg
+	}
+	header, static, sig := body[:sessionIDSize+8], body[sessionIDSize+8:sessionIDSize+8+pointLen], body[sessionIDSize+8+pointLen:]
+	id, counter := header[:sessionIDSize], binary.BigEndian.Uint64(header[sessionIDSize:])
+
+	peer := addr.String()
+	s.mu.Lock()
+	var sess *session
+	for _, r := range s.recent[peer] {
+		if bytes.Equal(r.id, id) {
+			sess = r
+		}
+	}
+	s.mu.Unlock()
+	if sess == nil {
+		return errNoSession
+	}
+	sessionKey, _ := sess.peerKey.MarshalBinary()
+	if !bytes.Equal(static, sessionKey) {
+		return fmt.Errorf("%w: the REJECT is not signed by the key of the session", errHandshake)
+	}
+	if err := schnorr.Verify(s.suite, sess.peerKey, rejectTranscript(header, static), sig); err != nil {
+		return fmt.Errorf("%w: %v", errHandshake, err)
+	}
+
+	s.mu.Lock()
+	if s.outbound[peer] == sess {
+		delete(s.outbound, peer)
+	}
+	s.mu.Unlock()
+	sess.mu.Lock()
+	var resend [][]byte
+	kept := sess.sent[:0]
+	for _, e := range sess.sent {
+		if e.counter >= counter {
+			resend = append(resend, e.content)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	sess.sent = kept
+	sess.mu.Unlock()
+
+	fmt.Println("[NET]", peer, "lost the session, send", len(resend), "events again over a new one")
+	go func() {
+		for _, content := range resend {
+			if err := s.Send(addr, content); err != nil {
+				fmt.Println("[NET] Send to", addr, "failed again:", err)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// accept enforces the replay window of an inbound session
+func (sess *session) accept(counter uint64) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.seen[counter] || (sess.highest >= replayWindow && counter <= sess.highest-replayWindow) {
+		return false
+	}
+	sess.seen[counter] = true
+	if counter > sess.highest {
+		sess.highest = counter
+		for c := range sess.seen {
+			if sess.highest >= replayWindow && c <= sess.highest-replayWindow {
+				delete(sess.seen, c)
+			}
+		}
+	}
+	return true
+}
+
+// newSession derives the key of the direction initiator -> responder
+func newSession(shared, initiatorEph, responderEph, initiatorStatic, responderStatic []byte,
+	peerKey kyber.Point, peerAddr net.Addr) (*session, error) {
+
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte("DePTVM secure channel"), shared, initiatorEph, responderEph, initiatorStatic, responderStatic} {
+		h.Write(part)
+	}
+	key := h.Sum(nil)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	id := sha256.Sum256(append([]byte("session id"), key...))
+	return &session{
+		id:       id[:sessionIDSize],
+		aead:     aead,
+		peerKey:  peerKey,
+		peerAddr: peerAddr,
+		seen:     make(map[uint64]bool),
+	}, nil
+}
+
+func helloTranscript(eph, static []byte, receiver string) []byte {
+	return bytes.Join([][]byte{[]byte("hello"), eph, static, []byte(receiver)}, nil)
+}
+
+func replyTranscript(initiatorEph, responderEph, static []byte) []byte {
+	return bytes.Join([][]byte{[]byte("reply"), initiatorEph, responderEph, static}, nil)
+}
+
+func rejectTranscript(header, static []byte) []byte {
+	return bytes.Join([][]byte{[]byte("reject"), header, static}, nil)
+}
+
+func gcmNonce(counter uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
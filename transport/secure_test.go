@@ -0,0 +1,256 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+	"go.dedis.ch/kyber/v4/sign/schnorr"
+)
+
+var testSuite = edwards25519.NewBlakeSHA256Ed25519()
+
+func testKey() (kyber.Scalar, kyber.Point) {
+	private := testSuite.Scalar().Pick(testSuite.RandomStream())
+	return private, testSuite.Point().Mul(private, nil)
+}
+
+// secureNode listens at address on network with the given key, or a new one
+func secureNode(t *testing.T, network *MemoryNetwork, address string, private kyber.Scalar) (*SecureTransport, Transport) {
+	inner, err := network.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private == nil {
+		private, _ = testKey()
+	}
+	return Secure(inner, testSuite, private, testSuite.Point().Mul(private, nil), ""), inner
+}
+
+func receive(t *testing.T, s Transport) ([]byte, net.Addr) {
+	type packet struct {
+		content []byte
+		addr    net.Addr
+	}
+	got := make(chan packet, 1)
+	go func() {
+		content, addr, _ := s.Receive()
+		got <- packet{content, addr}
+	}()
+	select {
+	case p := <-got:
+		return p.content, p.addr
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event arrived")
+		return nil, nil
+	}
+}
+
+func TestSecureSend(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, _ := secureNode(t, network, "a:1", nil)
+	b, _ := secureNode(t, network, "b:1", nil)
+	defer a.Close()
+	defer b.Close()
+
+	for _, content := range [][]byte{[]byte("first"), []byte("second")} {
+		if err := a.Send(b.LocalAddr(), content); err != nil {
+			t.Fatal(err)
+		}
+		got, from := receive(t, b)
+		if !bytes.Equal(got, content) || from.String() != "a:1" {
+			t.Fatalf("got %q from %v", got, from)
+		}
+	}
+	if !b.Authenticated(a.LocalAddr(), a.publicKey) {
+		t.Fatal("the sender's key is not authenticated")
+	}
+}
+
+// a peer that restarts with its key rejects the frames of the old session, the sender opens a new
+// one and sends the rejected event again
+func TestSecureRestartedPeer(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, _ := secureNode(t, network, "a:1", nil)
+	defer a.Close()
+	bKey, _ := testKey()
+	b, _ := secureNode(t, network, "b:1", bKey)
+	if err := a.Send(b.LocalAddr(), []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, b)
+	b.Close()
+
+	b, _ = secureNode(t, network, "b:1", bKey)
+	defer b.Close()
+	if err := a.Send(b.LocalAddr(), []byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	//the restarted peer proves its key, the event is sent again
+	if got, _ := receive(t, b); !bytes.Equal(got, []byte("lost")) {
+		t.Fatalf("got %q", got)
+	}
+	if err := a.Send(b.LocalAddr(), []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := receive(t, b); !bytes.Equal(got, []byte("after")) {
+		t.Fatalf("got %q", got)
+	}
+}
+
+// only the key of a session can reject it
+func TestSecureForgedReject(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, _ := secureNode(t, network, "a:1", nil)
+	b, _ := secureNode(t, network, "b:1", nil)
+	defer a.Close()
+	defer b.Close()
+	if err := a.Send(b.LocalAddr(), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, b)
+	a.mu.Lock()
+	sess := a.outbound["b:1"]
+	a.mu.Unlock()
+
+	header := make([]byte, sessionIDSize+8)
+	copy(header, sess.id)
+	bStatic, _ := b.publicKey.MarshalBinary()
+	forger, forgerKey := testKey()
+	forgerStatic, _ := forgerKey.MarshalBinary()
+	for name, static := range map[string][]byte{"its own key": forgerStatic, "the key of the session": bStatic} {
+		sig, err := schnorr.Sign(testSuite, forger, rejectTranscript(header, static))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := bytes.Join([][]byte{header, static, sig}, nil)
+		if err := a.handleReject(body, b.LocalAddr()); !errors.Is(err, errHandshake) {
+			t.Fatalf("a REJECT signed by another key naming %s was accepted: %v", name, err)
+		}
+		a.mu.Lock()
+		kept := a.outbound["b:1"] == sess
+		a.mu.Unlock()
+		if !kept {
+			t.Fatalf("a REJECT signed by another key naming %s dropped the session", name)
+		}
+	}
+}
+
+// a peer with a pinned key must prove it, a peer pinned to nil is not sent to
+func TestSecurePinnedKey(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, _ := secureNode(t, network, "a:1", nil)
+	b, _ := secureNode(t, network, "b:1", nil)
+	defer a.Close()
+	defer b.Close()
+
+	_, other := testKey()
+	a.Pin("b:1", other)
+	if err := a.Send(b.LocalAddr(), []byte("first")); !errors.Is(err, errHandshake) {
+		t.Fatalf("the peer proved another key than the pinned one: %v", err)
+	}
+	a.Pin("b:1", nil)
+	if err := a.Send(b.LocalAddr(), []byte("first")); !errors.Is(err, errNotPinned) {
+		t.Fatalf("sent to a peer whose key is not known yet: %v", err)
+	}
+	a.Pin("b:1", b.publicKey)
+	if err := a.Send(b.LocalAddr(), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := receive(t, b); !bytes.Equal(got, []byte("first")) {
+		t.Fatalf("got %q", got)
+	}
+
+	//HELLOs from a pinned address must carry the pinned key, an unknown key is answered
+	b.Pin("a:1", other)
+	p, err := a.newHello(b.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.handleHello(p.hello[1:], a.LocalAddr()); !errors.Is(err, errHandshake) {
+		t.Fatalf("a HELLO with another key than the pinned one was answered: %v", err)
+	}
+	b.Pin("a:1", nil)
+	if err := b.handleHello(p.hello[1:], a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayed HELLOs open no second session and do not push out the session in use
+func TestSecureReplayedHello(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, aInner := secureNode(t, network, "a:1", nil)
+	b, _ := secureNode(t, network, "b:1", nil)
+	defer a.Close()
+	defer b.Close()
+	if err := a.Send(b.LocalAddr(), []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, b)
+
+	p, err := a.newHello(b.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		aInner.Send(b.LocalAddr(), p.hello)
+	}
+	for i := 0; i < 2*maxInboundPerPeer; i++ {
+		other, _ := a.newHello(b.LocalAddr())
+		aInner.Send(b.LocalAddr(), other.hello)
+	}
+	if err := a.Send(b.LocalAddr(), []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := receive(t, b); !bytes.Equal(got, []byte("second")) {
+		t.Fatalf("got %q", got)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := len(b.byPeer["a:1"]); n != 1 {
+		t.Fatalf("%d confirmed sessions, want 1", n)
+	}
+	if n := len(b.tentative["a:1"]); n > maxInboundPerPeer {
+		t.Fatalf("%d unconfirmed sessions", n)
+	}
+}
+
+// a HELLO names the address the receiver advertises
+func TestSecureAdvertisedAddr(t *testing.T) {
+	network := NewMemoryNetwork()
+	a, _ := secureNode(t, network, "a:1", nil)
+	defer a.Close()
+	inner, _ := network.Listen("b:1")
+	private, public := testKey()
+	b := Secure(inner, testSuite, private, public, "elsewhere:1")
+	defer b.Close()
+	p, err := a.newHello(b.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.handleHello(p.hello[1:], a.LocalAddr()); !errors.Is(err, errHandshake) {
+		t.Fatalf("a HELLO naming another address was answered: %v", err)
+	}
+	p, _ = a.newHello(MemoryAddr("elsewhere:1"))
+	if err := b.handleHello(p.hello[1:], a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdvertisedAddr(t *testing.T) {
+	for _, c := range []struct{ listen, host, want string }{
+		{"10.0.0.1:5000", "192.168.0.1", "10.0.0.1:5000"},
+		{"0.0.0.0:5000", "192.168.0.1", "192.168.0.1:5000"},
+		{"[::]:5000", "192.168.0.1", "192.168.0.1:5000"},
+		{"node:5000", "192.168.0.1", "node:5000"},
+	} {
+		if got := AdvertisedAddr(MemoryAddr(c.listen), c.host); got != c.want {
+			t.Errorf("AdvertisedAddr(%s, %s) = %s, want %s", c.listen, c.host, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Every frame on a TCP connection is  length(4) | payload.
+// The first frame of an outgoing connection carries the listening address of the dialer,
+// so the receiver can hand out the address the sender is reachable at instead of its ephemeral port.
+// Only the port is taken from it, the host is the one the connection comes from.
+const (
+	MaxFrameSize = 256 << 20
+	dialTimeout  = 5 * time.Second
+)
+
+var errFrameTooLarge = errors.New("frame too large")
+
+type tcpTransport struct {
+	listener *net.TCPListener
+
+	mu    sync.Mutex
+	conns map[string]*tcpConn //outgoing connections by peer address
+
+	in *inbox
+}
+
+// outgoing connection, writes are serialized
+type tcpConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+func ListenTCP(address string) (Transport, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &tcpTransport{
+		listener: listener,
+		conns:    make(map[string]*tcpConn),
+		in:       newInbox(),
+	}
+	go t.acceptLoop()
+	return t, nil
+}
+
+func (t *tcpTransport) LocalAddr() net.Addr {
+	return t.listener.Addr()
+}
+
+func (t *tcpTransport) ResolveAddr(address string) (net.Addr, error) {
+	return net.ResolveTCPAddr("tcp", address)
+}
+
+func (t *tcpTransport) Close() error {
+	t.mu.Lock()
+	for _, c := range t.conns {
+		c.conn.Close()
+	}
+	t.conns = make(map[string]*tcpConn)
+	t.mu.Unlock()
+	t.in.close(ErrClosed)
+	return t.listener.Close()
+}
+
+// Send writes one frame, a broken cached connection is dialed again once
+func (t *tcpTransport) Send(addr net.Addr, content []byte) error {
+	if len(content) > MaxFrameSize {
+		return errFrameTooLarge
+	}
+	for attempt := 0; ; attempt++ {
+		c, err := t.connection(addr.String())
+		if err != nil {
+			return err
+		}
+		err = c.writeFrame(content)
+		if err == nil {
+			return nil
+		}
+		t.drop(addr.String(), c)
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (t *tcpTransport) Receive() ([]byte, net.Addr, error) {
+	return t.in.pop()
+}
+
+// connection returns the cached connection to peer or dials one, the dial does not hold up
+// the sends to the other peers
+func (t *tcpTransport) connection(peer string) (*tcpConn, error) {
+	t.mu.Lock()
+	c, ok := t.conns[peer]
+	t.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	conn, err := net.DialTimeout("tcp", peer, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c = &tcpConn{conn: conn, w: bufio.NewWriter(conn)}
+	//introduce ourselves with the listening address
+	if err := c.writeFrame([]byte(t.LocalAddr().String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if other, ok := t.conns[peer]; ok {
+		//another send dialed the peer at the same time
+		conn.Close()
+		return other, nil
+	}
+	t.conns[peer] = c
+	return c, nil
+}
+
+func (t *tcpTransport) drop(peer string, c *tcpConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns[peer] == c {
+		delete(t.conns, peer)
+	}
+	c.conn.Close()
+}
+
+func (c *tcpConn) writeFrame(content []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(content)))
+	if _, err := c.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(content); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (t *tcpTransport) acceptLoop() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			t.in.close(err)
+			return
+		}
+		go t.readLoop(conn)
+	}
+}
+
+func (t *tcpTransport) readLoop(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	hello, err := readFrame(r)
+	if err != nil {
+		return
+	}
+	peer, err := helloAddr(conn, string(hello))
+	if err != nil {
+		fmt.Println("[NET] Drop the connection from", conn.RemoteAddr(), ":", err)
+		return
+	}
+	for {
+		content, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		t.in.push(Packet{content, peer})
+	}
+}
+
+// helloAddr is the listening address of the dialer: the port it announced at the address the
+// connection comes from, so a peer can not pass its events off as another host's
+func helloAddr(conn net.Conn, hello string) (*net.TCPAddr, error) {
+	announced, err := net.ResolveTCPAddr("tcp", hello)
+	if err != nil {
+		return nil, err
+	}
+	remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil, errors.New("connection without a TCP address")
+	}
+	//a dialer listening on all interfaces announces the unspecified address
+	if announced.IP != nil && !announced.IP.IsUnspecified() && !announced.IP.Equal(remote.IP) {
+		return nil, errors.New("announced address " + hello + " is not the one the connection comes from")
+	}
+	return &net.TCPAddr{IP: remote.IP, Port: announced.Port, Zone: remote.Zone}, nil
+}
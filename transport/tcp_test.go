@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+)
+
+func listenTCP(t *testing.T) Transport {
+	tr, err := ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Skip("no TCP socket:", err)
+	}
+	t.Cleanup(func() { tr.Close() })
+	return tr
+}
+
+// events of concurrent sends arrive from the listening address of the sender
+func TestTCPSend(t *testing.T) {
+	a, b := listenTCP(t), listenTCP(t)
+	content := testContent(3 * MaxFragmentPayload)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.Send(b.LocalAddr(), content); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		got, from, err := b.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) || from.String() != a.LocalAddr().String() {
+			t.Fatalf("received %d bytes from %v, want %d from %v", len(got), from, len(content), a.LocalAddr())
+		}
+	}
+}
+
+// the hello frame only names the port, the host is the one of the connection
+func TestHelloAddr(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no TCP socket:", err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, c := range []struct {
+		hello string
+		want  string
+	}{
+		{"127.0.0.1:10001", "127.0.0.1:10001"},
+		{"0.0.0.0:10002", "127.0.0.1:10002"},
+		{":10003", "127.0.0.1:10003"},
+		{"10.0.0.7:10004", ""},
+		{"no address", ""},
+	} {
+		addr, err := helloAddr(conn, c.hello)
+		if c.want == "" {
+			if err == nil {
+				t.Fatalf("hello %q is taken as %v", c.hello, addr)
+			}
+			continue
+		}
+		if err != nil || addr.String() != c.want {
+			t.Fatalf("hello %q gives %v, %v, want %s", c.hello, addr, err, c.want)
+		}
+	}
+}
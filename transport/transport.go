@@ -0,0 +1,112 @@
+// Package transport moves encoded events between the entities.
+//
+// A Transport sends an event to a peer, returns received events one after another together with
+// the listening address of the sender, and knows its own listening address. Three implementations exist:
+// the reliable UDP one (fragments, acknowledgements, retransmission), a length-prefixed TCP one
+// for large shuffle and block payloads and an in-memory one which lets a whole deployment run in one process.
+package transport
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+type Transport interface {
+	// Send delivers content to the peer listening at addr
+	Send(addr net.Addr, content []byte) error
+	// Receive blocks until the next event arrives, addr is the sender's listening address
+	Receive() (content []byte, addr net.Addr, err error)
+	// LocalAddr is the address other entities use to reach this one
+	LocalAddr() net.Addr
+	// ResolveAddr turns "host:port" into an address of this transport's network
+	ResolveAddr(address string) (net.Addr, error)
+	Close() error
+}
+
+var ErrClosed = errors.New("transport closed")
+
+// Listen opens a transport of the given network ("udp", "tcp" or "mem") at address
+func Listen(network, address string) (Transport, error) {
+	switch network {
+	case "udp", "":
+		return ListenUDP(address)
+	case "tcp":
+		return ListenTCP(address)
+	case "mem":
+		return DefaultMemoryNetwork.Listen(address)
+	default:
+		return nil, errors.New("unknown transport network: " + network)
+	}
+}
+
+// AdvertisedAddr is the address the peers reach a node listening at listen under: listen itself,
+// or host with the port of listen when the node listens on all interfaces
+func AdvertisedAddr(listen net.Addr, host string) string {
+	h, port, err := net.SplitHostPort(listen.String())
+	if err != nil {
+		return listen.String()
+	}
+	if ip := net.ParseIP(h); h != "" && (ip == nil || !ip.IsUnspecified()) {
+		return listen.String()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Packet is an event received from a peer
+type Packet struct {
+	Content []byte
+	Addr    net.Addr
+}
+
+// unbounded queue of received events, the network readers never block on a slow handler
+type inbox struct {
+	mu     sync.Mutex
+	ready  *sync.Cond
+	queue  []Packet
+	err    error
+	closed bool
+}
+
+func newInbox() *inbox {
+	in := &inbox{}
+	in.ready = sync.NewCond(&in.mu)
+	return in
+}
+
+func (in *inbox) push(p Packet) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return
+	}
+	in.queue = append(in.queue, p)
+	in.ready.Signal()
+}
+
+// close wakes up Receive, the queued events are still handed out first
+func (in *inbox) close(err error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return
+	}
+	in.closed = true
+	in.err = err
+	in.ready.Broadcast()
+}
+
+func (in *inbox) pop() ([]byte, net.Addr, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for len(in.queue) == 0 && !in.closed {
+		in.ready.Wait()
+	}
+	if len(in.queue) == 0 {
+		return nil, nil, in.err
+	}
+	p := in.queue[0]
+	in.queue[0] = Packet{}
+	in.queue = in.queue[1:]
+	return p.Content, p.Addr, nil
+}
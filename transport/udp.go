@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Reliable UDP delivery on top of the fragments:
+// every event sent to a peer gets the next sequence number of that peer (used as the fragment message id),
+// the receiver acknowledges it once all fragments arrived and drops events it already delivered.
+// The sender retransmits the whole event with exponential backoff until the ACK arrives.
+//
+//	ACK packet: magic(2) | sequence number(8)
+const (
+	RetransmitTimeout = 100 * time.Millisecond //first retransmission timeout, doubled after every retry
+	MaxRetransmit     = 8                      //give up after this many retransmissions
+	ackPacketSize     = 10
+	deliveredWindow   = 8192 //sequence numbers remembered per peer for duplicate suppression
+	socketBufferSize  = 4 << 20
+)
+
+var ackMagic = [2]byte{0xD7, 0x41}
+
+var ErrDeliveryFailed = errors.New("no acknowledgement from peer")
+
+// sequence numbers already delivered by one peer
+type deliveredSet struct {
+	seen  map[uint64]bool
+	order []uint64
+}
+
+func (d *deliveredSet) add(seq uint64) bool {
+	if d.seen[seq] {
+		return false
+	}
+	d.seen[seq] = true
+	d.order = append(d.order, seq)
+	if len(d.order) > deliveredWindow {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	return true
+}
+
+// udpTransport delivers every event exactly once or reports a failure to the sender
+type udpTransport struct {
+	conn        *net.UDPConn
+	reassembler *Reassembler
+
+	mu        sync.Mutex
+	nextSeq   map[string]uint64
+	waiting   map[string]chan struct{}
+	delivered map[string]*deliveredSet
+
+	in *inbox
+}
+
+func ListenUDP(address string) (Transport, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadBuffer(socketBufferSize)
+	c := &udpTransport{
+		conn:        conn,
+		reassembler: NewReassembler(ReassemblyTimeout),
+		nextSeq:     make(map[string]uint64),
+		waiting:     make(map[string]chan struct{}),
+		delivered:   make(map[string]*deliveredSet),
+		in:          newInbox(),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *udpTransport) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *udpTransport) ResolveAddr(address string) (net.Addr, error) {
+	return net.ResolveUDPAddr("udp", address)
+}
+
+func (c *udpTransport) Close() error {
+	return c.conn.Close()
+}
+
+// Send blocks until the peer acknowledged the event or the retransmissions are used up
+func (c *udpTransport) Send(to net.Addr, content []byte) error {
+	addr, ok := to.(*net.UDPAddr)
+	if !ok {
+		resolved, err := net.ResolveUDPAddr("udp", to.String())
+		if err != nil {
+			return err
+		}
+		addr = resolved
+	}
+	//an event which can not be framed is refused before it takes a sequence number
+	if len(content) > maxMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	c.mu.Lock()
+	peer := addr.String()
+	seq, ok := c.nextSeq[peer]
+	if !ok {
+		//start from a random number so a restarted node is not taken for a duplicate
+		seq = rand.Uint64()
+	}
+	c.nextSeq[peer] = seq + 1
+	key := waitKey(peer, seq)
+	acked := make(chan struct{})
+	c.waiting[key] = acked
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiting, key)
+		c.mu.Unlock()
+	}()
+
+	packets, err := fragmentWithID(seq, content)
+	if err != nil {
+		return err
+	}
+	timeout := RetransmitTimeout
+	for attempt := 0; attempt <= MaxRetransmit; attempt++ {
+		for _, packet := range packets {
+			if _, err := c.conn.WriteToUDP(packet, addr); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-acked:
+			return nil
+		case <-time.After(timeout):
+			timeout *= 2
+		}
+	}
+	return ErrDeliveryFailed
+}
+
+// Receive blocks until the next event arrives
+func (c *udpTransport) Receive() ([]byte, net.Addr, error) {
+	return c.in.pop()
+}
+
+func (c *udpTransport) readLoop() {
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, addr, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			c.in.close(err)
+			return
+		}
+		packet := buf[:n]
+		if n == ackPacketSize && packet[0] == ackMagic[0] && packet[1] == ackMagic[1] {
+			c.handleAck(addr, binary.BigEndian.Uint64(packet[2:]))
+			continue
+		}
+
+		msg, err := c.reassembler.Add(packet, addr)
+		if err != nil {
+			fmt.Println("[NET] Drop the packet from", addr, ":", err)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(packet[2:10])
+		//acknowledge duplicates again, the first ACK may have been lost
+		c.sendAck(addr, seq)
+		if !c.markDelivered(addr, seq) {
+			continue
+		}
+
+		c.in.push(Packet{msg, addr})
+	}
+}
+
+func (c *udpTransport) handleAck(addr *net.UDPAddr, seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := waitKey(addr.String(), seq)
+	if acked, ok := c.waiting[key]; ok {
+		close(acked)
+		delete(c.waiting, key)
+	}
+}
+
+func (c *udpTransport) sendAck(addr *net.UDPAddr, seq uint64) {
+	ack := make([]byte, ackPacketSize)
+	copy(ack[0:2], ackMagic[:])
+	binary.BigEndian.PutUint64(ack[2:], seq)
+	c.conn.WriteToUDP(ack, addr)
+}
+
+func (c *udpTransport) markDelivered(addr *net.UDPAddr, seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	peer := addr.String()
+	set, ok := c.delivered[peer]
+	if !ok {
+		set = &deliveredSet{seen: make(map[uint64]bool)}
+		c.delivered[peer] = set
+	}
+	return set.add(seq)
+}
+
+func waitKey(peer string, seq uint64) string {
+	return fmt.Sprintf("%s/%d", peer, seq)
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listenUDP(t *testing.T) *udpTransport {
+	c, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c.(*udpTransport)
+}
+
+// lossyRelay forwards the packets between sender and receiver and drops the first ACK of the receiver
+type lossyRelay struct {
+	conn               *net.UDPConn
+	sender, receiver   *net.UDPAddr
+	mu                 sync.Mutex
+	events, acks, lost int
+}
+
+func (r *lossyRelay) run() {
+	buf := make([]byte, MaxPacketSize)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		to := r.receiver
+		if from.String() == r.receiver.String() {
+			to = r.sender
+			r.acks++
+			if r.lost == 0 {
+				r.lost++
+				r.mu.Unlock()
+				continue
+			}
+		} else {
+			r.events++
+		}
+		r.mu.Unlock()
+		r.conn.WriteToUDP(buf[:n], to)
+	}
+}
+
+// a lost ACK makes the sender send the event again, the receiver acknowledges it again but delivers it once
+func TestUDPRetransmitLostAck(t *testing.T) {
+	sender, receiver := listenUDP(t), listenUDP(t)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	relay := &lossyRelay{conn: conn, sender: sender.LocalAddr().(*net.UDPAddr), receiver: receiver.LocalAddr().(*net.UDPAddr)}
+	go relay.run()
+
+	first, second := testContent(3*MaxFragmentPayload+5), []byte("second")
+	if err := sender.Send(conn.LocalAddr(), first); err != nil {
+		t.Fatal(err)
+	}
+	relay.mu.Lock()
+	events, acks := relay.events, relay.acks
+	relay.mu.Unlock()
+	//the 4 fragments are sent again after the lost ACK, and acknowledged again
+	if events < 8 || events%4 != 0 || acks < 2 {
+		t.Fatalf("%d packets and %d ACKs relayed, want 4 fragments sent twice and 2 ACKs", events, acks)
+	}
+	if err := sender.Send(conn.LocalAddr(), second); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]byte{first, second} {
+		if content, _ := receive(t, receiver); !bytes.Equal(content, want) {
+			t.Fatalf("received %d bytes, want the %d of the next event", len(content), len(want))
+		}
+	}
+}
+
+// an event that arrives twice is acknowledged twice and delivered once
+func TestUDPDuplicateDelivery(t *testing.T) {
+	receiver := listenUDP(t)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	acked := func(seq uint64) {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		ack := make([]byte, MaxPacketSize)
+		n, _, err := conn.ReadFromUDP(ack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != ackPacketSize || ack[0] != ackMagic[0] || ack[1] != ackMagic[1] || binary.BigEndian.Uint64(ack[2:]) != seq {
+			t.Fatalf("got %x, want the ACK of %d", ack[:n], seq)
+		}
+	}
+	//7 arrives again after 8, the first ACK may have been lost
+	for _, seq := range []uint64{7, 7, 8, 7, 9} {
+		packets, _ := fragmentWithID(seq, []byte{byte(seq)})
+		if _, err := conn.WriteToUDP(packets[0], receiver.LocalAddr().(*net.UDPAddr)); err != nil {
+			t.Fatal(err)
+		}
+		acked(seq)
+	}
+	for _, want := range []byte{7, 8, 9} {
+		content, from := receive(t, receiver)
+		if !bytes.Equal(content, []byte{want}) || from.String() != conn.LocalAddr().String() {
+			t.Fatalf("received %x from %v, want %x", content, from, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package ue is the user equipment, it registers with an AP and derives its one-time pseudonym every round
+package ue
+
+import (
+	"NPTM/config"
+	"NPTM/proto"
+	"NPTM/transport"
+	"NPTM/util"
+	"fmt"
+	"net"
+	"sync"
+
+	"go.dedis.ch/kyber/v4"
+	"go.dedis.ch/kyber/v4/group/edwards25519"
+
+	"go.dedis.ch/kyber/v4/suites"
+)
+
+//go run ./cmd/ue
+//part code of NE
+
+//define part
+type UserEquipment struct {
+	//net config
+	AccessPointAddr net.Addr
+	AccessPointKey  kyber.Point               //the key the AP proved when it confirmed the registration
+	Socket          *transport.SecureTransport //every event is encrypted and authenticated with the long-term keys
+	Status          int
+	//crypto variables
+	Suite            suites.Suite
+	PrivateKey       kyber.Scalar
+	PublicKey        kyber.Point
+	OnetimePseudoNym kyber.Point
+	G                kyber.Point
+	//closed by Stop
+	stop chan struct{}
+	//guards the state above between the listener and the caller, phase is broadcast after every event
+	mu    sync.Mutex
+	phase *sync.Cond
+}
+
+//status
+
+// configuration
+const UE_CONFIGURATION = 0
+
+// connected with OA
+const UE_CONNECTED = 1
+
+//function part
+
+// Handle processes one event received from addr
+func (userEquipment *UserEquipment) Handle(buf []byte, addr net.Addr) {
+	userEquipment.mu.Lock()
+	defer userEquipment.mu.Unlock()
+	defer userEquipment.phase.Broadcast()
+
+	//decode the event 处理函数
+	/*1.buf []byte:
+	类型是 []byte，表示一个字节切片（slice）。通常用于存储二进制数据或字节流。
+ 	2.addr net.Addr:   发送方地址
+	类型是 net.Addr，表示发送方的监听地址（UDP/TCP/内存传输）。net.UDPAddr 是 Go 标准库中的一个结构体，表示一个 UDP 地址。
+	*/
+
+	event, msg, err := proto.Decode(buf)
+	/*proto.Decode 解码事件并检查协议版本和消息类型。
+	格式错误或版本不兼容的事件只记录日志并丢弃，不会让 UE 进程崩溃。
+	*/
+	if err != nil {
+		fmt.Println("[UE] Reject the event from", addr, ":", err)
+		return
+	}
+	
+	//only the AP this UE registered with may confirm it and hand out g
+	if addr.String() != userEquipment.AccessPointAddr.String() ||
+		(event.EventType == proto.SYNC_REPMAP && !userEquipment.Socket.Authenticated(addr, userEquipment.AccessPointKey)) {
+		fmt.Println("[UE] Reject the unauthenticated event", event.EventType, "from", addr)
+		return
+	}
+
+	switch event.EventType {
+	case proto.UE_REGISTER_CONFIRMATION:
+		userEquipment.handleRegisterConfirmation()
+		break
+	case proto.SYNC_REPMAP:
+		userEquipment.handleSyncRepUE(msg.(*proto.SyncRepMap))
+		break
+	default:
+		fmt.Println("[UE] Unrecognized request!")
+		break
+	}
+}
+
+// New listens on listen ("" picks a free port on ue_ip) and prepares a UE for the AP at APAddr
+//在传输层上解析AP地址，初始化加密套件suite，新建结构体userEquipment
+func New(conf *config.Config, listen string, APAddr string) (*UserEquipment, error) {
+	//listen on a random port, the AP answers to this address
+	if listen == "" {
+		listen = net.JoinHostPort(conf.Conn.UEIP, "0")
+	}
+	Socket, err := transport.Listen(conf.Conn.Transport, listen)
+	if err != nil {
+		return nil, err
+	}
+	//load AP's ip and port
+	AccessPointAddr, err := Socket.ResolveAddr(APAddr)
+	/*Socket.ResolveAddr 把 "ip:port" 形式的字符串解析为当前传输层（UDP/TCP/内存）的 net.Addr 地址。如果解析过程中发生错误，则返回一个错误。
+	APAddr 是一个字符串，表示要解析的地址，通常包含 IP 地址或主机名和端口号（例如 "192.168.1.1:8080" 或 "localhost:8080"）。
+	*/
+	if err != nil {
+		Socket.Close()
+		return nil, err
+	}
+
+	//initlize suite
+	suite := edwards25519.NewBlakeSHA256Ed25519()  // Use the edwards25519-curve
+	//表示初始化一个新的加密套件，使用 Ed25519 椭圆曲线和 Blake2b 哈希函数进行操作。Ed25519 是一种基于椭圆曲线的数字签名算法，具有高安全性和高效性，Blake2b 是一种快速的加密哈希函数。
+	a := suite.Scalar().Pick(suite.RandomStream()) // Alice's private key
+	//生成一个随机的私钥（标量）
+	A := suite.Point().Mul(a, nil)
+	//使用私钥 a 和椭圆曲线的基点（也称为生成元）计算公钥 A。
+	/*suite.Point():
+	创建一个新的椭圆曲线点。在椭圆曲线密码学中，点通常表示公钥。
+	Mul(a, nil):
+	Mul 方法用于点乘操作。a 是一个标量（私钥），nil 表示使用椭圆曲线的基点（生成元）进行乘法运算。
+	具体来说，这相当于计算 a * G，其中 G 是椭圆曲线的基点。
+ 	*/
+	
+	userEquipment := &UserEquipment{AccessPointAddr, nil, transport.Secure(Socket, suite, a, A, transport.AdvertisedAddr(Socket.LocalAddr(), conf.Conn.UEIP)), UE_CONFIGURATION, suite, a, A, suite.Point(), nil,
+		make(chan struct{}), sync.Mutex{}, nil}
+	userEquipment.phase = sync.NewCond(&userEquipment.mu)
+	//初始化一个 UserEquipment 结构体实例，并为其字段赋值。  //suite.Point() 创建了一个新的椭圆曲线点。
+	fmt.Println("[UE] Parameter initialization is complete.")
+	fmt.Println("[UE] My public key is ", userEquipment.PublicKey)
+	return userEquipment, nil
+}
+
+// Start runs the listener and registers with the AP
+func (userEquipment *UserEquipment) Start() {
+	//start listener
+	go userEquipment.startUEListener()
+	userEquipment.registerUE()
+}
+
+// WaitRegistered blocks until the AP confirmed the registration
+func (userEquipment *UserEquipment) WaitRegistered() {
+	userEquipment.mu.Lock()
+	defer userEquipment.mu.Unlock()
+	for userEquipment.Status != UE_CONNECTED {
+		//wait for UE register success
+		userEquipment.phase.Wait()
+	}
+}
+
+// Stop closes the socket, the listener returns
+func (userEquipment *UserEquipment) Stop() {
+	close(userEquipment.stop)
+	userEquipment.Socket.Close()
+}
+
+//register  事件结构体
+func (userEquipment *UserEquipment) registerUE() {
+	// set the parameters to register
+	bytePublicKey, _ := userEquipment.PublicKey.MarshalBinary()
+	// kyber.Point 接口的一个方法，用于将椭圆曲线点序列化为字节数组。这个方法在需要将点传输或存储时非常有用。
+	msg := &proto.Register{PublicKey: bytePublicKey}
+	//声明、初始化并赋值。
+	/*创建了一个 proto.Register 类型的消息，其中的 PublicKey 字段存储序列化后的公钥（字节数组）。
+ 	每种事件类型都有自己的消息结构体，接收方可以检查字段而不是对 map 做类型断言。
+	*/
+	
+	event := proto.NewEvent(proto.UE_REGISTER_APSIDE, msg)
+	//创建一个包含 proto.UE_REGISTER_APSIDE 和 msg 的 proto.Event 结构体实例，并带上协议版本。
+	util.SendUntilDelivered(userEquipment.Socket, userEquipment.AccessPointAddr, util.Encode(event))
+}
+
+// print out register success info
+func (userEquipment *UserEquipment) handleRegisterConfirmation() {
+	//print out the register success info
+	fmt.Println("[UE] Register success !")
+	userEquipment.AccessPointKey, _ = userEquipment.Socket.PeerKey(userEquipment.AccessPointAddr)
+	userEquipment.Status = UE_CONNECTED
+
+}
+
+//get UE's one-time pseudonym and g
+func (userEquipment *UserEquipment) handleSyncRepUE(msg *proto.SyncRepMap) {
+	//set one-time pseudonym and g
+	g := userEquipment.Suite.Point()
+	//使用加密套件取一个点
+	// deserialize g and calculate nym
+	if err := g.UnmarshalBinary(msg.G); err != nil {
+		//反序列化失败，丢弃这个事件
+		fmt.Println("[UE] Reject the reputation map, invalid g:", err)
+		return
+	}
+	nym := userEquipment.Suite.Point().Mul(userEquipment.PrivateKey, g)    //？
+	//set UE'S parameters
+	userEquipment.G = g
+	userEquipment.OnetimePseudoNym = nym
+
+	//print out the UE's nym
+	fmt.Println("[UE] One-Time pseudonym for this round is :", userEquipment.OnetimePseudoNym)
+}
+
+func (userEquipment *UserEquipment) startUEListener() {
+	fmt.Println("[UE] UserEquiment Listener started...")
+	for {
+		//events arrive reassembled, acknowledged and without duplicates
+		msg, addr, err := userEquipment.Socket.Receive()
+		if err != nil {
+			select {
+			case <-userEquipment.stop:
+			default:
+				fmt.Println("[UE] Listener stopped:", err)
+			}
+			return
+		}
+		userEquipment.Handle(msg, addr)
+	}
+}
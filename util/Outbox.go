@@ -0,0 +1,83 @@
+package util
+
+import (
+	"NPTM/transport"
+	"net"
+	"sync"
+)
+
+type outgoing struct {
+	addr    net.Addr
+	content []byte
+}
+
+// Outbox sends events from its own goroutine in the order they were queued, so an entity does not
+// hold its lock while a peer is slow to acknowledge (a UDP retransmission or a handshake)
+type Outbox struct {
+	conn  transport.Transport
+	mu    sync.Mutex
+	ready *sync.Cond
+	queue []outgoing
+	//an event was taken from the queue and is being sent
+	busy   bool
+	closed bool
+	done   chan struct{}
+}
+
+func NewOutbox(conn transport.Transport) *Outbox {
+	o := &Outbox{conn: conn, done: make(chan struct{})}
+	o.ready = sync.NewCond(&o.mu)
+	go o.run()
+	return o
+}
+
+// Send queues the event and returns at once, a failed delivery is logged by Send
+func (o *Outbox) Send(addr net.Addr, content []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	o.queue = append(o.queue, outgoing{addr, content})
+	o.ready.Broadcast()
+}
+
+// Flush returns once the events queued before were sent
+func (o *Outbox) Flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for (len(o.queue) > 0 || o.busy) && !o.closed {
+		o.ready.Wait()
+	}
+}
+
+// Close returns once the events queued before were sent, later ones are dropped
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	o.closed = true
+	o.ready.Broadcast()
+	o.mu.Unlock()
+	<-o.done
+}
+
+func (o *Outbox) run() {
+	for {
+		o.mu.Lock()
+		o.busy = false
+		o.ready.Broadcast()
+		for len(o.queue) == 0 && !o.closed {
+			o.ready.Wait()
+		}
+		if len(o.queue) == 0 {
+			o.mu.Unlock()
+			close(o.done)
+			return
+		}
+		next := o.queue[0]
+		o.queue[0] = outgoing{}
+		o.queue = o.queue[1:]
+		o.busy = true
+		o.mu.Unlock()
+		Send(o.conn, next.addr, next.content)
+	}
+}
@@ -11,5 +11,6 @@ type Pair struct {
 
 type EnPair struct {  
 	Nym kyber.Point
-	Val []byte  //加密后的是字节流
+	//ElGamal ciphertext of the value under the keys of the OAs  //值的ElGamal密文
+	C1, C2 kyber.Point
 }
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// The chain stores and the transcript archives of the OAs are append-only files of records
+//
+//	length(4) | crc32 of the data(4) | data
+//
+// every record is synced when it is written, so a crash can only leave the last record incomplete.
+const RecordHeaderSize = 8
+
+// MaxRecordSize bounds the length read from a record header before its buffer is allocated
+const MaxRecordSize = 256 << 20
+
+// ErrCorruptRecord marks a record whose length or checksum does not match its content
+var ErrCorruptRecord = errors.New("corrupt record")
+
+var errChecksum = fmt.Errorf("%w: checksum mismatch", ErrCorruptRecord)
+
+// ReadRecord reads the data of the next record of r. It returns io.EOF at the end of r and
+// io.ErrUnexpectedEOF for a record that is cut off
+func ReadRecord(r io.Reader) ([]byte, error) {
+	header := make([]byte, RecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if size > MaxRecordSize {
+		return nil, fmt.Errorf("%w: length %d", ErrCorruptRecord, size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errChecksum
+	}
+	return data, nil
+}
+
+// AppendRecord writes data as one record at the offset of file and syncs it, a record that was
+// only partly written is cut off again
+func AppendRecord(file *os.File, data []byte) error {
+	if len(data) > MaxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the limit", len(data))
+	}
+	record := make([]byte, RecordHeaderSize, RecordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(data))
+	record = append(record, data...)
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(record); err != nil {
+		//a partial record must not be followed by the next one
+		file.Truncate(offset)
+		file.Seek(offset, io.SeekStart)
+		return err
+	}
+	return file.Sync()
+}
+
+// TornTail tells whether the record at offset that failed with err is an interrupted last write:
+// it is incomplete, or its checksum does not match and it ends the file. A record that was written
+// completely but does not decode is never cut off
+func TornTail(file *os.File, offset int64, err error) (bool, error) {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, nil
+	}
+	if err != errChecksum {
+		return false, nil
+	}
+	info, serr := file.Stat()
+	if serr != nil {
+		return false, serr
+	}
+	header := make([]byte, RecordHeaderSize)
+	if _, rerr := file.ReadAt(header, offset); rerr != nil {
+		return false, rerr
+	}
+	return offset+RecordHeaderSize+int64(binary.BigEndian.Uint32(header[:4])) == info.Size(), nil
+}
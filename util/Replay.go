@@ -0,0 +1,50 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrStale     = errors.New("message of an older round")
+	ErrDuplicate = errors.New("sequence number already seen")
+)
+
+// Fresh binds signed content to a round and the sender's sequence number,
+// the signature then can not be replayed in another round or twice in the same one
+func Fresh(content []byte, round, seq int64) []byte {
+	return bytes.Join([][]byte{content, ToHexInt(round), ToHexInt(seq)}, []byte{})
+}
+
+// ToSignedRecord is the content a record signature covers
+func ToSignedRecord(record Record, round, seq int64) []byte {
+	return Fresh(ToByteRecord(record), round, seq)
+}
+
+// ReplayGuard remembers the newest round and sequence number accepted from every sender
+type ReplayGuard struct {
+	mu   sync.Mutex
+	last map[string][2]int64
+}
+
+func NewReplayGuard() *ReplayGuard {
+	return &ReplayGuard{last: make(map[string][2]int64)}
+}
+
+// Accept checks a message whose signature is already verified, sequence numbers of a sender
+// must grow and its rounds must not go back
+func (g *ReplayGuard) Accept(sender string, round, seq int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last, ok := g.last[sender]; ok {
+		if round < last[0] {
+			return ErrStale
+		}
+		if seq <= last[1] {
+			return ErrDuplicate
+		}
+	}
+	g.last[sender] = [2]int64{round, seq}
+	return nil
+}
@@ -0,0 +1,44 @@
+package util
+
+import "testing"
+
+func TestReplayGuard(t *testing.T) {
+	guard := NewReplayGuard()
+	for i, c := range []struct {
+		sender     string
+		round, seq int64
+		err        error
+	}{
+		{"oa0", 3, 1, nil},
+		{"oa0", 3, 1, ErrDuplicate}, //replayed
+		{"oa0", 3, 2, nil},
+		{"oa0", 4, 2, ErrDuplicate}, //replayed in a later round
+		{"oa0", 2, 5, ErrStale},     //older round
+		{"oa0", 2, 1, ErrStale},
+		{"oa0", 4, 3, nil},
+		{"oa0", 4, 9, nil}, //gaps of lost messages
+		{"oa0", 3, 10, ErrStale},
+		{"oa1", 1, 1, nil}, //every sender counts on its own
+		{"oa1", 1, 0, ErrDuplicate},
+		{"oa0", 4, 8, ErrDuplicate},
+		{"oa0", 5, 10, nil},
+	} {
+		if err := guard.Accept(c.sender, c.round, c.seq); err != c.err {
+			t.Fatalf("%d: Accept(%s, %d, %d) = %v, want %v", i, c.sender, c.round, c.seq, err, c.err)
+		}
+	}
+}
+
+// a refused message does not move the window
+func TestReplayGuardRefusedKeepsLast(t *testing.T) {
+	guard := NewReplayGuard()
+	if err := guard.Accept("ap", 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Accept("ap", 1, 100); err != ErrStale {
+		t.Fatalf("got %v, want %v", err, ErrStale)
+	}
+	if err := guard.Accept("ap", 5, 6); err != nil {
+		t.Fatalf("the stale message moved the sequence number: %v", err)
+	}
+}
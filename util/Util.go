@@ -2,17 +2,20 @@ package util
 //utility 实用工具包
 
 import (
+	"NPTM/transport"
 	"bytes"
 	"crypto/cipher"
 	"encoding/binary" //用于在不同的数据表示（如字节序列和数值类型）之间进行转换。它提供了将基本数据类型（如整数、浮点数等）编码为字节序列以及将字节序列解码为基本数据类型的功能。
 	"encoding/gob"  //用于进行对象序列化和反序列化。具体来说，它可以将 Go 的数据结构（如结构体、数组、切片、映射等）编码成字节流，方便在网络上传输或者持久化存储；
+	"encoding/hex"
 	"errors"
 	"log"
 	"math"
 	"net"
 	"os"
 	"reflect"
-	"sort"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 
@@ -23,32 +26,6 @@ import (
 	"go.dedis.ch/protobuf"
 )
 
-func SortMap(mp map[string]string) []string {
-
-	//1.将map1的key放到切片中
-	var newMap []string = nil
-	for k, _ := range mp {
-		newMap = append(newMap, k)
-	}
-
-	//2.对切片排序  对字符串切片采用字典序升序排列
-	sort.Strings(newMap)
-
-	var finalList []string = nil
-
-	//遍历排序后的 keys 切片，根据键从 mp 中提取对应的值，并按顺序放入 sortedValues 切片中。
-	for _, s := range newMap {
-		for k, v := range mp {
-			if s == k {
-				finalList = append(finalList, v)
-				break
-			}
-		}
-	}
-
-	return finalList    //返回按排序后的键顺序排列的值的切片
-}
-
 //将二维字节切片转换为ByteArray切片
 //[][]byte：这是一个二维字节切片，即每个元素是一个[]byte（字节切片）
 //[]ByteArray：这是一个ByteArray结构体的切片
@@ -68,18 +45,20 @@ func Encode(event interface{}) []byte {
 	return network.Bytes()
 }
 
-func Send(conn *net.UDPConn, addr *net.UDPAddr, content []byte) {
-	_, err := conn.WriteToUDP(content, addr)
+//Send returns after the transport delivered the event, a failed delivery is reported instead of silently lost
+func Send(conn transport.Transport, addr net.Addr, content []byte) error {
+	err := conn.Send(addr, content)
 	if err != nil {
-		panic(err.Error())
+		log.Println("[NET] Send to", addr, "failed:", err)
 	}
+	return err
 }
 
-func SendToAccessPoint(conn *net.UDPConn, content []byte) {
-	_, err := conn.Write(content)
-	if err != nil {
-		panic(err.Error())
-		//panic 是一种触发运行时错误的机制，用于表示程序无法继续执行的严重错误。
+//SendUntilDelivered retries until the peer is up, so the entities can be started in any order
+func SendUntilDelivered(conn transport.Transport, addr net.Addr, content []byte) {
+	for Send(conn, addr, content) != nil {
+		log.Println("[NET] Retry sending to", addr)
+		time.Sleep(time.Second)
 	}
 }
 //下划线 _ 被称为“空白标识符”（blank identifier）。它用于忽略不需要使用的值或变量。
@@ -117,7 +96,6 @@ func ByteToRecord(byteRecord []byte) Record {
 func CheckErr(err error) {
 	if err != nil {
 		panic(err.Error())
-		os.Exit(1)
 	}
 }
 
@@ -130,7 +108,8 @@ func ProtobufEncodePointList(plist []kyber.Point) []byte {
 	return byteNym
 }
 
-func ProtobufDecodePointList(bytes []byte) []kyber.Point {
+//DecodePointList decodes a list of ProtobufEncodePointList, a malformed list is reported
+func DecodePointList(bytes []byte) ([]kyber.Point, error) {
 	var aPoint kyber.Point
 	var tPoint = reflect.TypeOf(&aPoint).Elem()
 	//reflect.Type 类型的 .Elem() 方法返回指针指向的元素类型。如果 reflect.Type 表示一个指针类型，则 Elem() 返回指针指向的变量类型；否则，它会引发 panic。
@@ -149,11 +128,11 @@ func ProtobufDecodePointList(bytes []byte) []kyber.Point {
 
 	var msg PointList
 	if err := protobuf.DecodeWithConstructors(bytes, &msg, cons); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	/*protobuf.DecodeWithConstructors(bytes, &msg, cons) 使用构造器映射解码字节数组 bytes 为 msg。
 	*/
-	return msg.Points
+	return msg.Points, nil
 
 	/*利用反射和构造器映射来动态地解码点列表。这在需要从序列化的数据中恢复复杂对象（如加密点）时非常有用。
 	*/
@@ -286,3 +265,21 @@ func ElGamalDecrypt(group kyber.Group, prikey kyber.Scalar, K, C kyber.Point) (
 	message, err = M.Data()           // extract the embedded data
 	return
 }
+
+// ReadPrivateKey reads a key written by cmd/oa -genkey, the long-term key of an OA or the CSP
+//读取 -genkey 生成的十六进制私钥
+func ReadPrivateKey(path string) (kyber.Scalar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	byteKey, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	key := edwards25519.NewBlakeSHA256Ed25519().Scalar()
+	if err := key.UnmarshalBinary(byteKey); err != nil {
+		return nil, err
+	}
+	return key, nil
+}